@@ -1,9 +1,13 @@
 package air
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -43,6 +47,23 @@ type Air struct {
 	// It is called "debug_mode" when it is used as a configuration item.
 	DebugMode bool
 
+	// DebugTraceEnabled indicates whether the "X-Air-Trace" header is sent
+	// with every response, which lists each gas and handler executed for
+	// the request with their timings, such as
+	// `air.LoggerGas;dur=1.25, main.getUser;dur=0.82`.
+	//
+	// The timings are inclusive, which means that the timing of a gas
+	// covers all the gases and the handler after it, and are measured up
+	// to when the header is sent.
+	//
+	// It only works when the `DebugMode` is true.
+	//
+	// The default value is false.
+	//
+	// It is called "debug_trace_enabled" when it is used as a
+	// configuration item.
+	DebugTraceEnabled bool
+
 	// LoggerLevel is the level of the logger.
 	//
 	// It only works when the `DebugMode` is false.
@@ -64,7 +85,11 @@ type Air struct {
 	// It is called "address" when it is used as a configuration item.
 	Address string
 
-	// HostWhitelist is the hosts allowed by the server.
+	// HostWhitelist is the hosts allowed by the server. The
+	// internationalized domain names are matched in their ASCII (punycode)
+	// forms regardless of how they are encoded, and the requests whose
+	// hosts are not valid IDNA domain names are rejected with the 400
+	// status code.
 	//
 	// It only works when the `DebugMode` is false.
 	//
@@ -74,6 +99,27 @@ type Air struct {
 	// item.
 	HostWhitelist []string
 
+	// TrustedProxies is the proxies in front of the server whose forwarding
+	// headers are trusted by the `Request#ClientAddress()`. Each of them is
+	// either a CIDR (such as "203.0.113.0/24"), an IP address or one of the
+	// named networks "loopback", "private" and "link_local".
+	//
+	// The default value is nil.
+	//
+	// It is called "trusted_proxies" when it is used as a configuration
+	// item.
+	TrustedProxies []string
+
+	// TrustedProxyHops is the number of proxies in front of the server
+	// that are trusted by the `Request#ClientAddress()` regardless of their
+	// addresses, such as a cloud load balancer whose addresses are unknown.
+	//
+	// The default value is zero.
+	//
+	// It is called "trusted_proxy_hops" when it is used as a configuration
+	// item.
+	TrustedProxyHops int
+
 	// ReadTimeout is the maximum duration the server reads the request.
 	//
 	// The default value is 0.
@@ -116,6 +162,49 @@ type Air struct {
 	// item.
 	MaxHeaderBytes int
 
+	// MultipartMaxBytes is the maximum number of bytes of a multipart
+	// request body parsed for its form values and files, such as by the
+	// `Request#FormFile()`. The files of a larger body are rejected with
+	// the 413 status code. Use the `Request#MultipartReader()` to stream
+	// larger uploads.
+	//
+	// If it is less than or equal to zero, there is no limit.
+	//
+	// The default value is 33554432.
+	//
+	// It is called "multipart_max_bytes" when it is used as a
+	// configuration item.
+	MultipartMaxBytes int64
+
+	// MultipartMaxFileBytes is the maximum number of bytes of each file of
+	// a multipart request body. The larger files are rejected with the 413
+	// status code.
+	//
+	// If it is less than or equal to zero, only the `MultipartMaxBytes`
+	// applies.
+	//
+	// It is called "multipart_max_file_bytes" when it is used as a
+	// configuration item.
+	MultipartMaxFileBytes int64
+
+	// StrictParsingEnabled indicates whether the server rejects the
+	// HTTP/1.x requests that are commonly used to smuggle requests with
+	// the 400 status code and closes their connections, such as the ones
+	// with conflicting "Transfer-Encoding" and "Content-Length" headers,
+	// obs-folded headers, bare LF line endings or malformed chunk
+	// extensions. It is suited for the deployments directly exposed to the
+	// internet.
+	//
+	// It only works for the plaintext connections, which means that both
+	// of the `TLSCertFile` and the `TLSKeyFile` are empty, and the ACME is
+	// not used. A warning is logged when the server starts otherwise.
+	//
+	// The default value is false.
+	//
+	// It is called "strict_parsing_enabled" when it is used as a
+	// configuration item.
+	StrictParsingEnabled bool
+
 	// TLSCertFile is the path to the TLS certificate file used when
 	// starting the server.
 	//
@@ -176,6 +265,55 @@ type Air struct {
 	// configuration item.
 	WebSocketSubprotocols []string
 
+	// BroadcastBufferSize is the number of the messages published by the
+	// `Air#Publish()` that can be queued for a subscriber. The subscribers
+	// that fall behind it are evicted as slow consumers, so that they do
+	// not hold up the others.
+	//
+	// The default value is 64.
+	//
+	// It is called "broadcast_buffer_size" when it is used as a
+	// configuration item.
+	BroadcastBufferSize int
+
+	// TrailingSlashMode is the mode of how the router handles a request
+	// whose path only differs from a registered route by trailing slashes
+	// (route paths are always registered without them). It is one of the
+	// "" (responds with the `NotFoundHandler`), the "redirect" (redirects
+	// to the registered route with the 301 status code for the GET and
+	// the HEAD, or the 308 for the others) and the "rewrite" (serves the
+	// registered route directly).
+	//
+	// The default value is "".
+	//
+	// It is called "trailing_slash_mode" when it is used as a
+	// configuration item.
+	TrailingSlashMode string
+
+	// PathNormalizationEnabled indicates whether the path of every request
+	// is normalized before routing by resolving its "." and ".." segments
+	// (including their percent-encoded forms) and collapsing its duplicate
+	// slashes. The path as it was received can still be accessed by using
+	// the `Request#RawPath()`.
+	//
+	// The default value is false.
+	//
+	// It is called "path_normalization_enabled" when it is used as a
+	// configuration item.
+	PathNormalizationEnabled bool
+
+	// EncodedSlashMode is the mode of how the server handles a request
+	// whose path contains encoded slashes ("%2F"). It is one of the ""
+	// (keeps them as is), the "reject" (responds with the 400 status code)
+	// and the "decode" (decodes them into "/" before routing, and before
+	// the normalization if the `PathNormalizationEnabled` is true).
+	//
+	// The default value is "".
+	//
+	// It is called "encoded_slash_mode" when it is used as a configuration
+	// item.
+	EncodedSlashMode string
+
 	// NotFoundHandler is a `Handler` that returns not found error.
 	//
 	// The default value is the `DefaultNotFoundHandler`.
@@ -192,6 +330,51 @@ type Air struct {
 	// The default value is the `DefaultErrorHandler`.
 	ErrorHandler func(error, *Request, *Response)
 
+	// ErrorMapper maps the errors returned by the route handlers before
+	// they are seen by the `Gases` and the `ErrorHandler`. It can be used
+	// to map errors to the `HTTPError`s, such as mapping the
+	// `sql.ErrNoRows` to the `NewHTTPError(http.StatusNotFound, "")`.
+	//
+	// The default value is nil.
+	ErrorMapper func(error) error
+
+	// ErrorTemplate is the HTML template rendered by the
+	// `DefaultErrorHandler` for the clients that accept HTML, with the
+	// "Status", the "Title" and the "Message" of the error and the "Path"
+	// of the request. The plain text is responded if it is empty or fails
+	// to render.
+	//
+	// The default value is "".
+	//
+	// It is called "error_template" when it is used as a configuration
+	// item.
+	ErrorTemplate string
+
+	// ErrorPages is the HTML templates rendered by the
+	// `DefaultErrorHandler` instead of the `ErrorTemplate` for specific
+	// status codes. Each key is either a status code (such as "404") or a
+	// class of status codes (such as "5xx"), and the former takes
+	// precedence. The templates are rendered with the same data as the
+	// `ErrorTemplate`.
+	//
+	// The default value is nil.
+	//
+	// It is called "error_pages" when it is used as a configuration item.
+	ErrorPages map[string]string
+
+	// ErrorCatalog is the catalog of the errors that are created by using
+	// the `NewCodedError()`, keyed by their error codes (such as the
+	// "USER_NOT_FOUND"). The `DefaultErrorHandler` renders the error
+	// codes as the "code" members of the problem details, and the
+	// messages negotiated by the "Accept-Language" header when the
+	// `I18nEnabled` is true.
+	//
+	// The default value is nil.
+	//
+	// It is called "error_catalog" when it is used as a configuration
+	// item.
+	ErrorCatalog map[string]*ErrorCatalogEntry
+
 	// Pregases is the `Gas` chain stack that performs before routing.
 	//
 	// The default value is nil.
@@ -254,6 +437,19 @@ type Air struct {
 	// item.
 	GzipMIMETypes []string
 
+	// ResponseLazyBodyMaxBytes is the maximum number of bytes of the
+	// message body of a response that will be held back until the response
+	// ends, so that the "Content-Length" header can be set automatically
+	// when it is absent and the chunked transfer coding can be avoided. A
+	// response switches to streaming once its message body exceeds it or
+	// is flushed. A value less than or equal to zero disables it.
+	//
+	// The default value is 4096.
+	//
+	// It is called "response_lazy_body_max_bytes" when it is used as a
+	// configuration item.
+	ResponseLazyBodyMaxBytes int
+
 	// TemplateRoot is the root of the HTML templates. All the HTML
 	// templates inside it will be recursively parsed into the renderer.
 	//
@@ -292,9 +488,76 @@ type Air struct {
 	// TemplateFuncMap is the HTML template function map the renderer
 	// renders the HTML templates.
 	//
+	// Besides the functions in it, the "locstr", the "locstrf", the
+	// "loctimefmt", the "locnum", the "loccurrency", the "partial" and the
+	// "url" are always available. The "locstrf" is the
+	// `Request#LocalizedStringf()`, such as `{{locstrf "apples" .Count}}`.
+	// The "partial" renders an HTML template whose name is only known at
+	// runtime, such as `{{partial .Widget .}}`. The "url" builds a link by
+	// using the `Air#URL()`, such as `{{url "main.getUser" .ID}}`. The
+	// "loctimefmt", the "locnum" and the "loccurrency" are the
+	// `Request#FormatTime()`, the `Request#FormatNumber()` and the
+	// `Request#FormatCurrency()` when the `I18nEnabled` is true, or format
+	// in the `LocaleBase` and the `TimezoneBase` otherwise.
+	//
+	// ATTENTION: It only takes effect when the HTML templates are parsed,
+	// use the `Air#AddTemplateFuncs()` to add functions after that.
+	//
 	// The default value contains strlen, substr and timefmt.
 	TemplateFuncMap map[string]interface{}
 
+	// Renderer is the renderer used by the `Response#Render()` to render
+	// the HTML templates. It can be replaced to use a third-party template
+	// engine, in which case all the template related fields above have no
+	// effect.
+	//
+	// The default value is the built-in renderer based on the
+	// html/template.
+	Renderer Renderer
+
+	// ViewDataProviders is the global `ViewDataProvider`s whose data will
+	// be merged into the data of every HTML template rendering. The
+	// group-level ones can be added by using the `ViewDataGas()`.
+	//
+	// The default value is nil.
+	ViewDataProviders []ViewDataProvider
+
+	// JSONSerializer is the serializer used by the `Response#WriteJSON()`
+	// and the `Request#Bind()` to encode and decode JSON. It can be
+	// replaced to use a faster third-party JSON encoder, in which case all
+	// the JSON related fields below have no effect.
+	//
+	// The default value is the built-in serializer based on the
+	// encoding/json.
+	JSONSerializer JSONSerializer
+
+	// JSONIndent is the indent of the JSON responses.
+	//
+	// The default value is "", which means that the JSON responses are
+	// compact, except in the debug mode where "\t" is used.
+	//
+	// It is called "json_indent" when it is used as a configuration item.
+	JSONIndent string
+
+	// JSONHTMLEscapingDisabled indicates whether the escaping of the
+	// characters "<", ">" and "&" within the JSON strings is disabled.
+	//
+	// The default value is false.
+	//
+	// It is called "json_html_escaping_disabled" when it is used as a
+	// configuration item.
+	JSONHTMLEscapingDisabled bool
+
+	// JSONUnknownFieldsDisallowed indicates whether the JSON request bodies
+	// containing fields that do not match any of the destination struct
+	// fields are rejected when binding.
+	//
+	// The default value is false.
+	//
+	// It is called "json_unknown_fields_disallowed" when it is used as a
+	// configuration item.
+	JSONUnknownFieldsDisallowed bool
+
 	// CofferEnabled indicates whether the coffer is enabled.
 	//
 	// The default value is false.
@@ -338,7 +601,9 @@ type Air struct {
 	I18nEnabled bool
 
 	// LocaleRoot is the root of the locale files. All the locale files
-	// inside it will be parsed into the i18n.
+	// (TOML-based, or JSON-based if their extensions are ".json") inside
+	// it will be parsed into the i18n. The nested tables in them are
+	// flattened with their keys joined by ".", such as the "nav.home".
 	//
 	// The default value is "locales".
 	//
@@ -353,8 +618,72 @@ type Air struct {
 	// It is called "locale_base" when it is used as a configuration item.
 	LocaleBase string
 
-	// ConfigFile is the TOML-based configuration file that will be parsed
-	// into the matching configuration items before starting the server.
+	// LocaleQueryName is the name of the query param that overrides the
+	// locale negotiated from the "Accept-Language" header, such as "lang".
+	//
+	// The default value is "".
+	//
+	// It is called "locale_query_name" when it is used as a configuration
+	// item.
+	LocaleQueryName string
+
+	// LocaleCookieName is the name of the cookie that overrides the locale
+	// negotiated from the "Accept-Language" header. The query param named
+	// the `LocaleQueryName` takes precedence.
+	//
+	// The default value is "".
+	//
+	// It is called "locale_cookie_name" when it is used as a configuration
+	// item.
+	LocaleCookieName string
+
+	// TimezoneBase is the IANA name of the timezone used to format the
+	// times for the requests whose timezones are unknown.
+	//
+	// The default value is "UTC".
+	//
+	// It is called "timezone_base" when it is used as a configuration item.
+	TimezoneBase string
+
+	// TimezoneCookieName is the name of the cookie that carries the IANA
+	// name of the timezone of the client, which overrides the one of the
+	// "Time-Zone" header.
+	//
+	// The default value is "".
+	//
+	// It is called "timezone_cookie_name" when it is used as a
+	// configuration item.
+	TimezoneCookieName string
+
+	// TimezoneProvider returns the IANA name of the timezone of the client
+	// of a request, such as the one in the profile of the current user. It
+	// takes precedence over the `TimezoneCookieName` and the "Time-Zone"
+	// header, and is skipped if it returns "".
+	//
+	// The default value is nil.
+	TimezoneProvider func(req *Request) string
+
+	// Store is the key-value store used as the backend of the features that
+	// need to share states. The `BoltStore` can be used for single-binary
+	// deployments.
+	//
+	// The default value is nil.
+	Store Store
+
+	// WarmUpURLs is the URL paths that will be requested by using the
+	// `Air#WarmUp()` in the background when the server starts, so that
+	// the caches (such as the coffer and the HTML templates) are populated
+	// before the first real requests arrive.
+	//
+	// The default value is nil.
+	//
+	// It is called "warm_up_urls" when it is used as a configuration item.
+	WarmUpURLs []string
+
+	// ConfigFile is the TOML-based (or JSON-based if its extension is
+	// ".json") configuration file that will be parsed into the matching
+	// configuration items by using the `Air#LoadConfig()` before starting
+	// the server.
 	//
 	// The default value is "".
 	ConfigFile string
@@ -371,6 +700,9 @@ type Air struct {
 	contentTypeSnifferBufferPool *sync.Pool
 	reverseProxyTransport        *http.Transport
 	reverseProxyBufferPool       *reverseProxyBufferPool
+	groupGases                   map[string][]Gas
+	broadcastHub                 *broadcastHub
+	trustedProxies               *trustedProxies
 }
 
 // Default is the default instance of the `Air`.
@@ -384,7 +716,9 @@ func New() *Air {
 		LoggerOutput:            os.Stdout,
 		Address:                 ":8080",
 		MaxHeaderBytes:          1 << 20,
+		MultipartMaxBytes:       32 << 20,
 		ACMECertRoot:            "acme-certs",
+		BroadcastBufferSize:     64,
 		NotFoundHandler:         DefaultNotFoundHandler,
 		MethodNotAllowedHandler: DefaultMethodNotAllowedHandler,
 		ErrorHandler:            DefaultErrorHandler,
@@ -406,10 +740,11 @@ func New() *Air {
 			"application/xml",
 			"image/svg+xml",
 		},
-		TemplateRoot:       "templates",
-		TemplateExts:       []string{".html"},
-		TemplateLeftDelim:  "{{",
-		TemplateRightDelim: "}}",
+		ResponseLazyBodyMaxBytes: 4096,
+		TemplateRoot:             "templates",
+		TemplateExts:             []string{".html"},
+		TemplateLeftDelim:        "{{",
+		TemplateRightDelim:       "}}",
 		TemplateFuncMap: map[string]interface{}{
 			"strlen":  strlen,
 			"substr":  substr,
@@ -429,8 +764,9 @@ func New() *Air {
 			".png",
 			".gif",
 		},
-		LocaleRoot: "locales",
-		LocaleBase: "en-US",
+		LocaleRoot:   "locales",
+		LocaleBase:   "en-US",
+		TimezoneBase: "UTC",
 	}
 
 	a.logger = newLogger(a)
@@ -440,6 +776,8 @@ func New() *Air {
 	a.binder = newBinder(a)
 	a.minifier = newMinifier(a)
 	a.renderer = newRenderer(a)
+	a.Renderer = a.renderer
+	a.JSONSerializer = newJSONSerializer(a)
 	a.coffer = newCoffer(a)
 	a.i18n = newI18n(a)
 	a.contentTypeSnifferBufferPool = &sync.Pool{
@@ -450,6 +788,9 @@ func New() *Air {
 
 	a.reverseProxyTransport = newReverseProxyTransport()
 	a.reverseProxyBufferPool = newReverseProxyBufferPool()
+	a.groupGases = map[string][]Gas{}
+	a.broadcastHub = newBroadcastHub(a)
+	a.trustedProxies = newTrustedProxies(a)
 
 	return a
 }
@@ -490,56 +831,56 @@ func (a *Air) PANIC(msg string, extras ...map[string]interface{}) {
 
 // GET registers a new GET route for the path with the matching h in the router
 // with the optional route-level gases.
-func (a *Air) GET(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodGet, path, h, gases...)
+func (a *Air) GET(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodGet, path, h, gases...)
 }
 
 // HEAD registers a new HEAD route for the path with the matching h in the
 // router with the optional route-level gases.
-func (a *Air) HEAD(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodHead, path, h, gases...)
+func (a *Air) HEAD(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodHead, path, h, gases...)
 }
 
 // POST registers a new POST route for the path with the matching h in the
 // router with the optional route-level gases.
-func (a *Air) POST(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodPost, path, h, gases...)
+func (a *Air) POST(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodPost, path, h, gases...)
 }
 
 // PUT registers a new PUT route for the path with the matching h in the router
 // with the optional route-level gases.
-func (a *Air) PUT(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodPut, path, h, gases...)
+func (a *Air) PUT(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodPut, path, h, gases...)
 }
 
 // PATCH registers a new PATCH route for the path with the matching h in the
 // router with the optional route-level gases.
-func (a *Air) PATCH(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodPatch, path, h, gases...)
+func (a *Air) PATCH(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodPatch, path, h, gases...)
 }
 
 // DELETE registers a new DELETE route for the path with the matching h in the
 // router with the optional route-level gases.
-func (a *Air) DELETE(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodDelete, path, h, gases...)
+func (a *Air) DELETE(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodDelete, path, h, gases...)
 }
 
 // CONNECT registers a new CONNECT route for the path with the matching h in the
 // router with the optional route-level gases.
-func (a *Air) CONNECT(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodConnect, path, h, gases...)
+func (a *Air) CONNECT(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodConnect, path, h, gases...)
 }
 
 // OPTIONS registers a new OPTIONS route for the path with the matching h in the
 // router with the optional route-level gases.
-func (a *Air) OPTIONS(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodOptions, path, h, gases...)
+func (a *Air) OPTIONS(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodOptions, path, h, gases...)
 }
 
 // TRACE registers a new TRACE route for the path with the matching h in the
 // router with the optional route-level gases.
-func (a *Air) TRACE(path string, h Handler, gases ...Gas) {
-	a.router.register(http.MethodTrace, path, h, gases...)
+func (a *Air) TRACE(path string, h Handler, gases ...Gas) *Route {
+	return a.router.register(http.MethodTrace, path, h, gases...)
 }
 
 // BATCH registers a batch of routes for the methods and the path with the
@@ -636,9 +977,58 @@ func (a *Air) FILES(prefix, root string, gases ...Gas) {
 	a.BATCH([]string{http.MethodGet, http.MethodHead}, prefix, h, gases...)
 }
 
+// URL returns the URL path of the first route named the name with the params
+// filled into its param names and wildcard in order. It returns "" if not
+// found.
+//
+// For example, the `a.URL("main.getUser", 1)` returns "/users/1" if the
+// `a.GET("/users/:id", getUser)` has been called.
+//
+// The routes must be named (see the `Route#Name`) before the server starts.
+func (a *Air) URL(name string, params ...interface{}) string {
+	a.router.Lock()
+	defer a.router.Unlock()
+
+	for _, r := range a.router.routes {
+		if r.Name == name {
+			return r.url(params...)
+		}
+	}
+
+	return ""
+}
+
+// Routes returns all the registered routes in the order of registration. It is
+// useful for debugging.
+func (a *Air) Routes() []*Route {
+	a.router.Lock()
+	defer a.router.Unlock()
+	return append([]*Route(nil), a.router.routes...)
+}
+
+// AddTemplateFuncs adds the fm to the `TemplateFuncMap` and makes the renderer
+// re-parse the HTML templates before the next rendering, so the functions in
+// the fm are available even if some HTML templates have been rendered.
+func (a *Air) AddTemplateFuncs(fm map[string]interface{}) {
+	if a.TemplateFuncMap == nil {
+		a.TemplateFuncMap = make(map[string]interface{}, len(fm))
+	}
+
+	for n, f := range fm {
+		a.TemplateFuncMap[n] = f
+	}
+
+	a.renderer.reset()
+}
+
 // Group returns a new instance of the `Group` with the path prefix and the
-// optional group-level gases.
+// optional group-level gases. The group-level gases loaded for the prefix by
+// the `Air#LoadGases()` will be appended to the gases.
 func (a *Air) Group(prefix string, gases ...Gas) *Group {
+	if ggs := a.groupGases[prefix]; len(ggs) > 0 {
+		gases = append(gases[:len(gases):len(gases)], ggs...)
+	}
+
 	return &Group{
 		Air:    a,
 		Prefix: prefix,
@@ -648,38 +1038,125 @@ func (a *Air) Group(prefix string, gases ...Gas) *Group {
 
 // Serve starts the server.
 func (a *Air) Serve() error {
-	if a.ConfigFile == "" {
-		return a.server.serve()
+	if a.ConfigFile != "" {
+		if err := a.LoadConfig(a.ConfigFile); err != nil {
+			return err
+		}
 	}
 
-	m := map[string]toml.Primitive{}
-	md, err := toml.DecodeFile(a.ConfigFile, &m)
-	if err != nil {
-		return err
+	if len(a.WarmUpURLs) > 0 {
+		go a.WarmUp(a.WarmUpURLs...)
+	}
+
+	return a.server.serve()
+}
+
+// LoadConfig parses the configuration file into the matching configuration
+// items of the a. The file must be TOML-based (or JSON-based if its extension
+// is ".json"). Only the environment variables are parsed if the filename is
+// empty.
+//
+// Each configuration item can be overridden by an environment variable named
+// "AIR_" followed by its name in upper case, such as "AIR_ADDRESS" for the
+// "address" and "AIR_DEBUG_MODE" for the "debug_mode". The value of the
+// environment variable is taken literally by a string item, and is parsed as a
+// TOML value (or is treated as a string if it is not a valid one) by the
+// others.
+//
+// Each format is decoded on its own, so that a null item of a JSON-based file
+// leaves the matching configuration item unchanged, and the unknown items are
+// ignored whatever their values are (such as the mixed-type arrays).
+//
+// The YAML-based files are not supported, convert them into the TOML or the
+// JSON instead. The configuration is loaded only when it is called (which is
+// done by the `Air#Serve()` if the `Air#ConfigFile` is not empty), it is not
+// reloaded when the file changes, since most items (such as the `Air#Address`)
+// cannot be changed after the server starts.
+func (a *Air) LoadConfig(filename string) error {
+	m := map[string]interface{}{}
+	if filename != "" {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		if strings.ToLower(filepath.Ext(filename)) == ".json" {
+			d := json.NewDecoder(bytes.NewReader(b))
+			d.UseNumber()
+			if err := d.Decode(&m); err != nil {
+				return err
+			}
+		} else if err := toml.Unmarshal(b, &m); err != nil {
+			return err
+		}
+	}
+
+	envs := map[string]string{}
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "AIR_") {
+			continue
+		}
+
+		i := strings.IndexByte(e, '=')
+		if i < 0 {
+			continue
+		}
+
+		k := strings.ToLower(e[len("AIR_"):i])
+		envs[k] = e[i+1:]
+
+		ev := struct {
+			V interface{} `toml:"v"`
+		}{}
+		if _, err := toml.Decode("v = "+envs[k], &ev); err != nil {
+			ev.V = envs[k]
+		}
+
+		m[k] = ev.V
+	}
+
+	// The environment variables are taken literally by the string items,
+	// so that the "AIR_APP_NAME=123" is not rejected as an integer.
+	decode := func(p interface{}, key string, v interface{}) error {
+		if s, ok := v.(*string); ok {
+			if ev, ok := envs[key]; ok {
+				*s = ev
+				return nil
+			}
+		}
+
+		return configItemDecode(p, key, v)
 	}
 
 	if p, ok := m["app_name"]; ok {
-		if err := md.PrimitiveDecode(p, &a.AppName); err != nil {
+		if err := decode(p, "app_name", &a.AppName); err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["maintainer_email"]; ok {
-		err := md.PrimitiveDecode(p, &a.MaintainerEmail)
+		err := decode(p, "maintainer_email", &a.MaintainerEmail)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["debug_mode"]; ok {
-		if err := md.PrimitiveDecode(p, &a.DebugMode); err != nil {
+		if err := decode(p, "debug_mode", &a.DebugMode); err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["debug_trace_enabled"]; ok {
+		err := decode(p, "debug_trace_enabled", &a.DebugTraceEnabled)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["logger_level"]; ok {
 		lll := ""
-		if err := md.PrimitiveDecode(p, &lll); err != nil {
+		if err := decode(p, "logger_level", &lll); err != nil {
 			return err
 		}
 
@@ -702,81 +1179,138 @@ func (a *Air) Serve() error {
 	}
 
 	if p, ok := m["address"]; ok {
-		if err := md.PrimitiveDecode(p, &a.Address); err != nil {
+		if err := decode(p, "address", &a.Address); err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["host_whitelist"]; ok {
 		a.HostWhitelist = a.HostWhitelist[:0]
-		if err := md.PrimitiveDecode(p, &a.HostWhitelist); err != nil {
+		err := decode(p, "host_whitelist", &a.HostWhitelist)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["trusted_proxies"]; ok {
+		a.TrustedProxies = a.TrustedProxies[:0]
+		err := decode(p, "trusted_proxies", &a.TrustedProxies)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["trusted_proxy_hops"]; ok {
+		err := decode(p, "trusted_proxy_hops", &a.TrustedProxyHops)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["read_timeout"]; ok {
-		if err := md.PrimitiveDecode(p, &a.ReadTimeout); err != nil {
+		err := decode(p, "read_timeout", &a.ReadTimeout)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["read_header_timeout"]; ok {
-		err := md.PrimitiveDecode(p, &a.ReadHeaderTimeout)
+		err := decode(p, "read_header_timeout", &a.ReadHeaderTimeout)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["write_timeout"]; ok {
-		if err := md.PrimitiveDecode(p, &a.WriteTimeout); err != nil {
+		err := decode(p, "write_timeout", &a.WriteTimeout)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["idle_timeout"]; ok {
-		if err := md.PrimitiveDecode(p, &a.IdleTimeout); err != nil {
+		err := decode(p, "idle_timeout", &a.IdleTimeout)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["max_header_bytes"]; ok {
-		if err := md.PrimitiveDecode(p, &a.MaxHeaderBytes); err != nil {
+		err := decode(p, "max_header_bytes", &a.MaxHeaderBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["multipart_max_bytes"]; ok {
+		err := decode(p, "multipart_max_bytes", &a.MultipartMaxBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["multipart_max_file_bytes"]; ok {
+		err := decode(
+			p,
+			"multipart_max_file_bytes",
+			&a.MultipartMaxFileBytes,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["strict_parsing_enabled"]; ok {
+		err := decode(
+			p,
+			"strict_parsing_enabled",
+			&a.StrictParsingEnabled,
+		)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["tls_cert_file"]; ok {
-		if err := md.PrimitiveDecode(p, &a.TLSCertFile); err != nil {
+		err := decode(p, "tls_cert_file", &a.TLSCertFile)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["tls_key_file"]; ok {
-		if err := md.PrimitiveDecode(p, &a.TLSKeyFile); err != nil {
+		if err := decode(p, "tls_key_file", &a.TLSKeyFile); err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["acme_enabled"]; ok {
-		if err := md.PrimitiveDecode(p, &a.ACMEEnabled); err != nil {
+		err := decode(p, "acme_enabled", &a.ACMEEnabled)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["acme_cert_root"]; ok {
-		if err := md.PrimitiveDecode(p, &a.ACMECertRoot); err != nil {
+		err := decode(p, "acme_cert_root", &a.ACMECertRoot)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["https_enforced"]; ok {
-		if err := md.PrimitiveDecode(p, &a.HTTPSEnforced); err != nil {
+		err := decode(p, "https_enforced", &a.HTTPSEnforced)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["websocket_handshake_timeout"]; ok {
-		err := md.PrimitiveDecode(p, &a.WebSocketHandshakeTimeout)
+		err := decode(
+			p,
+			"websocket_handshake_timeout",
+			&a.WebSocketHandshakeTimeout,
+		)
 		if err != nil {
 			return err
 		}
@@ -784,21 +1318,83 @@ func (a *Air) Serve() error {
 
 	if p, ok := m["websocket_subprotocols"]; ok {
 		a.WebSocketSubprotocols = a.WebSocketSubprotocols[:0]
-		err := md.PrimitiveDecode(p, &a.WebSocketSubprotocols)
+		err := decode(
+			p,
+			"websocket_subprotocols",
+			&a.WebSocketSubprotocols,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["broadcast_buffer_size"]; ok {
+		err := decode(
+			p,
+			"broadcast_buffer_size",
+			&a.BroadcastBufferSize,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["trailing_slash_mode"]; ok {
+		err := decode(p, "trailing_slash_mode", &a.TrailingSlashMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["path_normalization_enabled"]; ok {
+		err := decode(
+			p,
+			"path_normalization_enabled",
+			&a.PathNormalizationEnabled,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["encoded_slash_mode"]; ok {
+		err := decode(p, "encoded_slash_mode", &a.EncodedSlashMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["error_template"]; ok {
+		err := decode(p, "error_template", &a.ErrorTemplate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["error_pages"]; ok {
+		a.ErrorPages = map[string]string{}
+		if err := decode(p, "error_pages", &a.ErrorPages); err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["error_catalog"]; ok {
+		a.ErrorCatalog = map[string]*ErrorCatalogEntry{}
+		err := decode(p, "error_catalog", &a.ErrorCatalog)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["auto_push_enabled"]; ok {
-		err := md.PrimitiveDecode(p, &a.AutoPushEnabled)
+		err := decode(p, "auto_push_enabled", &a.AutoPushEnabled)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["minifier_enabled"]; ok {
-		err := md.PrimitiveDecode(p, &a.MinifierEnabled)
+		err := decode(p, "minifier_enabled", &a.MinifierEnabled)
 		if err != nil {
 			return err
 		}
@@ -806,20 +1402,25 @@ func (a *Air) Serve() error {
 
 	if p, ok := m["minifier_mime_types"]; ok {
 		a.MinifierMIMETypes = a.MinifierMIMETypes[:0]
-		err := md.PrimitiveDecode(p, &a.MinifierMIMETypes)
+		err := decode(p, "minifier_mime_types", &a.MinifierMIMETypes)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["gzip_enabled"]; ok {
-		if err := md.PrimitiveDecode(p, &a.GzipEnabled); err != nil {
+		err := decode(p, "gzip_enabled", &a.GzipEnabled)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["gzip_compression_level"]; ok {
-		err := md.PrimitiveDecode(p, &a.GzipCompressionLevel)
+		err := decode(
+			p,
+			"gzip_compression_level",
+			&a.GzipCompressionLevel,
+		)
 		if err != nil {
 			return err
 		}
@@ -827,83 +1428,186 @@ func (a *Air) Serve() error {
 
 	if p, ok := m["gzip_mime_types"]; ok {
 		a.GzipMIMETypes = a.GzipMIMETypes[:0]
-		if err := md.PrimitiveDecode(p, &a.GzipMIMETypes); err != nil {
+		err := decode(p, "gzip_mime_types", &a.GzipMIMETypes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["response_lazy_body_max_bytes"]; ok {
+		err := decode(
+			p,
+			"response_lazy_body_max_bytes",
+			&a.ResponseLazyBodyMaxBytes,
+		)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["template_root"]; ok {
-		if err := md.PrimitiveDecode(p, &a.TemplateRoot); err != nil {
+		err := decode(p, "template_root", &a.TemplateRoot)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["template_exts"]; ok {
 		a.TemplateExts = a.TemplateExts[:0]
-		if err := md.PrimitiveDecode(p, &a.TemplateExts); err != nil {
+		err := decode(p, "template_exts", &a.TemplateExts)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["template_left_delim"]; ok {
-		err := md.PrimitiveDecode(p, &a.TemplateLeftDelim)
+		err := decode(p, "template_left_delim", &a.TemplateLeftDelim)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["template_right_delim"]; ok {
-		err := md.PrimitiveDecode(p, &a.TemplateRightDelim)
+		err := decode(p, "template_right_delim", &a.TemplateRightDelim)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["json_indent"]; ok {
+		if err := decode(p, "json_indent", &a.JSONIndent); err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["json_html_escaping_disabled"]; ok {
+		err := decode(
+			p,
+			"json_html_escaping_disabled",
+			&a.JSONHTMLEscapingDisabled,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["json_unknown_fields_disallowed"]; ok {
+		err := decode(
+			p,
+			"json_unknown_fields_disallowed",
+			&a.JSONUnknownFieldsDisallowed,
+		)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["coffer_enabled"]; ok {
-		if err := md.PrimitiveDecode(p, &a.CofferEnabled); err != nil {
+		err := decode(p, "coffer_enabled", &a.CofferEnabled)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["coffer_max_memory_bytes"]; ok {
-		err := md.PrimitiveDecode(p, &a.CofferMaxMemoryBytes)
+		err := decode(
+			p,
+			"coffer_max_memory_bytes",
+			&a.CofferMaxMemoryBytes,
+		)
 		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["asset_root"]; ok {
-		if err := md.PrimitiveDecode(p, &a.AssetRoot); err != nil {
+		if err := decode(p, "asset_root", &a.AssetRoot); err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["asset_exts"]; ok {
 		a.AssetExts = a.AssetExts[:0]
-		if err := md.PrimitiveDecode(p, &a.AssetExts); err != nil {
+		if err := decode(p, "asset_exts", &a.AssetExts); err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["i18n_enabled"]; ok {
-		if err := md.PrimitiveDecode(p, &a.I18nEnabled); err != nil {
+		err := decode(p, "i18n_enabled", &a.I18nEnabled)
+		if err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["locale_root"]; ok {
-		if err := md.PrimitiveDecode(p, &a.LocaleRoot); err != nil {
+		if err := decode(p, "locale_root", &a.LocaleRoot); err != nil {
 			return err
 		}
 	}
 
 	if p, ok := m["locale_base"]; ok {
-		if err := md.PrimitiveDecode(p, &a.LocaleBase); err != nil {
+		if err := decode(p, "locale_base", &a.LocaleBase); err != nil {
 			return err
 		}
 	}
 
-	return a.server.serve()
+	if p, ok := m["locale_query_name"]; ok {
+		err := decode(p, "locale_query_name", &a.LocaleQueryName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["locale_cookie_name"]; ok {
+		err := decode(p, "locale_cookie_name", &a.LocaleCookieName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["timezone_base"]; ok {
+		err := decode(p, "timezone_base", &a.TimezoneBase)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["timezone_cookie_name"]; ok {
+		err := decode(p, "timezone_cookie_name", &a.TimezoneCookieName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := m["warm_up_urls"]; ok {
+		a.WarmUpURLs = a.WarmUpURLs[:0]
+		if err := decode(p, "warm_up_urls", &a.WarmUpURLs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configItemDecode decodes the p, which is a value decoded from a TOML-based
+// or JSON-based configuration file, into the v as the configuration item named
+// the key. A null p leaves the v unchanged.
+func configItemDecode(p interface{}, key string, v interface{}) error {
+	b, err := json.Marshal(p)
+	if err == nil {
+		err = json.Unmarshal(b, v)
+	}
+
+	if err != nil {
+		return fmt.Errorf(
+			"air: invalid configuration item %q: %v",
+			key,
+			err,
+		)
+	}
+
+	return nil
 }
 
 // Close closes the server immediately.
@@ -918,9 +1622,25 @@ func (a *Air) Shutdown(timeout time.Duration) error {
 	return a.server.shutdown(timeout)
 }
 
+// ServeHTTP implements the `http.Handler`. It allows the a to be mounted
+// inside an existing `http.Handler`, such as an `http.ServeMux`, without
+// calling the `Air#Serve()`.
+func (a *Air) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	a.server.ServeHTTP(rw, r)
+}
+
 // Handler defines a function to serve requests.
 type Handler func(*Request, *Response) error
 
+// WrapHTTPHandler provides a convenient way to wrap an `http.Handler` into a
+// `Handler`.
+func WrapHTTPHandler(h http.Handler) Handler {
+	return func(req *Request, res *Response) error {
+		h.ServeHTTP(res.HTTPResponseWriter(), req.HTTPRequest())
+		return nil
+	}
+}
+
 // DefaultNotFoundHandler is the default `Handler` that returns not found error.
 func DefaultNotFoundHandler(req *Request, res *Response) error {
 	res.Status = http.StatusNotFound
@@ -935,14 +1655,83 @@ func DefaultMethodNotAllowedHandler(req *Request, res *Response) error {
 }
 
 // DefaultErrorHandler is the default centralized error handler for the server.
+//
+// It responds with an RFC 7807 "application/problem+json" content to the
+// clients that prefer JSON, with the `Air#ErrorPages` or the
+// `Air#ErrorTemplate` (if any) to the clients that accept HTML, and with a
+// "text/plain" content to the others. The status code is taken from the err if
+// it is an `HTTPError`. The field messages of the `ValidationErrors` in the err
+// are rendered as the "errors" member or the template data "Errors". The error
+// code of the `HTTPError` is rendered as the "code" member or the template data
+// "Code", and its documentation URL in the `Air#ErrorCatalog` is rendered as
+// the "type" member or the template data "DocumentationURL".
 func DefaultErrorHandler(err error, req *Request, res *Response) {
 	if res.ContentLength > 0 {
 		return
 	}
 
+	var he *HTTPError
+	if errors.As(err, &he) && !res.Written {
+		res.Status = he.status(req.Air)
+	}
+
 	m := err.Error()
+	code, docURL := "", ""
+	if he != nil && he.ErrorCode != "" {
+		m = he.message(req)
+		code = he.ErrorCode
+		if ece := req.Air.ErrorCatalog[code]; ece != nil {
+			docURL = ece.DocumentationURL
+		}
+	}
+
 	if !req.Air.DebugMode && res.Status == http.StatusInternalServerError {
 		m = http.StatusText(res.Status)
+	} else if he != nil && he.Internal != nil && req.Air.DebugMode {
+		m += ": " + he.Internal.Error()
+	}
+
+	var fes map[string][]string
+	if ves := ValidationErrors(nil); errors.As(err, &ves) {
+		fes = ves.Fields()
+	}
+
+	if acceptsProblemJSON(req) {
+		pt := "about:blank"
+		if docURL != "" {
+			pt = docURL
+		}
+
+		b, err := req.Air.JSONSerializer.Marshal(&problem{
+			Type:     pt,
+			Title:    http.StatusText(res.Status),
+			Status:   res.Status,
+			Detail:   m,
+			Instance: req.Path,
+			Code:     code,
+			Errors:   fes,
+		})
+		if err == nil {
+			res.Header.Set(
+				"Content-Type",
+				"application/problem+json",
+			)
+			res.Write(bytes.NewReader(b))
+			return
+		}
+	} else if et := errorPage(req.Air, res.Status); et != "" &&
+		strings.Contains(req.Header.Get("Accept"), "text/html") {
+		if err := res.Render(map[string]interface{}{
+			"Status":           res.Status,
+			"Title":            http.StatusText(res.Status),
+			"Message":          m,
+			"Path":             req.Path,
+			"Errors":           fes,
+			"Code":             code,
+			"DocumentationURL": docURL,
+		}, et); err == nil {
+			return
+		}
 	}
 
 	res.WriteString(m)
@@ -1003,6 +1792,21 @@ func stringSliceContains(ss []string, s string) bool {
 	return false
 }
 
+// stringSlicesEqual reports whether the ss1 and the ss2 are equal.
+func stringSlicesEqual(ss1, ss2 []string) bool {
+	if len(ss1) != len(ss2) {
+		return false
+	}
+
+	for i := range ss1 {
+		if ss1[i] != ss2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // stringSliceContainsCIly reports whether the ss contains the s
 // case-insensitively.
 func stringSliceContainsCIly(ss []string, s string) bool {
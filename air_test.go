@@ -0,0 +1,181 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirServeHTTP(t *testing.T) {
+	a := New()
+	a.GET("/foo", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/", a)
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Foobar", rec.Body.String())
+
+	a.GET("/bar", func(req *Request, res *Response) error {
+		return res.WriteString(req.Param("q").Value().String())
+	})
+
+	sp := http.StripPrefix("/app", a)
+
+	req = httptest.NewRequest(http.MethodGet, "/app/foo", nil)
+	rec = httptest.NewRecorder()
+	sp.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Foobar", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/app/bar?q=foo%20bar", nil)
+	rec = httptest.NewRecorder()
+	sp.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "foo bar", rec.Body.String())
+}
+
+func TestWrapHTTPHandler(t *testing.T) {
+	a := New()
+	a.GET("/foo", WrapHTTPHandler(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		r *http.Request,
+	) {
+		rw.Header().Set("Foo", "bar")
+		rw.WriteHeader(http.StatusTeapot)
+		rw.Write([]byte(r.URL.Path))
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	assert.Equal(t, "bar", rec.Header().Get("Foo"))
+	assert.Equal(t, "/foo", rec.Body.String())
+}
+
+func TestWrapHTTPMiddleware(t *testing.T) {
+	a := New()
+	a.GET("/foo", func(req *Request, res *Response) error {
+		return res.WriteString(req.Header.Get("Foo"))
+	}, WrapHTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(
+			rw http.ResponseWriter,
+			r *http.Request,
+		) {
+			r.Header.Set("Foo", "bar")
+			rw.Header().Set("Bar", "foo")
+			next.ServeHTTP(rw, r)
+		})
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "foo", rec.Header().Get("Bar"))
+	assert.Equal(t, "bar", rec.Body.String())
+}
+
+func TestAirLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestAirLoadConfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tf := filepath.Join(dir, "config.toml")
+	assert.NoError(t, ioutil.WriteFile(tf, []byte(`
+app_name = "foobar"
+address = ":8080"
+read_timeout = 1000000000
+host_whitelist = ["example.com"]
+`), 0644))
+
+	a := New()
+	assert.NoError(t, a.LoadConfig(tf))
+	assert.Equal(t, "foobar", a.AppName)
+	assert.Equal(t, ":8080", a.Address)
+	assert.Equal(t, time.Second, a.ReadTimeout)
+	assert.Equal(t, []string{"example.com"}, a.HostWhitelist)
+
+	jf := filepath.Join(dir, "config.json")
+	assert.NoError(t, ioutil.WriteFile(jf, []byte(`{
+	"app_name": "foobar",
+	"read_timeout": 2000000000,
+	"debug_mode": true
+}`), 0644))
+
+	os.Setenv("AIR_ADDRESS", ":9090")
+	os.Setenv("AIR_GZIP_ENABLED", "true")
+	defer os.Unsetenv("AIR_ADDRESS")
+	defer os.Unsetenv("AIR_GZIP_ENABLED")
+
+	a = New()
+	assert.NoError(t, a.LoadConfig(jf))
+	assert.Equal(t, "foobar", a.AppName)
+	assert.Equal(t, ":9090", a.Address)
+	assert.Equal(t, 2*time.Second, a.ReadTimeout)
+	assert.True(t, a.DebugMode)
+	assert.True(t, a.GzipEnabled)
+
+	a = New()
+	assert.NoError(t, a.LoadConfig(""))
+	assert.Equal(t, ":9090", a.Address)
+
+	os.Setenv("AIR_APP_NAME", "123")
+	os.Setenv("AIR_TLS_CERT_FILE", "true")
+	defer os.Unsetenv("AIR_APP_NAME")
+	defer os.Unsetenv("AIR_TLS_CERT_FILE")
+
+	a = New()
+	assert.NoError(t, a.LoadConfig(tf))
+	assert.Equal(t, "123", a.AppName)
+	assert.Equal(t, "true", a.TLSCertFile)
+	assert.Equal(t, ":9090", a.Address)
+
+	assert.Error(t, a.LoadConfig(filepath.Join(dir, "nonexistent.toml")))
+
+	os.Unsetenv("AIR_APP_NAME")
+	os.Unsetenv("AIR_TLS_CERT_FILE")
+
+	jf = filepath.Join(dir, "config_null.json")
+	assert.NoError(t, ioutil.WriteFile(jf, []byte(`{
+	"app_name": null,
+	"read_timeout": 3000000000,
+	"host_whitelist": null,
+	"error_catalog": {
+		"not_found": {
+			"status": 404,
+			"documentation_url": "https://example.com/not_found"
+		}
+	},
+	"foobar": [1, "foo", {"bar": null}]
+}`), 0644))
+
+	a = New()
+	assert.NoError(t, a.LoadConfig(jf))
+	assert.Equal(t, "air", a.AppName)
+	assert.Equal(t, 3*time.Second, a.ReadTimeout)
+	assert.Nil(t, a.HostWhitelist)
+	assert.Equal(t, 404, a.ErrorCatalog["not_found"].Status)
+	assert.Equal(
+		t,
+		"https://example.com/not_found",
+		a.ErrorCatalog["not_found"].DocumentationURL,
+	)
+
+	assert.NoError(t, ioutil.WriteFile(jf, []byte(`{
+	"read_timeout": "foobar"
+}`), 0644))
+	assert.Error(t, New().LoadConfig(jf))
+}
@@ -0,0 +1,197 @@
+package air
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArtifactStorage is the storage backend of the immutable artifacts addressed
+// by their SHA-256 digests. The digests are always 64 lowercase hex digits.
+//
+// All the methods of it must be safe for concurrent use.
+type ArtifactStorage interface {
+	// Open opens the artifact with the digest. It returns an error for
+	// which the `os.IsNotExist()` reports true if not found.
+	Open(digest string) (io.ReadSeekCloser, error)
+
+	// Put stores the artifact with the digest read from the r. It must not
+	// store anything if reading from the r fails, since that is how a
+	// digest mismatch is reported.
+	Put(digest string, r io.Reader) error
+}
+
+// FileArtifactStorage is an `ArtifactStorage` backed by the local file system.
+type FileArtifactStorage struct {
+	// Root is the root directory of the artifacts.
+	Root string
+}
+
+// Open implements the `ArtifactStorage`.
+func (fas *FileArtifactStorage) Open(digest string) (io.ReadSeekCloser, error) {
+	return os.Open(fas.filename(digest))
+}
+
+// Put implements the `ArtifactStorage`.
+func (fas *FileArtifactStorage) Put(digest string, r io.Reader) error {
+	fn := fas.filename(digest)
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(fn), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), fn)
+}
+
+// filename returns the filename of the artifact with the digest in the fas.
+func (fas *FileArtifactStorage) filename(digest string) string {
+	return filepath.Join(fas.Root, digest[:2], digest)
+}
+
+// ARTIFACTS registers a new GET route and a new HEAD route with the path prefix
+// to serve the immutable artifacts addressed by their SHA-256 digests (such as
+// "/artifacts/sha256-<hex>") from the as with the optional route-level gases.
+// It is suited for build artifact and plugin distribution services.
+//
+// The content of each artifact is verified against its digest before being
+// served. The artifacts can be uploaded by using the
+// `ArtifactUploadHandler()`.
+func (a *Air) ARTIFACTS(prefix string, as ArtifactStorage, gases ...Gas) {
+	h := func(req *Request, res *Response) error {
+		digest, ok := artifactDigest(req)
+		if !ok {
+			return a.NotFoundHandler(req, res)
+		}
+
+		f, err := as.Open(digest)
+		if os.IsNotExist(err) {
+			return a.NotFoundHandler(req, res)
+		} else if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		} else if hex.EncodeToString(h.Sum(nil)) != digest {
+			return errors.New("artifact digest mismatch")
+		} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		res.Header.Set(
+			"Cache-Control",
+			"public, max-age=31536000, immutable",
+		)
+		res.Header.Set("ETag", `"sha256-`+digest+`"`)
+
+		return res.Write(f)
+	}
+
+	a.BATCH(
+		[]string{http.MethodGet, http.MethodHead},
+		strings.TrimSuffix(prefix, "/")+"/:artifact",
+		h,
+		gases...,
+	)
+}
+
+// ArtifactUploadHandler returns a `Handler` that stores the request body into
+// the as as the artifact named by the route param "artifact" (such as
+// "sha256-<hex>"). The request body is rejected if it does not match the
+// digest. It responds with the 201 status code if the artifact is created, or
+// the 200 if it already exists.
+//
+// It is usually registered with authentication gases, such as
+// `a.PUT("/artifacts/:artifact", ArtifactUploadHandler(as), authGas)`.
+func ArtifactUploadHandler(as ArtifactStorage) Handler {
+	return func(req *Request, res *Response) error {
+		digest, ok := artifactDigest(req)
+		if !ok {
+			res.Status = http.StatusBadRequest
+			return errors.New("invalid artifact name")
+		}
+
+		if f, err := as.Open(digest); err == nil {
+			f.Close()
+			return res.WriteString("sha256-" + digest)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		dr := &artifactDigestReader{
+			r:      req.Body,
+			h:      sha256.New(),
+			digest: digest,
+		}
+		if err := as.Put(digest, dr); err != nil {
+			if dr.mismatched {
+				res.Status = http.StatusBadRequest
+			}
+
+			return err
+		}
+
+		res.Status = http.StatusCreated
+
+		return res.WriteString("sha256-" + digest)
+	}
+}
+
+// artifactDigest returns the digest from the route param "artifact" of the req.
+// It reports false if the route param is not a valid artifact name.
+func artifactDigest(req *Request) (string, bool) {
+	p := req.Param("artifact")
+	if p == nil {
+		return "", false
+	}
+
+	digest := strings.TrimPrefix(p.Value().String(), "sha256-")
+	if len(digest) != 64 || !isLowerHex(digest) ||
+		len(p.Value().String()) != len(digest)+7 {
+		return "", false
+	}
+
+	return digest, true
+}
+
+// artifactDigestReader is an `io.Reader` that fails at the end of its
+// underlying reader if the content read does not match the digest.
+type artifactDigestReader struct {
+	r          io.Reader
+	h          hash.Hash
+	digest     string
+	mismatched bool
+}
+
+// Read implements the `io.Reader`.
+func (adr *artifactDigestReader) Read(b []byte) (int, error) {
+	n, err := adr.r.Read(b)
+	adr.h.Write(b[:n])
+	if err == io.EOF && hex.EncodeToString(adr.h.Sum(nil)) != adr.digest {
+		adr.mismatched = true
+		return n, errors.New("artifact digest mismatch")
+	}
+
+	return n, err
+}
@@ -0,0 +1,89 @@
+package air
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestArtifacts")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	as := &FileArtifactStorage{
+		Root: dir,
+	}
+
+	a := New()
+	a.ARTIFACTS("/artifacts", as)
+	a.PUT("/artifacts/:artifact", ArtifactUploadHandler(as))
+
+	sum := sha256.Sum256([]byte("Foobar"))
+	name := "sha256-" + hex.EncodeToString(sum[:])
+
+	req := httptest.NewRequest(http.MethodGet, "/artifacts/"+name, nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	req = httptest.NewRequest(
+		http.MethodPut,
+		"/artifacts/"+name,
+		strings.NewReader("Foobaz"),
+	)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(
+		http.MethodPut,
+		"/artifacts/"+name,
+		strings.NewReader("Foobar"),
+	)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, name, rec.Body.String())
+
+	req = httptest.NewRequest(
+		http.MethodPut,
+		"/artifacts/"+name,
+		strings.NewReader("Foobar"),
+	)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/artifacts/"+name, nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Foobar", rec.Body.String())
+	assert.Equal(t, `"`+name+`"`, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	req = httptest.NewRequest(http.MethodGet, "/artifacts/md5-foobar", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, name[7:9], name[7:]),
+		[]byte("Corrupted"),
+		0644,
+	))
+
+	req = httptest.NewRequest(http.MethodGet, "/artifacts/"+name, nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
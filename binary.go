@@ -0,0 +1,315 @@
+package air
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// binaryField is a `[]byte` field with an "encoding" struct tag, such as
+// `encoding:"base64,max=1048576"`.
+//
+// The supported encodings are the "base64" (the standard or the URL-safe
+// alphabet, with or without padding), the "datauri" (the RFC 2397 data URIs
+// such as the "data:image/png;base64,iVBORw0KGgo=") and the "hex". The "max"
+// caps the number of the decoded bytes.
+type binaryField struct {
+	index    []int
+	name     string
+	encoding string
+	max      int
+}
+
+// binaryFields returns the `binaryField`s of the struct type t and its nested
+// structs. The names of the fields are keyed by the keyer.
+func binaryFields(
+	t reflect.Type,
+	keyer func(reflect.StructField) string,
+) ([]*binaryField, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var bfs []*binaryField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" { // Unexported
+			continue
+		}
+
+		name := keyer(sf)
+		if name == "" {
+			continue
+		}
+
+		bf, err := newBinaryField(sf, name)
+		if err != nil {
+			return nil, err
+		} else if bf != nil {
+			bf.index = []int{i}
+			bfs = append(bfs, bf)
+			continue
+		}
+
+		nbfs, err := binaryFields(sf.Type, keyer)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, nbf := range nbfs {
+			nbf.index = append([]int{i}, nbf.index...)
+			if !sf.Anonymous {
+				nbf.name = name + "." + nbf.name
+			}
+		}
+
+		bfs = append(bfs, nbfs...)
+	}
+
+	return bfs, nil
+}
+
+// newBinaryField returns a new instance of the `binaryField` named the name
+// for the sf. It returns nil if the sf has no "encoding" struct tag.
+func newBinaryField(sf reflect.StructField, name string) (*binaryField, error) {
+	tag := sf.Tag.Get("encoding")
+	if tag == "" {
+		return nil, nil
+	} else if sf.Type != reflect.TypeOf([]byte(nil)) {
+		return nil, fmt.Errorf(
+			"encoding tag of %s requires []byte",
+			sf.Name,
+		)
+	}
+
+	bf := &binaryField{
+		name: name,
+	}
+	for i, o := range strings.Split(tag, ",") {
+		if i == 0 {
+			bf.encoding = o
+		} else if strings.HasPrefix(o, "max=") {
+			var err error
+			if bf.max, err = strconv.Atoi(o[4:]); err != nil {
+				return nil, fmt.Errorf(
+					"invalid encoding tag %q",
+					tag,
+				)
+			}
+		}
+	}
+
+	switch bf.encoding {
+	case "base64", "datauri", "hex":
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", bf.encoding)
+	}
+
+	return bf, nil
+}
+
+// decode decodes the s by using the `bf.encoding`. It returns an error with
+// the status code of the res set to the 413 if the `bf.max` is exceeded.
+func (bf *binaryField) decode(s string, res *Response) ([]byte, error) {
+	// The n is a lower bound of the decoded length, so that the oversized
+	// values are rejected before being decoded.
+	var (
+		n   int
+		dec func(string) ([]byte, error)
+	)
+	switch bf.encoding {
+	case "base64":
+		n = base64.RawStdEncoding.DecodedLen(len(s)) - 2
+		dec = decodeBase64
+	case "datauri":
+		n = (len(s) - strings.IndexByte(s, ',') - 1) / 3
+		dec = decodeDataURI
+	case "hex":
+		n = hex.DecodedLen(len(s))
+		dec = hex.DecodeString
+	}
+
+	errTooLarge := fmt.Errorf("%s too large", bf.name)
+	if bf.max > 0 && n > bf.max {
+		res.Status = http.StatusRequestEntityTooLarge
+		return nil, errTooLarge
+	}
+
+	b, err := dec(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s of %s", bf.encoding, bf.name)
+	} else if bf.max > 0 && len(b) > bf.max {
+		res.Status = http.StatusRequestEntityTooLarge
+		return nil, errTooLarge
+	}
+
+	return b, nil
+}
+
+// set sets the b to the field of the bf of the struct rv. The nil pointers on
+// the way are allocated.
+func (bf *binaryField) set(rv reflect.Value, b []byte) {
+	for _, i := range bf.index {
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+
+			rv = rv.Elem()
+		}
+
+		rv = rv.Field(i)
+	}
+
+	rv.SetBytes(b)
+}
+
+// decodeBase64 decodes the s in any of the base64 alphabets and paddings.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// decodeDataURI decodes the data of the RFC 2397 data URI s.
+func decodeDataURI(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "data:") {
+		return nil, errors.New("not a data uri")
+	}
+
+	i := strings.IndexByte(s, ',')
+	if i < 0 {
+		return nil, errors.New("not a data uri")
+	}
+
+	if strings.HasSuffix(s[:i], ";base64") {
+		return decodeBase64(s[i+1:])
+	}
+
+	d, err := url.PathUnescape(s[i+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(d), nil
+}
+
+// jsonFieldName returns the name of the sf in the JSON. It returns "" if the
+// sf is ignored.
+func jsonFieldName(sf reflect.StructField) string {
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	} else if name == "" {
+		name = sf.Name
+	}
+
+	return name
+}
+
+// unmarshalJSONWithBinaries unmarshals the JSON b into the v by using the js,
+// with the `binaryField`s of the v decoded from their JSON strings.
+func unmarshalJSONWithBinaries(
+	js JSONSerializer,
+	b []byte,
+	v interface{},
+	res *Response,
+) error {
+	bfs, err := binaryFields(reflect.TypeOf(v), jsonFieldName)
+	if err != nil {
+		return err
+	} else if len(bfs) == 0 {
+		return js.Unmarshal(b, v)
+	}
+
+	// The numbers are kept as the `json.Number`s, so that they are
+	// re-encoded as is without losing precision.
+	var m map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return js.Unmarshal(b, v)
+	}
+
+	bs := make([][]byte, len(bfs))
+	for i, bf := range bfs {
+		s, ok := takeJSONString(m, bf.name)
+		if !ok {
+			continue
+		}
+
+		if bs[i], err = bf.decode(s, res); err != nil {
+			return err
+		}
+	}
+
+	if b, err = json.Marshal(m); err != nil {
+		return err
+	}
+
+	if err := js.Unmarshal(b, v); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	for i, bf := range bfs {
+		if bs[i] != nil {
+			bf.set(rv, bs[i])
+		}
+	}
+
+	return nil
+}
+
+// takeJSONString takes the string at the dotted path out of the JSON object m.
+// The keys are matched case-insensitively as the `json.Unmarshal()` does.
+func takeJSONString(m map[string]interface{}, path string) (string, bool) {
+	ks := strings.Split(path, ".")
+	for i, k := range ks {
+		mk, ok := k, false
+		if _, ok = m[k]; !ok {
+			for k2 := range m {
+				if strings.EqualFold(k2, k) {
+					mk, ok = k2, true
+					break
+				}
+			}
+		}
+
+		if !ok {
+			return "", false
+		}
+
+		if i < len(ks)-1 {
+			if m, ok = m[mk].(map[string]interface{}); !ok {
+				return "", false
+			}
+
+			continue
+		}
+
+		s, ok := m[mk].(string)
+		if ok {
+			delete(m, mk)
+		}
+
+		return s, ok
+	}
+
+	return "", false
+}
@@ -0,0 +1,110 @@
+package air
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindBinary(t *testing.T) {
+	type image struct {
+		Data []byte `json:"data" encoding:"datauri"`
+	}
+
+	var v struct {
+		Blob   []byte `json:"blob" encoding:"base64,max=8"`
+		Digest []byte `encoding:"hex"`
+		Image  *image `json:"image"`
+		Raw    []byte `json:"raw"`
+		ID     int64  `json:"id"`
+	}
+
+	a := New()
+	a.ErrorHandler = func(err error, req *Request, res *Response) {
+		res.WriteString(err.Error())
+	}
+
+	a.POST("/", func(req *Request, res *Response) error {
+		return req.Bind(&v)
+	})
+
+	a.GET("/", func(req *Request, res *Response) error {
+		return req.Bind(&v)
+	})
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		strings.NewReader(`{
+	"blob": "Zm9vYmFy",
+	"digest": "cafe",
+	"image": {"data": "data:text/plain,hello%20world"},
+	"raw": "YmF6",
+	"id": 9007199254740993
+}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, []byte("foobar"), v.Blob)
+	assert.Equal(t, []byte{0xca, 0xfe}, v.Digest)
+	assert.Equal(t, []byte("hello world"), v.Image.Data)
+	assert.Equal(t, []byte("baz"), v.Raw)
+	assert.Equal(t, int64(9007199254740993), v.ID)
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		strings.NewReader(`{"blob": "Zm9vYmFyYmF6cXV4"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, "blob too large", rec.Body.String())
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		strings.NewReader(`{"digest": "xyz"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "invalid hex of Digest", rec.Body.String())
+
+	v.Blob, v.Image = nil, nil
+	req = httptest.NewRequest(
+		http.MethodGet,
+		"/?Blob=Zm9v_w&Digest=beef&Raw=qux",
+		nil,
+	)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, []byte{'f', 'o', 'o', 0xff}, v.Blob)
+	assert.Equal(t, []byte{0xbe, 0xef}, v.Digest)
+	assert.Equal(t, []byte("qux"), v.Raw)
+}
+
+func TestDecodeDataURI(t *testing.T) {
+	b, err := decodeDataURI("data:image/png;base64,iVBORw==")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, b)
+
+	b, err = decodeDataURI("data:,a%2Cb")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a,b"), b)
+
+	_, err = decodeDataURI("iVBORw==")
+	assert.Equal(t, errors.New("not a data uri"), err)
+
+	_, err = decodeDataURI("data:image/png;base64")
+	assert.Equal(t, errors.New("not a data uri"), err)
+}
@@ -1,7 +1,6 @@
 package air
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"io/ioutil"
@@ -29,7 +28,11 @@ func newBinder(a *Air) *binder {
 // bind binds the r into the v.
 func (b *binder) bind(v interface{}, r *Request) error {
 	if r.Method == http.MethodGet {
-		return b.bindParams(v, r.Params())
+		if err := b.bindParams(v, r.Params(), r.res); err != nil {
+			return err
+		}
+
+		return shape(reflect.ValueOf(v))
 	} else if r.Body == nil {
 		return errors.New("request body cannot be empty")
 	}
@@ -41,7 +44,15 @@ func (b *binder) bind(v interface{}, r *Request) error {
 
 	switch mt {
 	case "application/json":
-		err = json.NewDecoder(r.Body).Decode(v)
+		var b []byte
+		if b, err = ioutil.ReadAll(r.Body); err == nil {
+			err = unmarshalJSONWithBinaries(
+				r.Air.JSONSerializer,
+				b,
+				v,
+				r.res,
+			)
+		}
 	case "application/xml":
 		err = xml.NewDecoder(r.Body).Decode(v)
 	case "application/msgpack", "application/x-msgpack":
@@ -54,7 +65,7 @@ func (b *binder) bind(v interface{}, r *Request) error {
 	case "application/toml", "application/x-toml":
 		_, err = toml.DecodeReader(r.Body, v)
 	case "application/x-www-form-urlencoded", "multipart/form-data":
-		err = b.bindParams(v, r.Params())
+		err = b.bindParams(v, r.Params(), r.res)
 	default:
 		r.res.Status = http.StatusUnsupportedMediaType
 		return errors.New(http.StatusText(r.res.Status))
@@ -64,11 +75,16 @@ func (b *binder) bind(v interface{}, r *Request) error {
 		return err
 	}
 
-	return nil
+	return shape(reflect.ValueOf(v))
 }
 
-// bindParams binds the params into the v.
-func (b *binder) bindParams(v interface{}, params []*RequestParam) error {
+// bindParams binds the params into the v. The status code of the res is set
+// to the 413 if a `[]byte` field exceeds its size cap.
+func (b *binder) bindParams(
+	v interface{},
+	params []*RequestParam,
+	res *Response,
+) error {
 	t := reflect.TypeOf(v).Elem()
 	if t.Kind() != reflect.Struct {
 		return errors.New("binding element must be a struct")
@@ -83,7 +99,11 @@ func (b *binder) bindParams(v interface{}, params []*RequestParam) error {
 
 		vfk := vf.Kind()
 		if vfk == reflect.Struct {
-			err := b.bindParams(vf.Addr().Interface(), params)
+			err := b.bindParams(
+				vf.Addr().Interface(),
+				params,
+				res,
+			)
 			if err != nil {
 				return err
 			}
@@ -128,6 +148,25 @@ func (b *binder) bindParams(v interface{}, params []*RequestParam) error {
 			vf.SetFloat(f64)
 		case reflect.String:
 			vf.SetString(pv.String())
+		case reflect.Slice:
+			if tf.Type.Elem().Kind() != reflect.Uint8 {
+				return errors.New("unknown type")
+			}
+
+			bf, err := newBinaryField(tf, tf.Name)
+			if err != nil {
+				return err
+			} else if bf == nil {
+				vf.SetBytes([]byte(pv.String()))
+				continue
+			}
+
+			bs, err := bf.decode(pv.String(), res)
+			if err != nil {
+				return err
+			}
+
+			vf.SetBytes(bs)
 		default:
 			return errors.New("unknown type")
 		}
@@ -0,0 +1,375 @@
+package air
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerState is the state of a circuit of the `CircuitBreaker`.
+type CircuitBreakerState uint8
+
+// The circuit breaker states.
+const (
+	// CircuitBreakerClosed defines the closed state of a circuit, in which
+	// the requests are passed through.
+	CircuitBreakerClosed CircuitBreakerState = iota
+
+	// CircuitBreakerOpen defines the open state of a circuit, in which the
+	// requests are rejected or served by the fallback.
+	CircuitBreakerOpen
+
+	// CircuitBreakerHalfOpen defines the half-open state of a circuit, in
+	// which a single probe request is passed through to decide whether the
+	// circuit should be closed or opened again.
+	CircuitBreakerHalfOpen
+)
+
+// String returns the string value of the cbs.
+func (cbs CircuitBreakerState) String() string {
+	switch cbs {
+	case CircuitBreakerOpen:
+		return "open"
+	case CircuitBreakerHalfOpen:
+		return "half_open"
+	}
+
+	return "closed"
+}
+
+// CircuitBreaker is a circuit breaker that stops the requests to the routes
+// that keep failing (such as the ones whose upstreams are down) for a while,
+// so that the failures do not pile up and the upstreams can recover. Each
+// route has its own circuit, keyed by the method and the path of the route,
+// such as the "GET /search". The requests that match no route share the
+// circuit keyed by the "unmatched".
+//
+// The circuits can be controlled with the `CircuitBreaker#Trip()` and the
+// `CircuitBreaker#Reset()`, inspected and controlled through the
+// `CircuitBreaker#AdminHandler()`, and exposed by the `MetricsGas()` with the
+// `MetricsGasConfig.CircuitBreakers`.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of the consecutive failures that
+	// opens a circuit.
+	//
+	// The default value is 5.
+	FailureThreshold int
+
+	// OpenTimeout is the duration for which a circuit stays open before a
+	// probe request is passed through.
+	//
+	// The default value is 30 seconds.
+	OpenTimeout time.Duration
+
+	// Failed reports whether a request has failed with the err.
+	//
+	// The default value reports whether the err is not nil or the status
+	// code of the res is greater than or equal to 500.
+	Failed func(req *Request, res *Response, err error) bool
+
+	// Fallback is the `Handler` that serves the requests while their
+	// circuits are open, such as one that serves a degraded response or a
+	// cached one.
+	//
+	// If it is nil, those requests will be rejected with the 503 status
+	// code.
+	Fallback Handler
+
+	mutex    sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is a circuit of a route of the `CircuitBreaker`.
+type circuit struct {
+	state      CircuitBreakerState
+	tripped    bool
+	probing    bool
+	failures   int
+	openedAt   time.Time
+	rejections uint64
+}
+
+// NewCircuitBreaker returns a new instance of the `CircuitBreaker` with the
+// default field values.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		Failed:           defaultCircuitBreakerFailed,
+		circuits:         map[string]*circuit{},
+	}
+}
+
+// defaultCircuitBreakerFailed is the default `CircuitBreaker#Failed`.
+func defaultCircuitBreakerFailed(req *Request, res *Response, err error) bool {
+	return err != nil || res.Status >= http.StatusInternalServerError
+}
+
+// Gas returns a `Gas` that guards the routes with the circuits of the cb. It
+// is meant to be used per route or per group, such as
+// `a.GET("/search", h, cb.Gas())`, since the routes are not matched before
+// the `Air#Gases`.
+//
+// The zero fields of the cb are set to their default values (see the
+// `NewCircuitBreaker()`).
+func (cb *CircuitBreaker) Gas() Gas {
+	cb.mutex.Lock()
+	if cb.FailureThreshold <= 0 {
+		cb.FailureThreshold = 5
+	}
+
+	if cb.OpenTimeout <= 0 {
+		cb.OpenTimeout = 30 * time.Second
+	}
+
+	if cb.Failed == nil {
+		cb.Failed = defaultCircuitBreakerFailed
+	}
+
+	cb.mutex.Unlock()
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			// The unmatched requests share a single circuit, so
+			// that they do not grow the circuits without bound.
+			route := "unmatched"
+			if req.route != nil {
+				route = req.Method + " " + req.route.Path
+			}
+
+			probe, ok := cb.allow(route)
+			if !ok {
+				if cb.Fallback != nil {
+					return cb.Fallback(req, res)
+				}
+
+				res.Status = http.StatusServiceUnavailable
+
+				return errors.New(http.StatusText(res.Status))
+			}
+
+			// A panic is recorded as a failure, so that the
+			// half-open circuit is not left probing forever.
+			failed := true
+			defer func() {
+				cb.done(route, probe, failed)
+			}()
+
+			err := next(req, res)
+			failed = cb.Failed(req, res, err)
+
+			return err
+		}
+	}
+}
+
+// allow reports whether a request to the route is allowed, and whether it is
+// the probe request of the half-open circuit of the route.
+func (cb *CircuitBreaker) allow(route string) (probe, ok bool) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	c := cb.circuit(route)
+	if c.state == CircuitBreakerOpen && !c.tripped &&
+		time.Since(c.openedAt) >= cb.OpenTimeout {
+		c.state = CircuitBreakerHalfOpen
+	}
+
+	switch c.state {
+	case CircuitBreakerClosed:
+		return false, true
+	case CircuitBreakerHalfOpen:
+		if !c.probing {
+			c.probing = true
+			return true, true
+		}
+	}
+
+	c.rejections++
+
+	return false, false
+}
+
+// done records the result of a request to the route.
+func (cb *CircuitBreaker) done(route string, probe, failed bool) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	c := cb.circuit(route)
+	if probe {
+		c.probing = false
+	}
+
+	if c.tripped || (c.state != CircuitBreakerClosed && !probe) {
+		return
+	}
+
+	if !failed {
+		c.state = CircuitBreakerClosed
+		c.failures = 0
+		return
+	}
+
+	c.failures++
+	if probe || c.failures >= cb.FailureThreshold {
+		c.state = CircuitBreakerOpen
+		c.openedAt = time.Now()
+	}
+}
+
+// circuit returns the circuit of the route. It must be called with the
+// `cb.mutex` held.
+func (cb *CircuitBreaker) circuit(route string) *circuit {
+	if cb.circuits == nil {
+		cb.circuits = map[string]*circuit{}
+	}
+
+	c, ok := cb.circuits[route]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[route] = c
+	}
+
+	return c
+}
+
+// State returns the state of the circuit of the route, such as the
+// "GET /search".
+func (cb *CircuitBreaker) State(route string) CircuitBreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if c, ok := cb.circuits[route]; ok {
+		return c.state
+	}
+
+	return CircuitBreakerClosed
+}
+
+// Trip opens the circuit of the route manually. It stays open until the
+// `cb#Reset()` is called.
+func (cb *CircuitBreaker) Trip(route string) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	c := cb.circuit(route)
+	c.state = CircuitBreakerOpen
+	c.tripped = true
+	c.openedAt = time.Now()
+}
+
+// Reset closes the circuit of the route manually.
+func (cb *CircuitBreaker) Reset(route string) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	c := cb.circuit(route)
+	c.state = CircuitBreakerClosed
+	c.tripped = false
+	c.failures = 0
+}
+
+// AdminHandler returns a `Handler` that serves the circuits of the cb in JSON,
+// such as `[{"route":"GET /search","state":"open",...}]`. The POST requests
+// with the "route" and the "action" ("trip" or "reset") params control the
+// circuits by using the `cb#Trip()` and the `cb#Reset()`.
+//
+// It should be protected, such as by the `InternalGas()`.
+func (cb *CircuitBreaker) AdminHandler() Handler {
+	return func(req *Request, res *Response) error {
+		if req.Method == http.MethodPost {
+			var route, action string
+			if p := req.Param("route"); p != nil {
+				route = p.Value().String()
+			}
+
+			if p := req.Param("action"); p != nil {
+				action = p.Value().String()
+			}
+
+			switch {
+			case route == "":
+				res.Status = http.StatusBadRequest
+				return errors.New("missing route")
+			case action == "trip":
+				cb.Trip(route)
+			case action == "reset":
+				cb.Reset(route)
+			default:
+				res.Status = http.StatusBadRequest
+				return fmt.Errorf("unknown action %q", action)
+			}
+		}
+
+		cb.mutex.Lock()
+		defer cb.mutex.Unlock()
+
+		cs := make([]map[string]interface{}, 0, len(cb.circuits))
+		for _, route := range cb.routes() {
+			c := cb.circuits[route]
+			m := map[string]interface{}{
+				"route":      route,
+				"state":      c.state.String(),
+				"tripped":    c.tripped,
+				"failures":   c.failures,
+				"rejections": c.rejections,
+			}
+			if c.state != CircuitBreakerClosed {
+				m["opened_at"] = c.openedAt.UTC()
+			}
+
+			cs = append(cs, m)
+		}
+
+		return res.WriteJSON(cs)
+	}
+}
+
+// routes returns the sorted routes of the circuits of the cb. It must be called
+// with the `cb.mutex` held.
+func (cb *CircuitBreaker) routes() []string {
+	routes := make([]string, 0, len(cb.circuits))
+	for route := range cb.circuits {
+		routes = append(routes, route)
+	}
+
+	sort.Strings(routes)
+
+	return routes
+}
+
+// exposeStates writes the "circuit_breaker_state" samples of the circuits of
+// the cb to the buf.
+func (cb *CircuitBreaker) exposeStates(buf *bytes.Buffer) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	for _, route := range cb.routes() {
+		fmt.Fprintf(
+			buf,
+			"circuit_breaker_state{route=\"%s\"} %d\n",
+			escapeMetricsLabelValue(route),
+			cb.circuits[route].state,
+		)
+	}
+}
+
+// exposeRejections writes the "circuit_breaker_rejections_total" samples of
+// the circuits of the cb to the buf.
+func (cb *CircuitBreaker) exposeRejections(buf *bytes.Buffer) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	for _, route := range cb.routes() {
+		fmt.Fprintf(
+			buf,
+			"circuit_breaker_rejections_total{route=\"%s\"} %d\n",
+			escapeMetricsLabelValue(route),
+			cb.circuits[route].rejections,
+		)
+	}
+}
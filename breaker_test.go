@@ -0,0 +1,226 @@
+package air
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := NewCircuitBreaker()
+	cb.FailureThreshold = 2
+	cb.OpenTimeout = 50 * time.Millisecond
+
+	zcb := &CircuitBreaker{}
+
+	a := New()
+	a.Pregases = []Gas{MetricsGas(MetricsGasConfig{
+		CircuitBreakers: []*CircuitBreaker{cb, zcb},
+	})}
+
+	failing := true
+	a.GET("/search", func(req *Request, res *Response) error {
+		if failing {
+			return errors.New("upstream down")
+		}
+
+		return res.WriteString("results")
+	}, cb.Gas())
+
+	a.GET("/pages", func(req *Request, res *Response) error {
+		return errors.New("upstream down")
+	}, zcb.Gas())
+
+	a.GET("/breakers", cb.AdminHandler())
+	a.POST("/breakers", cb.AdminHandler())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/breakers",
+			strings.NewReader(form.Encode()),
+		)
+		req.Header.Set(
+			"Content-Type",
+			"application/x-www-form-urlencoded",
+		)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, get("/search").Code)
+	assert.Equal(t, CircuitBreakerClosed, cb.State("GET /search"))
+	assert.Equal(t, http.StatusInternalServerError, get("/search").Code)
+	assert.Equal(t, CircuitBreakerOpen, cb.State("GET /search"))
+	assert.Equal(t, http.StatusServiceUnavailable, get("/search").Code)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(
+			t,
+			http.StatusInternalServerError,
+			get("/pages?page=1").Code,
+		)
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/pages").Code)
+	assert.Equal(t, CircuitBreakerOpen, zcb.State("GET /pages"))
+
+	rec := get("/metrics")
+	assert.Contains(
+		t,
+		rec.Body.String(),
+		"circuit_breaker_state{route=\"GET /search\"} 1\n",
+	)
+	assert.Contains(
+		t,
+		rec.Body.String(),
+		"circuit_breaker_state{route=\"GET /pages\"} 1\n",
+	)
+	assert.Contains(
+		t,
+		rec.Body.String(),
+		"circuit_breaker_rejections_total{route=\"GET /search\"} 1\n",
+	)
+	assert.Equal(
+		t,
+		1,
+		strings.Count(
+			rec.Body.String(),
+			"# TYPE circuit_breaker_state gauge\n",
+		),
+	)
+
+	time.Sleep(60 * time.Millisecond)
+
+	assert.Equal(t, http.StatusInternalServerError, get("/search").Code)
+	assert.Equal(t, CircuitBreakerOpen, cb.State("GET /search"))
+
+	time.Sleep(60 * time.Millisecond)
+
+	failing = false
+	assert.Equal(t, http.StatusOK, get("/search").Code)
+	assert.Equal(t, CircuitBreakerClosed, cb.State("GET /search"))
+
+	rec = post(url.Values{"route": {"GET /search"}, "action": {"trip"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"state":"open"`)
+	assert.Contains(t, rec.Body.String(), `"tripped":true`)
+
+	time.Sleep(60 * time.Millisecond)
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/search").Code)
+
+	cb.Fallback = func(req *Request, res *Response) error {
+		return res.WriteString("cached results")
+	}
+
+	rec = get("/search")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "cached results", rec.Body.String())
+
+	rec = post(url.Values{"route": {"GET /search"}, "action": {"reset"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"state":"closed"`)
+
+	rec = get("/search")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "results", rec.Body.String())
+
+	rec = post(url.Values{"route": {"GET /search"}, "action": {"open"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = post(url.Values{"action": {"trip"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = get("/breakers")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"route":"GET /search"`)
+}
+
+func TestCircuitBreakerPanic(t *testing.T) {
+	cb := NewCircuitBreaker()
+	cb.FailureThreshold = 1
+	cb.OpenTimeout = 10 * time.Millisecond
+
+	a := New()
+	recovering := func(next Handler) Handler {
+		return func(req *Request, res *Response) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+
+				res.Status = http.StatusInternalServerError
+				err = fmt.Errorf("%v", r)
+			}()
+
+			return next(req, res)
+		}
+	}
+
+	a.Pregases = []Gas{recovering}
+
+	panicking := true
+	a.GET("/search", func(req *Request, res *Response) error {
+		if panicking {
+			panic("upstream down")
+		}
+
+		return res.WriteString("results")
+	}, cb.Gas())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, get("/search").Code)
+	assert.Equal(t, CircuitBreakerOpen, cb.State("GET /search"))
+
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, http.StatusInternalServerError, get("/search").Code)
+	assert.Equal(t, CircuitBreakerOpen, cb.State("GET /search"))
+
+	time.Sleep(20 * time.Millisecond)
+
+	panicking = false
+	assert.Equal(t, http.StatusOK, get("/search").Code)
+	assert.Equal(t, CircuitBreakerClosed, cb.State("GET /search"))
+}
+
+func TestCircuitBreakerUnmatched(t *testing.T) {
+	cb := NewCircuitBreaker()
+
+	a := New()
+	a.Gases = []Gas{cb.Gas()}
+
+	for _, p := range []string{"/foo", "/bar?baz=qux", "/foo/bar"} {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	assert.Equal(t, []string{"unmatched"}, cb.routes())
+}
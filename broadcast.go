@@ -0,0 +1,253 @@
+package air
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BroadcastMessage is a message published by the `Air#Publish()`.
+type BroadcastMessage struct {
+	// Topic is the topic that the message is published to.
+	Topic string
+
+	// Data is the data of the message.
+	Data string
+}
+
+// Subscription is a subscription to the topics of the messages published by
+// the `Air#Publish()`. It is created by the `Air#Subscribe()`.
+type Subscription struct {
+	messages chan *BroadcastMessage
+	topics   []string
+	hub      *broadcastHub
+	evicted  bool
+	closed   bool
+}
+
+// Messages returns the channel of the messages published to the topics of the
+// s. It is closed when the s is closed or evicted.
+func (s *Subscription) Messages() <-chan *BroadcastMessage {
+	return s.messages
+}
+
+// Evicted reports whether the s has been evicted as a slow consumer, which
+// means that it fell behind the `Air#BroadcastBufferSize` messages.
+func (s *Subscription) Evicted() bool {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	return s.evicted
+}
+
+// Close closes the s. After one call to it, subsequent calls have no effect.
+func (s *Subscription) Close() {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	s.hub.remove(s)
+}
+
+// broadcastHub is the hub of the `Subscription`s of an `Air`.
+type broadcastHub struct {
+	a             *Air
+	mutex         sync.Mutex
+	subscriptions map[string]map[*Subscription]struct{}
+}
+
+// newBroadcastHub returns a new instance of the `broadcastHub` with the a.
+func newBroadcastHub(a *Air) *broadcastHub {
+	return &broadcastHub{
+		a:             a,
+		subscriptions: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+// remove removes the s from the bh and closes it. It must be called with the
+// `bh.mutex` held.
+func (bh *broadcastHub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	for _, t := range s.topics {
+		delete(bh.subscriptions[t], s)
+		if len(bh.subscriptions[t]) == 0 {
+			delete(bh.subscriptions, t)
+		}
+	}
+
+	close(s.messages)
+}
+
+// Subscribe returns a new `Subscription` to the topics.
+func (a *Air) Subscribe(topics ...string) *Subscription {
+	size := a.BroadcastBufferSize
+	if size <= 0 {
+		size = 64
+	}
+
+	s := &Subscription{
+		messages: make(chan *BroadcastMessage, size),
+		topics:   topics,
+		hub:      a.broadcastHub,
+	}
+
+	a.broadcastHub.mutex.Lock()
+	defer a.broadcastHub.mutex.Unlock()
+
+	for _, t := range topics {
+		ss, ok := a.broadcastHub.subscriptions[t]
+		if !ok {
+			ss = map[*Subscription]struct{}{}
+			a.broadcastHub.subscriptions[t] = ss
+		}
+
+		ss[s] = struct{}{}
+	}
+
+	return s
+}
+
+// Publish publishes the msg to all the subscribers of the topic, such as the
+// clients of the `Response#SubscribeSSE()` and the
+// `Response#SubscribeWebSocket()`, and returns the number of the subscribers
+// that it is queued for. It never blocks, since the subscribers that cannot
+// keep up are evicted.
+func (a *Air) Publish(topic, msg string) int {
+	bm := &BroadcastMessage{
+		Topic: topic,
+		Data:  msg,
+	}
+
+	a.broadcastHub.mutex.Lock()
+	defer a.broadcastHub.mutex.Unlock()
+
+	n := 0
+	for s := range a.broadcastHub.subscriptions[topic] {
+		select {
+		case s.messages <- bm:
+			n++
+		default:
+			s.evicted = true
+			a.broadcastHub.remove(s)
+		}
+	}
+
+	return n
+}
+
+// SubscribeSSE responds to the client with the messages published to the
+// topics as the server-sent events, whose event types are their topics, until
+// the client goes away or falls behind. A comment is sent every 15 seconds to
+// keep the connection alive.
+func (r *Response) SubscribeSSE(topics ...string) error {
+	s := r.Air.Subscribe(topics...)
+	defer s.Close()
+
+	r.Header.Set("Content-Type", "text/event-stream")
+	r.Header.Set("Cache-Control", "no-cache")
+	r.Header.Set("X-Accel-Buffering", "no")
+	if err := r.Flush(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.req.Context.Done():
+			return nil
+		case bm, ok := <-s.Messages():
+			if !ok {
+				return nil
+			}
+
+			err = writeSSE(r.Body, bm)
+		case <-ticker.C:
+			_, err = io.WriteString(r.Body, ": keep-alive\n\n")
+		}
+
+		if err == nil {
+			err = r.Flush()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sseLineBreakReplacer is the `strings.Replacer` that replaces all the line
+// breaks of the server-sent events (the "\r\n", "\r" and "\n") with the "\n".
+var sseLineBreakReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// writeSSE writes the bm to the w as a server-sent event. The data of the bm is
+// split into the data fields on all the line breaks of the server-sent events,
+// and the topic of the bm is rejected if it has any of them, since they would
+// inject the fields into the event.
+func writeSSE(w io.Writer, bm *BroadcastMessage) error {
+	if strings.ContainsAny(bm.Topic, "\r\n") {
+		return errors.New("air: invalid server-sent event type")
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "event: %s\n", bm.Topic)
+	data := sseLineBreakReplacer.Replace(bm.Data)
+	for _, l := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", l)
+	}
+
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// SubscribeWebSocket switches the connection to the WebSocket protocol and
+// sends the messages published to the topics as the text messages, until the
+// client goes away or falls behind. The clients that fall behind are closed
+// with the 1013 (try again later) close code, so that they can reconnect.
+func (r *Response) SubscribeWebSocket(topics ...string) error {
+	ws, err := r.WebSocket()
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	s := r.Air.Subscribe(topics...)
+	defer s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		ws.Listen()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-r.req.Context.Done():
+			return nil
+		case bm, ok := <-s.Messages():
+			if !ok {
+				return ws.WriteConnectionClose(
+					websocket.CloseTryAgainLater,
+					"slow consumer",
+				)
+			}
+
+			if err := ws.WriteText(bm.Data); err != nil {
+				return err
+			}
+		}
+	}
+}
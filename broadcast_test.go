@@ -0,0 +1,140 @@
+package air
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirPublish(t *testing.T) {
+	a := New()
+	a.BroadcastBufferSize = 2
+
+	s1 := a.Subscribe("foo", "bar")
+	s2 := a.Subscribe("foo")
+
+	assert.Equal(t, 2, a.Publish("foo", "1"))
+	assert.Equal(t, 1, a.Publish("bar", "2"))
+	assert.Equal(t, 0, a.Publish("baz", "3"))
+
+	bm := <-s1.Messages()
+	assert.Equal(t, "foo", bm.Topic)
+	assert.Equal(t, "1", bm.Data)
+
+	// The s2 is not read, so it falls behind on the third message.
+	assert.Equal(t, 2, a.Publish("foo", "4"))
+
+	bm = <-s1.Messages()
+	assert.Equal(t, "bar", bm.Topic)
+
+	assert.Equal(t, 1, a.Publish("foo", "5"))
+	assert.False(t, s1.Evicted())
+	assert.True(t, s2.Evicted())
+
+	for range []int{1, 4} {
+		_, ok := <-s2.Messages()
+		assert.True(t, ok)
+	}
+
+	_, ok := <-s2.Messages()
+	assert.False(t, ok)
+
+	s1.Close()
+	s1.Close()
+	assert.Equal(t, 0, a.Publish("foo", "6"))
+	assert.Empty(t, a.broadcastHub.subscriptions)
+}
+
+func TestResponseSubscribeSSE(t *testing.T) {
+	a := New()
+	a.GET("/events", func(req *Request, res *Response) error {
+		return res.SubscribeSSE("news")
+	})
+
+	hs := httptest.NewServer(a)
+	defer hs.Close()
+
+	hres, err := http.Get(hs.URL + "/events")
+	assert.NoError(t, err)
+	defer hres.Body.Close()
+	assert.Equal(
+		t,
+		"text/event-stream",
+		hres.Header.Get("Content-Type"),
+	)
+
+	for a.Publish("news", "foo\nbar") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	br := bufio.NewReader(hres.Body)
+	var lines []string
+	for len(lines) < 4 {
+		l, err := br.ReadString('\n')
+		assert.NoError(t, err)
+		lines = append(lines, l)
+	}
+
+	assert.Equal(
+		t,
+		"event: news\ndata: foo\ndata: bar\n\n",
+		strings.Join(lines, ""),
+	)
+}
+
+func TestWriteSSE(t *testing.T) {
+	b := strings.Builder{}
+	assert.NoError(t, writeSSE(&b, &BroadcastMessage{
+		Topic: "news",
+		Data:  "foo\r\nbar\rid: 1\nbaz",
+	}))
+	assert.Equal(
+		t,
+		"event: news\ndata: foo\ndata: bar\ndata: id: 1\ndata: baz\n\n",
+		b.String(),
+	)
+
+	b.Reset()
+	assert.Error(t, writeSSE(&b, &BroadcastMessage{
+		Topic: "news\rid: 1",
+		Data:  "foo",
+	}))
+	assert.Error(t, writeSSE(&b, &BroadcastMessage{
+		Topic: "news\ndata: bar",
+		Data:  "foo",
+	}))
+	assert.Empty(t, b.String())
+}
+
+func TestResponseSubscribeWebSocket(t *testing.T) {
+	a := New()
+	a.BroadcastBufferSize = 1
+	a.GET("/ws", func(req *Request, res *Response) error {
+		return res.SubscribeWebSocket("news")
+	})
+
+	hs := httptest.NewServer(a)
+	defer hs.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(hs.URL, "http")+"/ws",
+		nil,
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	for a.Publish("news", "foo") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	mt, b, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, mt)
+	assert.Equal(t, "foo", string(b))
+}
@@ -0,0 +1,63 @@
+// Command airscaffold generates a runnable starter project of the Air by using
+// the `air.Scaffold()`.
+//
+// Usage:
+//
+//	airscaffold [-module path] [-name name] [-overwrite] dir
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aofei/air"
+)
+
+func main() {
+	so := air.ScaffoldOptions{}
+	flag.StringVar(
+		&so.ModulePath,
+		"module",
+		"",
+		"the module path of the project (default the base name of dir)",
+	)
+	flag.StringVar(
+		&so.AppName,
+		"name",
+		"",
+		"the app name of the project (default the last element of "+
+			"module)",
+	)
+	flag.BoolVar(
+		&so.Overwrite,
+		"overwrite",
+		false,
+		"overwrite the existing files",
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(
+			flag.CommandLine.Output(),
+			"usage: airscaffold [-module path] [-name name] "+
+				"[-overwrite] dir",
+		)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := flag.Arg(0)
+	if err := air.Scaffold(dir, so); err != nil {
+		fmt.Fprintln(os.Stderr, "airscaffold:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf(
+		"generated %s, run \"go mod tidy\" and \"go run .\" in it\n",
+		dir,
+	)
+}
@@ -0,0 +1,204 @@
+package air
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ConcurrencyGasConfig is a set of configurations for the `ConcurrencyGas()`.
+type ConcurrencyGasConfig struct {
+	// MaxConcurrency is the maximum number of the requests that are
+	// processed at the same time.
+	//
+	// It must be greater than zero.
+	MaxConcurrency int
+
+	// MaxQueueLength is the maximum number of the requests that wait for
+	// being processed. The requests beyond it are rejected with the 503
+	// status code.
+	//
+	// If it is less than or equal to zero, the `MaxConcurrency` will be
+	// used.
+	MaxQueueLength int
+
+	// QueueTimeout is the maximum duration that a request waits for being
+	// processed. The requests that time out are rejected with the 503
+	// status code.
+	//
+	// If it is zero, the requests wait until they are canceled.
+	QueueTimeout time.Duration
+
+	// Priority returns the `Priority` that a request is scheduled by, such
+	// as one whose urgency is capped by the plan of the authenticated
+	// user. The `(*Request).Priority` can be used to trust the "Priority"
+	// header of the clients, which should only be done when all of them
+	// are trusted, since any client can signal the "u=0" to have the
+	// requests of the others rejected.
+	//
+	// If it is nil, the `Priority` carried by the `Request#Context` (see
+	// the `ContextWithPriority()`) or the `DefaultPriority` will be used,
+	// so the "Priority" header is ignored.
+	Priority func(req *Request) Priority
+}
+
+// ConcurrencyGas returns a `Gas` that limits the number of the requests it
+// processes at the same time with the cgc, so that the bursts of traffic queue
+// up instead of overloading the instance.
+//
+// The waiting requests are processed in the order of their urgencies (see the
+// `cgc.Priority`), and then in the order of their arrivals. When the
+// queue is full, the least urgent waiting request is rejected in favor of a
+// more urgent one.
+//
+// It panics if the `cgc.MaxConcurrency` is not greater than zero.
+func ConcurrencyGas(cgc ConcurrencyGasConfig) Gas {
+	if cgc.MaxConcurrency <= 0 {
+		panic("air: concurrency gas max concurrency must be positive")
+	}
+
+	if cgc.MaxQueueLength <= 0 {
+		cgc.MaxQueueLength = cgc.MaxConcurrency
+	}
+
+	cl := &concurrencyLimiter{
+		max:            cgc.MaxConcurrency,
+		maxQueueLength: cgc.MaxQueueLength,
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			ctx := req.Context
+			if cgc.QueueTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(
+					ctx,
+					cgc.QueueTimeout,
+				)
+				defer cancel()
+			}
+
+			err := cl.acquire(ctx, cgc.priority(req).Urgency)
+			if err != nil {
+				res.Status = http.StatusServiceUnavailable
+				return errors.New(http.StatusText(res.Status))
+			}
+			defer cl.release()
+
+			return next(req, res)
+		}
+	}
+}
+
+// priority returns the `Priority` that the req is scheduled by.
+func (cgc ConcurrencyGasConfig) priority(req *Request) Priority {
+	if cgc.Priority != nil {
+		return cgc.Priority(req)
+	}
+
+	if p, ok := PriorityFromContext(req.Context); ok {
+		return p
+	}
+
+	return DefaultPriority
+}
+
+// errConcurrencyQueueFull is the error of the requests that are rejected since
+// the queue of a `concurrencyLimiter` is full.
+var errConcurrencyQueueFull = errors.New("air: concurrency queue is full")
+
+// concurrencyLimiter is the limiter of a `ConcurrencyGas()`.
+type concurrencyLimiter struct {
+	mutex          sync.Mutex
+	max            int
+	maxQueueLength int
+	active         int
+	waiters        []*concurrencyWaiter
+}
+
+// concurrencyWaiter is a waiter of a `concurrencyLimiter`.
+type concurrencyWaiter struct {
+	urgency int
+	ready   chan error
+}
+
+// acquire waits until a request of the urgency can be processed, or until the
+// ctx is done.
+func (cl *concurrencyLimiter) acquire(ctx context.Context, urgency int) error {
+	cl.mutex.Lock()
+	if cl.active < cl.max && len(cl.waiters) == 0 {
+		cl.active++
+		cl.mutex.Unlock()
+		return nil
+	}
+
+	if len(cl.waiters) >= cl.maxQueueLength {
+		last := cl.waiters[len(cl.waiters)-1]
+		if last.urgency <= urgency {
+			cl.mutex.Unlock()
+			return errConcurrencyQueueFull
+		}
+
+		cl.waiters = cl.waiters[:len(cl.waiters)-1]
+		last.ready <- errConcurrencyQueueFull
+	}
+
+	w := &concurrencyWaiter{
+		urgency: urgency,
+		ready:   make(chan error, 1),
+	}
+
+	i := len(cl.waiters)
+	for i > 0 && cl.waiters[i-1].urgency > urgency {
+		i--
+	}
+
+	cl.waiters = append(cl.waiters, nil)
+	copy(cl.waiters[i+1:], cl.waiters[i:])
+	cl.waiters[i] = w
+	cl.mutex.Unlock()
+
+	select {
+	case err := <-w.ready:
+		return err
+	case <-ctx.Done():
+	}
+
+	cl.mutex.Lock()
+	for i, cw := range cl.waiters {
+		if cw == w {
+			cl.waiters = append(cl.waiters[:i], cl.waiters[i+1:]...)
+			cl.mutex.Unlock()
+			return ctx.Err()
+		}
+	}
+
+	cl.mutex.Unlock()
+
+	// The w has been either admitted or rejected in the meantime.
+	if err := <-w.ready; err != nil {
+		return err
+	}
+
+	cl.release()
+
+	return ctx.Err()
+}
+
+// release releases a request acquired by the `cl.acquire()` and admits the
+// next waiting one (if any).
+func (cl *concurrencyLimiter) release() {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+
+	if len(cl.waiters) == 0 {
+		cl.active--
+		return
+	}
+
+	w := cl.waiters[0]
+	cl.waiters = cl.waiters[1:]
+	w.ready <- nil
+}
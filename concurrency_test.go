@@ -0,0 +1,129 @@
+package air
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyGas(t *testing.T) {
+	assert.Panics(t, func() {
+		ConcurrencyGas(ConcurrencyGasConfig{})
+	})
+
+	a := New()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	a.GET("/slow", func(req *Request, res *Response) error {
+		close(started)
+		<-release
+		return res.WriteString("slow")
+	}, ConcurrencyGas(ConcurrencyGasConfig{
+		MaxConcurrency: 1,
+		QueueTimeout:   20 * time.Millisecond,
+	}))
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- get()
+	}()
+
+	<-started
+
+	assert.Equal(t, http.StatusServiceUnavailable, get().Code)
+
+	close(release)
+
+	rec := <-done
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "slow", rec.Body.String())
+}
+
+func TestConcurrencyGasConfigPriority(t *testing.T) {
+	req := &Request{
+		Header:  http.Header{"Priority": []string{"u=0"}},
+		Context: context.Background(),
+	}
+
+	cgc := ConcurrencyGasConfig{}
+	assert.Equal(t, DefaultPriority, cgc.priority(req))
+
+	p := Priority{Urgency: 1}
+	req.Context = ContextWithPriority(req.Context, p)
+	assert.Equal(t, p, cgc.priority(req))
+
+	cgc.Priority = (*Request).Priority
+	assert.Equal(t, p, cgc.priority(req))
+
+	req.Context = context.Background()
+	assert.Equal(t, Priority{}, cgc.priority(req))
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	cl := &concurrencyLimiter{
+		max:            1,
+		maxQueueLength: 2,
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, cl.acquire(ctx, 3))
+
+	order := make(chan int, 3)
+	errs := make(chan error, 3)
+	enqueue := func(urgency int) {
+		go func() {
+			err := cl.acquire(ctx, urgency)
+			if err == nil {
+				order <- urgency
+				cl.release()
+			}
+
+			errs <- err
+		}()
+	}
+
+	waitQueueLength := func(n int) {
+		for {
+			cl.mutex.Lock()
+			l := len(cl.waiters)
+			cl.mutex.Unlock()
+			if l == n {
+				return
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	enqueue(5)
+	waitQueueLength(1)
+	enqueue(3)
+	waitQueueLength(2)
+
+	// The queue is full, so the less urgent ones are rejected.
+	assert.Equal(t, errConcurrencyQueueFull, cl.acquire(ctx, 6))
+
+	enqueue(1)
+	assert.Equal(t, errConcurrencyQueueFull, <-errs)
+
+	cl.release()
+	for _, u := range []int{1, 3} {
+		assert.Equal(t, u, <-order)
+		assert.NoError(t, <-errs)
+	}
+
+	assert.Zero(t, cl.active)
+	assert.Empty(t, cl.waiters)
+}
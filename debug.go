@@ -0,0 +1,84 @@
+package air
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// debugTrace records the gases and the handlers executed for a request. See
+// the `Air#DebugTraceEnabled`.
+type debugTrace struct {
+	stages []*debugTraceStage
+}
+
+// debugTraceStage is a stage of the `debugTrace`.
+type debugTraceStage struct {
+	name     string
+	started  time.Time
+	duration time.Duration
+}
+
+// wrap returns the h wrapped to be recorded as a stage named after the f, which
+// is either a `Gas` or a `Handler`. It returns the h as is if the dt is nil.
+func (dt *debugTrace) wrap(f interface{}, h Handler) Handler {
+	if dt == nil {
+		return h
+	}
+
+	name := funcName(f)
+
+	return func(req *Request, res *Response) error {
+		dts := &debugTraceStage{
+			name:    name,
+			started: time.Now(),
+		}
+		dt.stages = append(dt.stages, dts)
+
+		err := h(req, res)
+		dts.duration = time.Since(dts.started)
+
+		return err
+	}
+}
+
+// String returns the string representation of the dt, which is in the format
+// of the "Server-Timing" header. The durations of the unfinished stages are
+// measured up to now.
+func (dt *debugTrace) String() string {
+	ss := make([]string, 0, len(dt.stages))
+	for _, dts := range dt.stages {
+		d := dts.duration
+		if d == 0 {
+			d = time.Since(dts.started)
+		}
+
+		ss = append(ss, fmt.Sprintf(
+			"%s;dur=%.2f",
+			dts.name,
+			float64(d)/float64(time.Millisecond),
+		))
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+// funcName returns the short name of the function f with its package path and
+// the suffixes of its closures trimmed, such as the "air.LoggerGas" for the
+// `Gas` returned by the `LoggerGas()`.
+func funcName(f interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	name = name[strings.LastIndexByte(name, '/')+1:]
+	for {
+		i := strings.LastIndex(name, ".func")
+		if i < 0 || strings.Trim(name[i+5:], "0123456789.") != "" {
+			break
+		}
+
+		name = name[:i]
+	}
+
+	return name
+}
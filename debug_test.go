@@ -0,0 +1,45 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDebugTraceHandler(req *Request, res *Response) error {
+	return res.WriteString("Foobar")
+}
+
+func TestDebugTrace(t *testing.T) {
+	a := New()
+	a.DebugMode = true
+	a.DebugTraceEnabled = true
+	a.Pregases = []Gas{InternalGas(InternalGasConfig{
+		AllowedNetworks: []string{"192.0.2.0/24"},
+	})}
+	a.Gases = []Gas{ETagGas(ETagGasConfig{})}
+	a.GET("/", testDebugTraceHandler, TracingGas(TracingGasConfig{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Regexp(
+		t,
+		regexp.MustCompile(`^air\.InternalGas;dur=[0-9.]+, `+
+			`air\.ETagGas;dur=[0-9.]+, `+
+			`air\.TracingGas;dur=[0-9.]+, `+
+			`air\.testDebugTraceHandler;dur=[0-9.]+$`),
+		rec.Header().Get("X-Air-Trace"),
+	)
+
+	a.DebugMode = false
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get("X-Air-Trace"))
+}
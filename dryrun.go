@@ -0,0 +1,160 @@
+package air
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// DryRunGasConfig is a set of configurations for the `DryRunGas()`.
+type DryRunGasConfig struct {
+	// Methods is the methods of the mutating requests that can be dry run.
+	//
+	// If it is nil, the ["POST", "PUT", "PATCH", "DELETE"] will be used.
+	Methods []string
+
+	// Transaction begins the transaction that a dry-run request is handled
+	// in, and returns the function that rolls it back, which is always
+	// called after the request is handled. A dry-run request whose
+	// transaction fails to begin is not handled.
+	//
+	// If it is nil, only the dry-run requests that are `Honored` are
+	// handled.
+	Transaction func(req *Request) (rollback func() error, err error)
+
+	// Honored reports whether the handler of the req enforces the dry runs
+	// itself, such as by skipping the mutations when the
+	// `Request#DryRun()` is true. It is consulted only if the `Transaction`
+	// is nil.
+	//
+	// If it is nil, no handler is considered to enforce the dry runs.
+	Honored func(req *Request) bool
+}
+
+// DryRunGas returns a `Gas` that validates the "Dry-Run" header of every
+// request it processes with the drgc. A request with the "Dry-Run: true" is
+// handled normally, but its `Request#Context` is marked as dry run, which means
+// that it must be treated as read-only: the transaction-per-request gases must
+// always roll back, and the other side effects (such as sending e-mails) must
+// be skipped. It lets the clients preview the effects of the mutations safely.
+//
+// The dry runs must be enforced, either by the `drgc.Transaction` or by the
+// handlers reported by the `drgc.Honored`, otherwise the dry-run requests are
+// rejected with the 501 rather than being handled with real side effects. The
+// dry-run responses carry the "Dry-Run: true" header, so that the clients know
+// the header has been honored. The requests with invalid "Dry-Run" headers,
+// and the dry-run requests with methods out of the `drgc.Methods`, are
+// rejected with the 400.
+//
+// See the `DryRunFromContext()`.
+func DryRunGas(drgc DryRunGasConfig) Gas {
+	if drgc.Methods == nil {
+		drgc.Methods = []string{
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			h := req.Header.Get("Dry-Run")
+			if h == "" {
+				return next(req, res)
+			}
+
+			dryRun, err := strconv.ParseBool(h)
+			if err != nil {
+				res.Status = http.StatusBadRequest
+				return errors.New("invalid dry-run header")
+			} else if !dryRun {
+				return next(req, res)
+			}
+
+			if !stringSliceContains(drgc.Methods, req.Method) {
+				res.Status = http.StatusBadRequest
+				return errors.New("unsupported dry-run method")
+			}
+
+			req.Context = context.WithValue(
+				req.Context,
+				dryRunKey{},
+				&dryRunMark{
+					res: res,
+				},
+			)
+
+			if drgc.Transaction == nil {
+				if drgc.Honored == nil || !drgc.Honored(req) {
+					res.Status = http.StatusNotImplemented
+					return errors.New(
+						"dry run not supported",
+					)
+				}
+
+				res.Header.Set("Dry-Run", "true")
+
+				return next(req, res)
+			}
+
+			rollback, err := drgc.Transaction(req)
+			if err != nil {
+				res.Status = http.StatusInternalServerError
+				return err
+			}
+
+			res.Header.Set("Dry-Run", "true")
+
+			err = next(req, res)
+			if rerr := rollback(); err == nil {
+				err = rerr
+			}
+
+			return err
+		}
+	}
+}
+
+// dryRunKey is the key of the dry-run mark in a `context.Context`.
+type dryRunKey struct{}
+
+// dryRunMark is the dry-run mark in a `context.Context`.
+type dryRunMark struct {
+	res *Response
+}
+
+// ContextWithDryRun returns a copy of the ctx which is marked as dry run.
+func ContextWithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, &dryRunMark{})
+}
+
+// DryRunFromContext reports whether the ctx is marked as dry run, which means
+// that the mutations made under the ctx must be rolled back.
+func DryRunFromContext(ctx context.Context) bool {
+	_, ok := ctx.Value(dryRunKey{}).(*dryRunMark)
+	return ok
+}
+
+// ConfirmDryRun confirms that the dry run of the ctx has been enforced, so that
+// the response of the ctx carries the "Dry-Run: true" header. It must be called
+// before the response is written, and does nothing if the ctx is not marked as
+// dry run by the `DryRunGas()`.
+func ConfirmDryRun(ctx context.Context) {
+	dr, ok := ctx.Value(dryRunKey{}).(*dryRunMark)
+	if ok && dr.res != nil && !dr.res.Written {
+		dr.res.Header.Set("Dry-Run", "true")
+	}
+}
+
+// DryRun reports whether the r is a dry-run request. See the `DryRunGas()`.
+func (r *Request) DryRun() bool {
+	return DryRunFromContext(r.Context)
+}
+
+// ConfirmDryRun confirms that the dry run of the r has been enforced. See the
+// `ConfirmDryRun()`.
+func (r *Request) ConfirmDryRun() {
+	ConfirmDryRun(r.Context)
+}
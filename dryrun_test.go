@@ -0,0 +1,131 @@
+package air
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunGas(t *testing.T) {
+	a := New()
+	a.Gases = []Gas{DryRunGas(DryRunGasConfig{
+		Honored: func(req *Request) bool {
+			return req.Method == http.MethodPost
+		},
+	})}
+
+	committed := 0
+	a.POST("/users", func(req *Request, res *Response) error {
+		if !req.DryRun() {
+			committed++
+		}
+
+		return res.WriteString("Foobar")
+	})
+	a.PUT("/users", func(req *Request, res *Response) error {
+		committed++
+		return res.WriteString("Foobar")
+	})
+	a.GET("/users", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set("Dry-Run", "true")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Dry-Run"))
+	assert.Equal(t, "Foobar", rec.Body.String())
+	assert.Equal(t, 0, committed)
+
+	for _, h := range []string{"", "false"} {
+		req = httptest.NewRequest(http.MethodPost, "/users", nil)
+		req.Header.Set("Dry-Run", h)
+		rec = httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Dry-Run"))
+	}
+
+	assert.Equal(t, 2, committed)
+
+	req = httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set("Dry-Run", "maybe")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Dry-Run", "true")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 2, committed)
+
+	// The dry runs that would not be enforced are rejected.
+	req = httptest.NewRequest(http.MethodPut, "/users", nil)
+	req.Header.Set("Dry-Run", "true")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	assert.Empty(t, rec.Header().Get("Dry-Run"))
+	assert.Equal(t, 2, committed)
+
+	a = New()
+	a.Gases = []Gas{DryRunGas(DryRunGasConfig{})}
+	a.POST("/users", func(req *Request, res *Response) error {
+		committed++
+		return res.WriteString("Foobar")
+	})
+
+	req = httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set("Dry-Run", "true")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	assert.Equal(t, 2, committed)
+
+	rollbacks := 0
+	a = New()
+	a.Gases = []Gas{DryRunGas(DryRunGasConfig{
+		Transaction: func(req *Request) (func() error, error) {
+			if req.Header.Get("X-Fail") != "" {
+				return nil, errors.New("failed to begin")
+			}
+
+			return func() error {
+				rollbacks++
+				return nil
+			}, nil
+		},
+	})}
+	a.PUT("/users", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	req = httptest.NewRequest(http.MethodPut, "/users", nil)
+	req.Header.Set("Dry-Run", "true")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Dry-Run"))
+	assert.Equal(t, 1, rollbacks)
+
+	req = httptest.NewRequest(http.MethodPut, "/users", nil)
+	req.Header.Set("Dry-Run", "true")
+	req.Header.Set("X-Fail", "true")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get("Dry-Run"))
+	assert.Equal(t, 1, rollbacks)
+
+	ctx := ContextWithDryRun(context.Background())
+	assert.True(t, DryRunFromContext(ctx))
+	assert.False(t, DryRunFromContext(context.Background()))
+}
@@ -0,0 +1,155 @@
+package air
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HTTPError is an error with an HTTP status code. Handlers can return it to
+// control the status code of the error response.
+type HTTPError struct {
+	// Code is the HTTP status code.
+	Code int
+
+	// Message is the message that is safe to be shown to the client.
+	Message string
+
+	// Internal is the underlying error. It is only shown to the client in
+	// the debug mode.
+	Internal error
+
+	// ErrorCode is the machine-readable code of the error, such as the
+	// "USER_NOT_FOUND". It is looked up in the `Air#ErrorCatalog` for the
+	// status code, the message and the documentation URL that are not set
+	// by the error itself.
+	ErrorCode string
+}
+
+// NewHTTPError returns a new instance of the `HTTPError` with the code and the
+// message. The `http.StatusText()` of the code will be used if the message is
+// empty.
+func NewHTTPError(code int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+
+	return &HTTPError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WrapHTTPError returns a new instance of the `HTTPError` with the code and the
+// internal error err. Its message is the `http.StatusText()` of the code.
+func WrapHTTPError(code int, err error) *HTTPError {
+	he := NewHTTPError(code, "")
+	he.Internal = err
+	return he
+}
+
+// NewCodedError returns a new instance of the `HTTPError` with the error code
+// code, whose status code and message are taken from the `Air#ErrorCatalog`
+// when it is handled.
+func NewCodedError(code string) *HTTPError {
+	return &HTTPError{
+		ErrorCode: code,
+	}
+}
+
+// Error implements the `error`.
+func (he *HTTPError) Error() string {
+	if he.Message == "" {
+		return he.ErrorCode
+	}
+
+	return he.Message
+}
+
+// Unwrap returns the `he.Internal`.
+func (he *HTTPError) Unwrap() error {
+	return he.Internal
+}
+
+// status returns the status code of the he from the a. It falls back to the
+// `Air#ErrorCatalog` and then to the 500.
+func (he *HTTPError) status(a *Air) int {
+	if he.Code != 0 {
+		return he.Code
+	} else if ece := a.ErrorCatalog[he.ErrorCode]; ece != nil &&
+		ece.Status != 0 {
+		return ece.Status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// message returns the message of the he for the req. It falls back to the
+// localized string for the key "errors.<ErrorCode>", the message in the
+// `Air#ErrorCatalog` and then to the `http.StatusText()` of the status code.
+func (he *HTTPError) message(req *Request) string {
+	if he.Message != "" || he.ErrorCode == "" {
+		return he.Message
+	}
+
+	key := "errors." + he.ErrorCode
+	if m := req.LocalizedString(key); m != key {
+		return m
+	} else if ece := req.Air.ErrorCatalog[he.ErrorCode]; ece != nil &&
+		ece.Message != "" {
+		return ece.Message
+	}
+
+	return http.StatusText(he.status(req.Air))
+}
+
+// ErrorCatalogEntry is an entry of the `Air#ErrorCatalog`.
+type ErrorCatalogEntry struct {
+	// Status is the HTTP status code of the error.
+	Status int `json:"status"`
+
+	// Message is the default message of the error. It is overridden by the
+	// localized string for the key "errors.<code>" if the
+	// `Air#I18nEnabled` is true.
+	Message string `json:"message"`
+
+	// DocumentationURL is the URL of the documentation of the error. It is
+	// used as the "type" member of the problem details.
+	DocumentationURL string `json:"documentation_url"`
+}
+
+// errorPage returns the HTML template of the error page for the status code
+// from the a. It returns empty if not found.
+func errorPage(a *Air, status int) string {
+	code := strconv.Itoa(status)
+	if et, ok := a.ErrorPages[code]; ok {
+		return et
+	} else if et, ok := a.ErrorPages[code[:1]+"xx"]; ok {
+		return et
+	}
+
+	return a.ErrorTemplate
+}
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code,omitempty"`
+
+	// Errors is the messages of the invalid fields, see the
+	// `ValidationErrors`.
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+// acceptsProblemJSON reports whether the client of the req prefers JSON error
+// responses to HTML ones.
+func acceptsProblemJSON(req *Request) bool {
+	a := req.Header.Get("Accept")
+	return !strings.Contains(a, "text/html") &&
+		(strings.Contains(a, "application/problem+json") ||
+			strings.Contains(a, "application/json"))
+}
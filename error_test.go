@@ -0,0 +1,207 @@
+package air
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestNotFound = errors.New("not found in database")
+
+func TestHTTPError(t *testing.T) {
+	he := NewHTTPError(http.StatusTeapot, "")
+	assert.Equal(t, http.StatusTeapot, he.Code)
+	assert.Equal(t, http.StatusText(http.StatusTeapot), he.Error())
+
+	he = WrapHTTPError(http.StatusBadGateway, errTestNotFound)
+	assert.Equal(t, http.StatusText(http.StatusBadGateway), he.Error())
+	assert.True(t, errors.Is(he, errTestNotFound))
+
+	a := New()
+	a.ErrorMapper = func(err error) error {
+		if err == errTestNotFound {
+			return WrapHTTPError(http.StatusNotFound, err)
+		}
+
+		return err
+	}
+	a.GET("/conflict", func(req *Request, res *Response) error {
+		return NewHTTPError(http.StatusConflict, "Foobar")
+	})
+	a.GET("/missing", func(req *Request, res *Response) error {
+		return errTestNotFound
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/conflict", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "Foobar", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(
+		t,
+		"application/problem+json",
+		rec.Header().Get("Content-Type"),
+	)
+
+	p := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, "about:blank", p["type"])
+	assert.Equal(t, "Not Found", p["title"])
+	assert.Equal(t, float64(http.StatusNotFound), p["status"])
+	assert.Equal(t, "Not Found", p["detail"])
+	assert.Equal(t, "/missing", p["instance"])
+
+	a.DebugMode = true
+
+	req = httptest.NewRequest(http.MethodGet, "/missing", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Not Found: not found in database", rec.Body.String())
+}
+
+func TestDefaultErrorHandlerErrorPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestDefaultErrorHandlerErrorPages")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"error.html": `error {{.Status}}`,
+		"404.html":   `{{.Path}} not found`,
+		"5xx.html":   `{{.Title}}`,
+	} {
+		assert.NoError(t, ioutil.WriteFile(
+			filepath.Join(dir, name),
+			[]byte(content),
+			0644,
+		))
+	}
+
+	a := New()
+	a.TemplateRoot = dir
+	a.ErrorTemplate = "error.html"
+	a.ErrorPages = map[string]string{
+		"404": "404.html",
+		"5xx": "5xx.html",
+	}
+	a.GET("/conflict", func(req *Request, res *Response) error {
+		return NewHTTPError(http.StatusConflict, "")
+	})
+	a.GET("/unavailable", func(req *Request, res *Response) error {
+		return NewHTTPError(http.StatusServiceUnavailable, "")
+	})
+
+	for path, body := range map[string]string{
+		"/conflict":    "error 409",
+		"/missing":     "/missing not found",
+		"/unavailable": "Service Unavailable",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, body, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(
+		t,
+		"application/problem+json",
+		rec.Header().Get("Content-Type"),
+	)
+}
+
+func TestNewCodedError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestNewCodedError")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "en-US.toml"),
+		[]byte(`hello = "Hello"`),
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "zh-CN.toml"),
+		[]byte(`
+[errors]
+USER_NOT_FOUND = "用户不存在"
+`),
+		0644,
+	))
+
+	a := New()
+	a.I18nEnabled = true
+	a.LocaleRoot = dir
+	a.ErrorCatalog = map[string]*ErrorCatalogEntry{
+		"USER_NOT_FOUND": {
+			Status:           http.StatusNotFound,
+			Message:          "User not found",
+			DocumentationURL: "https://example.com/errors/404",
+		},
+	}
+	a.GET("/users/:id", func(req *Request, res *Response) error {
+		return NewCodedError("USER_NOT_FOUND")
+	})
+	a.GET("/unknown", func(req *Request, res *Response) error {
+		return NewCodedError("UNKNOWN")
+	})
+
+	assert.Equal(
+		t,
+		"USER_NOT_FOUND",
+		NewCodedError("USER_NOT_FOUND").Error(),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	p := problem{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, "https://example.com/errors/404", p.Type)
+	assert.Equal(t, "USER_NOT_FOUND", p.Code)
+	assert.Equal(t, "User not found", p.Detail)
+
+	req = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Language", "zh-CN")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	p = problem{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, "USER_NOT_FOUND", p.Code)
+	assert.Equal(t, "用户不存在", p.Detail)
+
+	req = httptest.NewRequest(http.MethodGet, "/unknown", nil)
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	p = problem{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, "about:blank", p.Type)
+	assert.Equal(t, "UNKNOWN", p.Code)
+}
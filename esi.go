@@ -0,0 +1,417 @@
+package air
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ESIGasConfig is a set of configurations for the `ESIGas()`.
+type ESIGasConfig struct {
+	// UpstreamHosts is the hosts (such as the "fragments.example.com") of
+	// the absolute URLs that are allowed to be included. The absolute URLs
+	// of other hosts fail to be included.
+	UpstreamHosts []string
+
+	// Client is the client used to fetch the absolute URLs.
+	//
+	// If it is nil, a client with a 10-second timeout will be used.
+	Client *http.Client
+
+	// MaxBodyBytes is the maximum number of bytes of the message body of a
+	// response to be processed.
+	//
+	// If it is less than or equal to zero, 1 MiB will be used.
+	MaxBodyBytes int
+
+	// MaxEntries is the maximum number of the cached fragments. A random
+	// fragment is evicted to make room for a new one when it is reached.
+	//
+	// If it is less than or equal to zero, 1000 will be used.
+	MaxEntries int
+}
+
+// ESIGas returns a `Gas` that processes the Edge Side Includes in the HTML
+// responses of the requests it processes with the egc, so that the pages with
+// personalized sections can be composed of the fragments cached separately.
+//
+// The `<esi:include src="..." alt="..." onerror="continue"/>` tags are replaced
+// with the fragments of their "src" (or of their "alt" if the "src" fails). The
+// paths (such as the "/fragments/cart") are served by the `Air#SubRequest()`,
+// and the absolute URLs of the `egc.UpstreamHosts` are fetched by using the
+// `egc.Client`. The fragments that are successful and allowed to be cached in
+// shared caches with a "max-age" in their "Cache-Control" are cached for that
+// long. The tags whose fragments fail are removed, and the failures are logged
+// unless their "onerror" is "continue".
+//
+// The `<esi:remove>` elements are removed, and the `<!--esi ... -->` comments
+// are unwrapped.
+//
+// The responses are fully buffered before being sent, except the ones that are
+// flushed or are event streams, which are streamed without being processed.
+func ESIGas(egc ESIGasConfig) Gas {
+	if egc.Client == nil {
+		egc.Client = &http.Client{
+			Timeout: 10 * time.Second,
+		}
+	}
+
+	if egc.MaxBodyBytes <= 0 {
+		egc.MaxBodyBytes = 1 << 20
+	}
+
+	if egc.MaxEntries <= 0 {
+		egc.MaxEntries = 1000
+	}
+
+	mc := &memoizeCache{
+		maxEntries: egc.MaxEntries,
+		entries:    map[string]*memoizeEntry{},
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			rb := newResponseBuffer(res)
+			if rb == nil {
+				return next(req, res)
+			}
+
+			err := next(req, res)
+			mt, _, _ := mime.ParseMediaType(
+				rb.Header().Get("Content-Type"),
+			)
+			if !res.Written || rb.streaming ||
+				rb.status != http.StatusOK ||
+				mt != "text/html" {
+				if ferr := rb.flush(); err == nil {
+					err = ferr
+				}
+
+				return err
+			}
+
+			rb.complete()
+			if rb.body.Len() > egc.MaxBodyBytes {
+				if ferr := rb.flush(); err == nil {
+					err = ferr
+				}
+
+				return err
+			}
+
+			b, perr := egc.process(req, res, rb.body.Bytes(), mc)
+			if perr != nil {
+				req.Air.ERROR(
+					"air: failed to process esi",
+					map[string]interface{}{
+						"error": perr.Error(),
+					},
+				)
+			} else {
+				rb.body.Reset()
+				rb.body.Write(b)
+
+				h := rb.Header()
+				h.Del("ETag")
+				if h.Get("Content-Length") != "" {
+					h.Set(
+						"Content-Length",
+						strconv.Itoa(len(b)),
+					)
+				}
+			}
+
+			if ferr := rb.flush(); err == nil {
+				err = ferr
+			}
+
+			return err
+		}
+	}
+}
+
+// esiSegment is a segment of an HTML document that is either a literal or an
+// include.
+type esiSegment struct {
+	literal []byte
+	include *esiInclude
+}
+
+// esiInclude is an `<esi:include>` tag.
+type esiInclude struct {
+	src             string
+	alt             string
+	continueOnError bool
+}
+
+// process returns the HTML document b of the response of the req with its ESI
+// processed. The b is gzipped if the res is.
+func (egc ESIGasConfig) process(
+	req *Request,
+	res *Response,
+	b []byte,
+	mc *memoizeCache,
+) ([]byte, error) {
+	if res.Gzipped {
+		var err error
+		if b, err = gunzip(b); err != nil {
+			return nil, err
+		}
+	}
+
+	segs := parseESI(b)
+
+	p := req.Parallel(0)
+	fragments := make([][]byte, len(segs))
+	for i, seg := range segs {
+		if seg.include == nil {
+			continue
+		}
+
+		i, inc := i, seg.include
+		p.Go(func(ctx context.Context) error {
+			f, err := egc.fragment(req, inc.src, mc)
+			if err != nil && inc.alt != "" {
+				f, err = egc.fragment(req, inc.alt, mc)
+			}
+
+			if err != nil && !inc.continueOnError {
+				req.Air.WARN(
+					"air: failed to include esi fragment",
+					map[string]interface{}{
+						"src":   inc.src,
+						"error": err.Error(),
+					},
+				)
+			}
+
+			fragments[i] = f
+
+			return nil
+		})
+	}
+
+	p.Wait()
+
+	buf := bytes.Buffer{}
+	for i, seg := range segs {
+		if seg.include != nil {
+			buf.Write(fragments[i])
+		} else {
+			buf.Write(seg.literal)
+		}
+	}
+
+	if !res.Gzipped {
+		return buf.Bytes(), nil
+	}
+
+	gb := bytes.Buffer{}
+	gw, err := gzip.NewWriterLevel(&gb, req.Air.GzipCompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := gw.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return gb.Bytes(), nil
+}
+
+// fragment returns the fragment of the src for the req.
+func (egc ESIGasConfig) fragment(
+	req *Request,
+	src string,
+	mc *memoizeCache,
+) ([]byte, error) {
+	if e := mc.get(src); e != nil {
+		return e.body, nil
+	}
+
+	var (
+		status int
+		header http.Header
+		body   []byte
+	)
+	if strings.HasPrefix(src, "/") && !strings.HasPrefix(src, "//") {
+		sr, err := req.Air.SubRequest(req, http.MethodGet, src)
+		if err != nil {
+			return nil, err
+		}
+
+		status, header, body = sr.Status, sr.Header, sr.Body
+	} else {
+		u, err := url.Parse(src)
+		if err != nil {
+			return nil, err
+		} else if !stringSliceContains(egc.UpstreamHosts, u.Host) {
+			return nil, fmt.Errorf("host %q not allowed", u.Host)
+		}
+
+		hr, err := http.NewRequestWithContext(
+			req.Context,
+			http.MethodGet,
+			u.String(),
+			nil,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		hres, err := egc.Client.Do(hr)
+		if err != nil {
+			return nil, err
+		}
+		defer hres.Body.Close()
+
+		body, err = ioutil.ReadAll(io.LimitReader(
+			hres.Body,
+			int64(egc.MaxBodyBytes),
+		))
+		if err != nil {
+			return nil, err
+		}
+
+		status, header = hres.StatusCode, hres.Header
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", status)
+	}
+
+	if ma := sharedMaxAge(header); ma > 0 {
+		mc.set(src, &memoizeEntry{
+			body:    body,
+			expires: time.Now().Add(ma),
+		})
+	}
+
+	return body, nil
+}
+
+// parseESI parses the HTML document b into the `esiSegment`s.
+func parseESI(b []byte) []esiSegment {
+	var (
+		segs     []esiSegment
+		literal  []byte
+		removing int
+	)
+
+	z := html.NewTokenizer(bytes.NewReader(b))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		raw := append([]byte(nil), z.Raw()...)
+
+		var tn []byte
+		switch tt {
+		case html.StartTagToken,
+			html.SelfClosingTagToken,
+			html.EndTagToken:
+			tn, _ = z.TagName()
+		}
+
+		switch {
+		case string(tn) == "esi:remove":
+			if tt == html.StartTagToken {
+				removing++
+			} else if tt == html.EndTagToken && removing > 0 {
+				removing--
+			}
+
+			continue
+		case removing > 0, string(tn) == "esi:include" &&
+			tt == html.EndTagToken:
+			continue
+		case string(tn) == "esi:include":
+			inc := &esiInclude{}
+			for {
+				k, v, more := z.TagAttr()
+				switch string(k) {
+				case "src":
+					inc.src = string(v)
+				case "alt":
+					inc.alt = string(v)
+				case "onerror":
+					inc.continueOnError = string(v) ==
+						"continue"
+				}
+
+				if !more {
+					break
+				}
+			}
+
+			segs = append(segs, esiSegment{
+				literal: literal,
+			}, esiSegment{
+				include: inc,
+			})
+			literal = nil
+
+			continue
+		case tt == html.CommentToken:
+			if d := z.Text(); bytes.HasPrefix(d, []byte("esi")) {
+				segs = append(segs, esiSegment{
+					literal: literal,
+				})
+				segs = append(segs, parseESI(d[3:])...)
+				literal = nil
+
+				continue
+			}
+		}
+
+		literal = append(literal, raw...)
+	}
+
+	return append(segs, esiSegment{
+		literal: literal,
+	})
+}
+
+// sharedMaxAge returns the "max-age" of the "Cache-Control" of the h. It
+// returns zero if the h is not allowed to be cached in shared caches.
+func sharedMaxAge(h http.Header) time.Duration {
+	ma, sma := -1, -1
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		switch {
+		case d == "private", d == "no-store", d == "no-cache":
+			return 0
+		case strings.HasPrefix(d, "s-maxage="):
+			sma, _ = strconv.Atoi(d[9:])
+		case strings.HasPrefix(d, "max-age="):
+			ma, _ = strconv.Atoi(d[8:])
+		}
+	}
+
+	if sma >= 0 {
+		ma = sma
+	}
+
+	if ma <= 0 {
+		return 0
+	}
+
+	return time.Duration(ma) * time.Second
+}
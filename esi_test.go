@@ -0,0 +1,128 @@
+package air
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestESIGas(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		r *http.Request,
+	) {
+		rw.Write([]byte("<footer>upstream</footer>"))
+	}))
+	defer upstream.Close()
+
+	uu, _ := url.Parse(upstream.URL)
+
+	a := New()
+	a.Gases = []Gas{ESIGas(ESIGasConfig{
+		UpstreamHosts: []string{uu.Host},
+	})}
+
+	navs := 0
+	a.GET("/fragments/nav", func(req *Request, res *Response) error {
+		navs++
+		res.Header.Set("Cache-Control", "public, max-age=60")
+		return res.WriteHTML(fmt.Sprintf("<nav>%d</nav>", navs))
+	})
+
+	a.GET("/fragments/cart", func(req *Request, res *Response) error {
+		res.Header.Set("Cache-Control", "private, max-age=60")
+		return res.WriteHTML("<p>" + req.Header.Get("Cookie") + "</p>")
+	})
+
+	a.GET("/fragments/broken", func(req *Request, res *Response) error {
+		res.Status = http.StatusInternalServerError
+		return res.WriteString("broken")
+	})
+
+	a.GET("/fragments/fallback", func(req *Request, res *Response) error {
+		return res.WriteHTML("<i>fallback</i>")
+	})
+
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteHTML(`<html><body>` +
+			`<esi:include src="/fragments/nav"/>` +
+			`<esi:include src="/fragments/cart"></esi:include>` +
+			`<esi:include src="/fragments/broken" ` +
+			`alt="/fragments/fallback"/>` +
+			`<esi:include src="/missing" onerror="continue"/>` +
+			`<esi:include src="http://example.com/evil"/>` +
+			`<esi:include src="` + upstream.URL + `/"/>` +
+			`<esi:remove><a href="/nav">Nav</a></esi:remove>` +
+			`<!--esi <b>ESI</b>-->` +
+			`<!-- comment --></body></html>`)
+	})
+
+	a.GET("/text", func(req *Request, res *Response) error {
+		return res.WriteString(`<esi:include src="/fragments/nav"/>`)
+	})
+
+	want := `<html><body><nav>1</nav><p>%s</p><i>fallback</i>` +
+		`<footer>upstream</footer> <b>ESI</b><!-- comment -->` +
+		`</body></html>`
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", "a=b")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, fmt.Sprintf(want, "a=b"), rec.Body.String())
+	assert.Equal(t, 1, navs)
+
+	a.GzipEnabled = true
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", "c=d")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	gr, err := gzip.NewReader(rec.Body)
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(want, "c=d"), string(b))
+	assert.Equal(t, 1, navs)
+
+	req = httptest.NewRequest(http.MethodGet, "/text", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(
+		t,
+		`<esi:include src="/fragments/nav"/>`,
+		rec.Body.String(),
+	)
+}
+
+func TestSharedMaxAge(t *testing.T) {
+	for cc, want := range map[string]time.Duration{
+		"":                           0,
+		"max-age=60":                 time.Minute,
+		"public, max-age=60":         time.Minute,
+		"max-age=60, s-maxage=120":   2 * time.Minute,
+		"s-maxage=120, private":      0,
+		"no-store":                   0,
+		"max-age=60, no-cache":       0,
+		"max-age=invalid":            0,
+		"public, max-age=0, s-max=1": 0,
+	} {
+		h := http.Header{}
+		h.Set("Cache-Control", cc)
+		assert.Equal(t, want, sharedMaxAge(h), cc)
+	}
+}
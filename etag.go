@@ -0,0 +1,123 @@
+package air
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/cespare/xxhash"
+)
+
+// ETagGasConfig is a set of configurations for the `ETagGas()`.
+type ETagGasConfig struct {
+	// Weak indicates whether the ETags are weak, which means that the
+	// responses with the same ETag are semantically equivalent but not
+	// necessarily byte-for-byte identical.
+	Weak bool
+
+	// MaxBodyBytes is the maximum number of bytes of the message body of a
+	// response to compute the ETag over. The responses exceeding it are
+	// streamed without the ETag once they exceed it.
+	//
+	// If it is less than or equal to zero, 1 MiB will be used.
+	MaxBodyBytes int
+}
+
+// ETagGas returns a `Gas` that computes an ETag over the message body of every
+// successful GET response it processes with the egc, and responds with the 304
+// status code and an empty message body if it matches the "If-None-Match" of
+// the request. It reduces the bandwidth of the polling clients substantially.
+//
+// The responses that already have an ETag are left untouched, since their
+// conditional requests have been handled by the `Response#Write()`, as are the
+// ones that have a "Last-Modified" for the "If-Modified-Since".
+//
+// The responses are buffered before being sent until they exceed the
+// `egc.MaxBodyBytes` or are flushed, after which they are streamed without the
+// ETag. So are the event streams.
+func ETagGas(egc ETagGasConfig) Gas {
+	if egc.MaxBodyBytes <= 0 {
+		egc.MaxBodyBytes = 1 << 20
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			if req.Method != http.MethodGet {
+				return next(req, res)
+			}
+
+			rb := newResponseBuffer(res)
+			if rb == nil {
+				return next(req, res)
+			}
+
+			rb.maxBytes = egc.MaxBodyBytes
+
+			err := next(req, res)
+			if !res.Written || rb.streaming ||
+				rb.status != http.StatusOK ||
+				rb.Header().Get("ETag") != "" {
+				rb.flush()
+				return err
+			}
+
+			rb.complete()
+
+			d := xxhash.New()
+			d.Write(rb.body.Bytes())
+
+			et := `"` + base64.StdEncoding.EncodeToString(
+				d.Sum(nil),
+			) + `"`
+			if egc.Weak {
+				et = "W/" + et
+			}
+
+			h := rb.Header()
+			h.Set("ETag", et)
+			if etagMatch(req.Header.Get("If-None-Match"), et) {
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				rb.status = http.StatusNotModified
+				rb.body.Reset()
+			}
+
+			if ferr := rb.flush(); err == nil {
+				err = ferr
+			}
+
+			return err
+		}
+	}
+}
+
+// newETagGas returns a new `ETagGas()` built with the gs. It is registered as
+// "etag" in the gas factory registry.
+func newETagGas(gs GasSettings) (Gas, error) {
+	egc := ETagGasConfig{}
+
+	var err error
+	if egc.Weak, err = gs.Bool("weak"); err != nil {
+		return nil, err
+	}
+
+	if egc.MaxBodyBytes, err = gs.Int("max_body_bytes"); err != nil {
+		return nil, err
+	}
+
+	return ETagGas(egc), nil
+}
+
+// etagMatch reports whether the "If-None-Match" header value inm matches the
+// et by using the weak comparison. See RFC 7232, section 3.2.
+func etagMatch(inm, et string) bool {
+	et = strings.TrimPrefix(et, "W/")
+	for _, e := range strings.Split(inm, ",") {
+		e = strings.TrimSpace(e)
+		if e == "*" || strings.TrimPrefix(e, "W/") == et {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,133 @@
+package air
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETagGas(t *testing.T) {
+	a := New()
+	a.Gases = []Gas{ETagGas(ETagGasConfig{
+		MaxBodyBytes: 16,
+	})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteJSON(map[string]string{"foo": "bar"})
+	})
+	a.GET("/large", func(req *Request, res *Response) error {
+		return res.WriteString(strings.Repeat("a", 17))
+	})
+	var rec *httptest.ResponseRecorder
+	a.GET("/stream", func(req *Request, res *Response) error {
+		steps := 0
+		return res.Stream(func(w io.Writer) bool {
+			if rec.Body.Len() > 0 || steps == 3 {
+				return false
+			}
+
+			steps++
+			w.Write([]byte(strings.Repeat("a", 10)))
+
+			return true
+		})
+	})
+	var flushed string
+	a.GET("/flush", func(req *Request, res *Response) error {
+		if err := res.WriteString("Foo"); err != nil {
+			return err
+		}
+
+		res.Flush()
+		flushed = rec.Body.String()
+
+		return res.WriteString("bar")
+	})
+	a.GET("/events", func(req *Request, res *Response) error {
+		res.Header.Set("Content-Type", "text/event-stream")
+		return res.Write(strings.NewReader("data: foobar\n\n"))
+	})
+	a.GET("/etag", func(req *Request, res *Response) error {
+		res.Header.Set("ETag", `"foobar"`)
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"foo":"bar"}`, rec.Body.String())
+
+	et := rec.Header().Get("ETag")
+	assert.NotEmpty(t, et)
+	assert.False(t, strings.HasPrefix(et, "W/"))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"foo", W/`+et)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, et, rec.Header().Get("ETag"))
+	assert.Empty(t, rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"foo"`)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"foo":"bar"}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/large", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+	assert.Equal(t, strings.Repeat("a", 17), rec.Body.String())
+
+	// The flushed responses reach the client immediately.
+	req = httptest.NewRequest(http.MethodGet, "/stream", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+	assert.Equal(t, strings.Repeat("a", 10), rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/flush", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+	assert.Equal(t, "Foo", flushed)
+	assert.Equal(t, "Foobar", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+	assert.Equal(t, "data: foobar\n\n", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/etag", nil)
+	req.Header.Set("If-None-Match", `"foobar"`)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	a = New()
+	a.Gases = []Gas{ETagGas(ETagGasConfig{
+		Weak: true,
+	})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("ETag"), "W/"))
+}
@@ -0,0 +1,235 @@
+package air
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Export renders the GET routes for the urls (such as "/" and "/about") to the
+// static files in the dir by requesting them through the whole handler chain
+// of the a, so that the content sites built with the a can be deployed to
+// static hosting. Since the gases are performed as usual, the asset pipeline
+// (such as the minifier and the coffer) is honored.
+//
+// The URL paths ending with "/" or without extensions are exported as the
+// "index.html" in their directories if their responses are HTML. For example,
+// the "/about" is exported as the "about/index.html".
+//
+// If the crawl is true, the internal links found in the exported HTML
+// responses (the "href" and the "src" attributes) are exported as well. The
+// links that fail to be exported are logged instead of failing the whole
+// export.
+func (a *Air) Export(dir string, urls []string, crawl bool) error {
+	queue := append([]string(nil), urls...)
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		seen[u] = true
+	}
+
+	for i := 0; i < len(queue); i++ {
+		u := queue[i]
+
+		lrw, err := a.serveLocal(u)
+		if err == nil && lrw.status != http.StatusOK {
+			err = fmt.Errorf(
+				"unexpected status code %d",
+				lrw.status,
+			)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("failed to export %q: %v", u, err)
+			if i < len(urls) {
+				return err
+			}
+
+			a.WARN("air: " + err.Error())
+
+			continue
+		}
+
+		mt, _, _ := mime.ParseMediaType(lrw.header.Get("Content-Type"))
+		isHTML := mt == "text/html"
+
+		fn, _ := splitPathQuery(u)
+		if strings.HasSuffix(fn, "/") {
+			fn += "index.html"
+		} else if isHTML && path.Ext(fn) == "" {
+			fn += "/index.html"
+		}
+
+		if fn, err = url.PathUnescape(fn); err != nil {
+			return err
+		}
+
+		fn = filepath.Join(dir, filepath.FromSlash(path.Clean("/"+fn)))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(fn, lrw.body.Bytes(), 0644)
+		if err != nil {
+			return err
+		}
+
+		if !crawl || !isHTML {
+			continue
+		}
+
+		for _, l := range internalLinks(u, lrw.body.Bytes()) {
+			if !seen[l] {
+				seen[l] = true
+				queue = append(queue, l)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WarmUp requests the GET routes for the urls (such as "/" and "/about")
+// through the whole handler chain of the a in process to populate the caches
+// (such as the coffer, the HTML templates and the response caches of the gases)
+// and avoid the cold-start latency spikes after deploys. It can be called at
+// any time, and is called for the `Air#WarmUpURLs` when the server starts.
+//
+// All the urls are requested even if some of them fail. The failures are
+// logged, and the first one is returned.
+func (a *Air) WarmUp(urls ...string) error {
+	var firstErr error
+	for _, u := range urls {
+		lrw, err := a.serveLocal(u)
+		if err == nil && lrw.status >= http.StatusBadRequest {
+			err = fmt.Errorf(
+				"unexpected status code %d",
+				lrw.status,
+			)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("failed to warm up %q: %v", u, err)
+			a.WARN("air: " + err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// serveLocal serves a GET request for the target through the whole handler
+// chain of the a in process, and returns the recorded response.
+func (a *Air) serveLocal(target string) (*localResponseWriter, error) {
+	host := "localhost"
+	if len(a.HostWhitelist) > 0 {
+		host = a.HostWhitelist[0]
+	}
+
+	hr, err := http.NewRequest(
+		http.MethodGet,
+		"http://"+host+target,
+		http.NoBody,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hr.RequestURI = target
+	hr.RemoteAddr = "127.0.0.1:0"
+
+	return a.serveLocalRequest(hr), nil
+}
+
+// serveLocalRequest serves the hr through the whole handler chain of the a in
+// process, and returns the recorded response.
+func (a *Air) serveLocalRequest(hr *http.Request) *localResponseWriter {
+	lrw := &localResponseWriter{
+		header: http.Header{},
+	}
+
+	a.server.ServeHTTP(lrw, hr)
+
+	if lrw.status == 0 {
+		lrw.status = http.StatusOK
+	}
+
+	return lrw
+}
+
+// localResponseWriter is an in-memory `http.ResponseWriter` used to record the
+// responses served in process.
+type localResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements the `http.ResponseWriter`.
+func (lrw *localResponseWriter) Header() http.Header {
+	return lrw.header
+}
+
+// Write implements the `http.ResponseWriter`.
+func (lrw *localResponseWriter) Write(b []byte) (int, error) {
+	if lrw.status == 0 {
+		lrw.status = http.StatusOK
+	}
+
+	return lrw.body.Write(b)
+}
+
+// WriteHeader implements the `http.ResponseWriter`.
+func (lrw *localResponseWriter) WriteHeader(status int) {
+	if lrw.status == 0 {
+		lrw.status = status
+	}
+}
+
+// internalLinks returns the URL paths of the internal links (the "href" and the
+// "src" attributes) found in the HTML document b served for the target. The
+// queries and the fragments are dropped.
+func internalLinks(target string, b []byte) []string {
+	base, err := url.Parse("http://localhost" + target)
+	if err != nil {
+		return nil
+	}
+
+	var ls []string
+	for z := html.NewTokenizer(bytes.NewReader(b)); ; {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ls
+		case html.StartTagToken, html.SelfClosingTagToken:
+		default:
+			continue
+		}
+
+		for {
+			k, v, more := z.TagAttr()
+			if ak := string(k); ak == "href" || ak == "src" {
+				lu, err := base.Parse(
+					strings.TrimSpace(string(v)),
+				)
+				if err == nil && lu.Scheme == base.Scheme &&
+					lu.Host == base.Host {
+					ls = append(ls, lu.EscapedPath())
+				}
+			}
+
+			if !more {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestAirExport")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := New()
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteHTML(`<a href="/about?foo=bar#baz">About</a>` +
+			`<a href="https://example.com/">Example</a>` +
+			`<img src="logo.txt"><a href="/missing">Missing</a>`)
+	})
+	a.GET("/about", func(req *Request, res *Response) error {
+		return res.WriteHTML(`<a href="/">Home</a>`)
+	})
+	a.GET("/logo.txt", func(req *Request, res *Response) error {
+		return res.WriteString("Logo")
+	})
+	a.GET("/error", func(req *Request, res *Response) error {
+		res.Status = http.StatusBadRequest
+		return res.WriteString("Error")
+	})
+
+	assert.NoError(t, a.Export(dir, []string{"/"}, false))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "About")
+
+	_, err = os.Stat(filepath.Join(dir, "about", "index.html"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, a.Export(dir, []string{"/"}, true))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "about", "index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, `<a href="/">Home</a>`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "logo.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Logo", string(b))
+
+	_, err = os.Stat(filepath.Join(dir, "missing", "index.html"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Error(t, a.Export(dir, []string{"/error"}, false))
+}
+
+func TestAirWarmUp(t *testing.T) {
+	a := New()
+
+	var paths []string
+	a.GET("/", func(req *Request, res *Response) error {
+		paths = append(paths, req.Path)
+		return res.WriteString("Foobar")
+	})
+	a.GET("/foo", func(req *Request, res *Response) error {
+		paths = append(paths, req.Path)
+		return res.WriteString("Foobar")
+	})
+
+	assert.NoError(t, a.WarmUp("/", "/foo?bar=baz"))
+	assert.Equal(t, []string{"/", "/foo?bar=baz"}, paths)
+
+	paths = nil
+	assert.Error(t, a.WarmUp("/missing", "/"))
+	assert.Equal(t, []string{"/"}, paths)
+}
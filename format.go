@@ -0,0 +1,119 @@
+package air
+
+import (
+	"time"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Location returns the timezone of the client of the r. It is taken from the
+// `Air#TimezoneProvider`, the cookie named the `Air#TimezoneCookieName` and the
+// "Time-Zone" header in order, and falls back to the `Air#TimezoneBase` (or the
+// UTC if it is invalid too) if none of them is a valid IANA timezone name.
+//
+// It is used by the `r#FormatTime()` and the template func "loctimefmt".
+func (r *Request) Location() *time.Location {
+	if r.location != nil {
+		return r.location
+	}
+
+	var tzs []string
+	if r.Air.TimezoneProvider != nil {
+		tzs = append(tzs, r.Air.TimezoneProvider(r))
+	}
+
+	if r.Air.TimezoneCookieName != "" {
+		if c := r.Cookie(r.Air.TimezoneCookieName); c != nil {
+			tzs = append(tzs, c.Value)
+		}
+	}
+
+	tzs = append(tzs, r.Header.Get("Time-Zone"))
+
+	r.location = baseLocation(r.Air)
+	for _, tz := range tzs {
+		if tz == "" {
+			continue
+		}
+
+		if l, err := time.LoadLocation(tz); err == nil {
+			r.location = l
+			break
+		}
+	}
+
+	return r.location
+}
+
+// FormatTime returns a textual representation of the t in the `r#Location()`
+// formatted for the layout.
+func (r *Request) FormatTime(t time.Time, layout string) string {
+	return t.In(r.Location()).Format(layout)
+}
+
+// FormatNumber returns a textual representation of the number n formatted in
+// the `r#Locale()`, such as the "1,234.5" for the "en-US" and the "1.234,5"
+// for the "de-DE".
+func (r *Request) FormatNumber(n interface{}) string {
+	return r.printer().Sprint(number.Decimal(n))
+}
+
+// FormatCurrency returns a textual representation of the amount of the
+// currency of the ISO 4217 code (such as the "USD") formatted in the
+// `r#Locale()`, such as the "€ 1.234,50" for the "EUR" and the "de-DE". The
+// amount is formatted as the `r#FormatNumber()` does if the code is invalid.
+func (r *Request) FormatCurrency(amount float64, code string) string {
+	return formatCurrency(r.printer(), amount, code)
+}
+
+// printer returns the `message.Printer` of the `r#Locale()`.
+func (r *Request) printer() *message.Printer {
+	return newLocalePrinter(r.Locale())
+}
+
+// formatCurrency returns a textual representation of the amount of the
+// currency of the ISO 4217 code formatted by using the p.
+func formatCurrency(p *message.Printer, amount float64, code string) string {
+	cu, err := currency.ParseISO(code)
+	if err != nil {
+		return p.Sprint(number.Decimal(amount))
+	}
+
+	scale, _ := currency.Standard.Rounding(cu)
+
+	return p.Sprintf(
+		"%v %v",
+		currency.Symbol(cu),
+		number.Decimal(amount, number.Scale(scale)),
+	)
+}
+
+// newLocalePrinter returns a new `message.Printer` of the locale. The "en-US"
+// is used if the locale is invalid.
+func newLocalePrinter(locale string) *message.Printer {
+	t, err := language.Parse(locale)
+	if err != nil {
+		t = language.AmericanEnglish
+	}
+
+	return message.NewPrinter(t)
+}
+
+// basePrinter returns the `message.Printer` of the `Air#LocaleBase` of the a.
+// It is used where there is no request.
+func basePrinter(a *Air) *message.Printer {
+	return newLocalePrinter(a.LocaleBase)
+}
+
+// baseLocation returns the timezone of the `Air#TimezoneBase` of the a. The UTC
+// is returned if it is invalid.
+func baseLocation(a *Air) *time.Location {
+	if l, err := time.LoadLocation(a.TimezoneBase); err == nil {
+		return l
+	}
+
+	return time.UTC
+}
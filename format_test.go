@@ -0,0 +1,88 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestRequestFormat")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"en-US.toml": `hello = "Hello"`,
+		"de-DE.toml": `hello = "Hallo"`,
+		"index.html": `{{loctimefmt .Time "15:04"}} ` +
+			`{{locnum 1234.5}} {{loccurrency 1234.5 "EUR"}}`,
+	} {
+		assert.NoError(t, ioutil.WriteFile(
+			filepath.Join(dir, name),
+			[]byte(content),
+			0644,
+		))
+	}
+
+	a := New()
+	a.I18nEnabled = true
+	a.LocaleRoot = dir
+	a.TemplateRoot = dir
+	a.TimezoneCookieName = "tz"
+
+	tm := time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	var location string
+	a.GET("/", func(req *Request, res *Response) error {
+		location = req.Location().String()
+		return res.Render(map[string]interface{}{
+			"Time": tm,
+		}, "index.html")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "de-DE")
+	req.Header.Set("Time-Zone", "Asia/Shanghai")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Asia/Shanghai", location)
+	assert.Equal(t, "20:00 1.234,5 € 1.234,50", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "en-US")
+	req.Header.Set("Time-Zone", "Asia/Shanghai")
+	req.AddCookie(&http.Cookie{
+		Name:  "tz",
+		Value: "America/New_York",
+	})
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "America/New_York", location)
+	assert.Equal(t, "07:00 1,234.5 € 1,234.50", rec.Body.String())
+
+	a.TimezoneProvider = func(*Request) string {
+		return "Europe/Berlin"
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Time-Zone", "Invalid/Zone")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "Europe/Berlin", location)
+
+	a.TimezoneProvider = nil
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Time-Zone", "Invalid/Zone")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "UTC", location)
+}
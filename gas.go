@@ -0,0 +1,531 @@
+package air
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// GasAPIVersion is the version of the gas API provided by the current
+// framework. It is increased only when the `GasFactory`, the `GasSettings` or
+// the `GasRegistration` changes incompatibly.
+const GasAPIVersion = 1
+
+// gasCapabilities is the capabilities provided by the current framework.
+var gasCapabilities = []string{
+	"settings",
+	"logger",
+	"plugin",
+	"tracing",
+}
+
+// GasCapabilities returns the capabilities provided by the current framework
+// which can be required by the `GasRegistration`s.
+func GasCapabilities() []string {
+	return append([]string(nil), gasCapabilities...)
+}
+
+// GasFactory defines a function to build gases with settings.
+type GasFactory func(GasSettings) (Gas, error)
+
+// GasRegistration is the registration of a gas in the gas factory registry.
+type GasRegistration struct {
+	// Name is the unique name of the gas.
+	Name string
+
+	// Version is the version of the gas itself. It is informational.
+	Version string
+
+	// APIVersion is the `GasAPIVersion` that the gas is built against.
+	APIVersion int
+
+	// Capabilities is the `GasCapabilities()` that the gas requires.
+	Capabilities []string
+
+	// Factory is the factory used to build the gas.
+	Factory GasFactory
+
+	// Deprecations is the deprecated settings of the gas that are migrated
+	// to their replacements before the `Factory` is called, so that the
+	// settings of the gas can evolve without breaking the existing gas
+	// pipeline files.
+	Deprecations []GasDeprecation
+}
+
+// GasDeprecation is a deprecated setting of a gas.
+type GasDeprecation struct {
+	// Key is the key of the deprecated setting.
+	Key string
+
+	// Replacement is the key of the setting that replaces the deprecated
+	// one. It takes precedence if both of them are set.
+	Replacement string
+
+	// Migrate converts the value of the deprecated setting into the value
+	// of the `Replacement`.
+	//
+	// If it is nil, the value will be moved as is.
+	Migrate func(v interface{}) (interface{}, error)
+}
+
+// negotiate reports whether the gr is compatible with the current framework.
+func (gr GasRegistration) negotiate() error {
+	if gr.Name == "" {
+		return errors.New("gas name cannot be empty")
+	} else if gr.Factory == nil {
+		return errors.New("gas factory cannot be nil")
+	} else if gr.APIVersion != GasAPIVersion {
+		return fmt.Errorf(
+			"gas %q requires api version %d, but %d is provided",
+			gr.Name,
+			gr.APIVersion,
+			GasAPIVersion,
+		)
+	}
+
+	for _, c := range gr.Capabilities {
+		if !stringSliceContains(gasCapabilities, c) {
+			return fmt.Errorf(
+				"gas %q requires unsupported capability %q",
+				gr.Name,
+				c,
+			)
+		}
+	}
+
+	return nil
+}
+
+// gasRegistry is the registry of all registered gas factories.
+var gasRegistry = struct {
+	sync.RWMutex
+
+	m map[string]GasRegistration
+}{
+	m: map[string]GasRegistration{
+		"logger": {
+			Name:       "logger",
+			APIVersion: GasAPIVersion,
+			Factory:    newLoggerGas,
+			Deprecations: []GasDeprecation{
+				{
+					Key:         "format",
+					Replacement: "tags",
+					Migrate:     migrateLoggerGasFormat,
+				},
+			},
+		},
+		"internal": {
+			Name:       "internal",
+			APIVersion: GasAPIVersion,
+			Factory:    newInternalGas,
+		},
+		"tracing": {
+			Name:       "tracing",
+			APIVersion: GasAPIVersion,
+			Factory:    newTracingGas,
+		},
+		"etag": {
+			Name:       "etag",
+			APIVersion: GasAPIVersion,
+			Factory:    newETagGas,
+		},
+	},
+}
+
+// RegisterGas registers the gf for the name into the gas factory registry. The
+// registered gases can be referenced by their names in the gas pipeline files
+// loaded by the `Air#LoadGases()`.
+//
+// It is usually called in the `init()` of the package that provides the gas.
+//
+// It panics if the name is empty, the gf is nil or the name has already been
+// registered.
+func RegisterGas(name string, gf GasFactory) {
+	if name == "" {
+		panic("air: gas name cannot be empty")
+	} else if gf == nil {
+		panic("air: gas factory cannot be nil")
+	}
+
+	if err := registerGas(GasRegistration{
+		Name:       name,
+		APIVersion: GasAPIVersion,
+		Factory:    gf,
+	}); err != nil {
+		panic("air: " + err.Error())
+	}
+}
+
+// RegisterGasPlugin registers the gr into the gas factory registry after
+// negotiating its `APIVersion` and `Capabilities` with the current framework.
+// Unlike the `RegisterGas()`, it returns an error instead of panicking.
+func RegisterGasPlugin(gr GasRegistration) error {
+	if err := gr.negotiate(); err != nil {
+		return err
+	}
+
+	return registerGas(gr)
+}
+
+// registerGas registers the gr into the gas factory registry.
+func registerGas(gr GasRegistration) error {
+	gasRegistry.Lock()
+	defer gasRegistry.Unlock()
+
+	if _, ok := gasRegistry.m[gr.Name]; ok {
+		return errors.New("gas already registered")
+	}
+
+	gr.Capabilities = append([]string(nil), gr.Capabilities...)
+	gr.Deprecations = append([]GasDeprecation(nil), gr.Deprecations...)
+	gasRegistry.m[gr.Name] = gr
+
+	return nil
+}
+
+// RegisteredGases returns all the `GasRegistration`s in the gas factory
+// registry sorted by their names.
+func RegisteredGases() []GasRegistration {
+	gasRegistry.RLock()
+	defer gasRegistry.RUnlock()
+
+	grs := make([]GasRegistration, 0, len(gasRegistry.m))
+	for _, gr := range gasRegistry.m {
+		grs = append(grs, gr)
+	}
+
+	sort.Slice(grs, func(i, j int) bool {
+		return grs[i].Name < grs[j].Name
+	})
+
+	return grs
+}
+
+// BuildGas builds a new `Gas` with the gs by using the gas factory registered
+// for the name.
+//
+// The deprecated settings in the gs (see the `GasRegistration.Deprecations`)
+// are migrated to their replacements with a warning logged once per process
+// by the standard logger.
+func BuildGas(name string, gs GasSettings) (Gas, error) {
+	return buildGas(
+		name,
+		gs,
+		&gasDeprecationsWarned,
+		func(m string, _ ...map[string]interface{}) {
+			log.Print("air: " + m)
+		},
+	)
+}
+
+// buildGas builds a new `Gas` with the gs by using the gas factory registered
+// for the name. The warn is called once for each deprecated setting in the gs
+// that is not yet in the warned, which is then added to the warned.
+func buildGas(
+	name string,
+	gs GasSettings,
+	warned *sync.Map,
+	warn func(m string, extras ...map[string]interface{}),
+) (Gas, error) {
+	gasRegistry.RLock()
+	gr, ok := gasRegistry.m[name]
+	gasRegistry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown gas %q", name)
+	}
+
+	gs, err := gs.migrate(name, gr.Deprecations, warned, warn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build gas %q: %v", name, err)
+	}
+
+	g, err := gr.Factory(gs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build gas %q: %v", name, err)
+	}
+
+	return g, nil
+}
+
+// gasDeprecationsWarned is the set of the deprecated settings of the gases
+// that have been warned about.
+var gasDeprecationsWarned sync.Map
+
+// LoadGases loads the gas pipeline from the filename and appends the gases to
+// the `Pregases`, the `Gases` and the group-level gases of the `Group`s created
+// afterwards.
+//
+// The file must be TOML-based (or JSON-based if its extension is ".json") and
+// look like
+//
+//	[[pregases]]
+//	name = "logger"
+//	settings = { slow_threshold = "1s" }
+//
+//	[[gases]]
+//	name = "foobar"
+//
+//	[[groups."/api"]]
+//	name = "foobar"
+//	settings = { foo = "bar" }
+//
+// where each name must have been registered by the `RegisterGas()`. The
+// deprecated settings are migrated as the `BuildGas()` does, but warned about
+// by using the logger of the a.
+//
+// ATTENTION: Since the group-level gases are chained when routes are
+// registered, it must be called before creating any `Group`.
+func (a *Air) LoadGases(filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	gp := struct {
+		Pregases []gasPipelineItem            `toml:"pregases"`
+		Gases    []gasPipelineItem            `toml:"gases"`
+		Groups   map[string][]gasPipelineItem `toml:"groups"`
+	}{}
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		err = json.Unmarshal(b, &gp)
+	} else {
+		err = toml.Unmarshal(b, &gp)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	pregases, err := a.buildGasPipeline(gp.Pregases)
+	if err != nil {
+		return err
+	}
+
+	gases, err := a.buildGasPipeline(gp.Gases)
+	if err != nil {
+		return err
+	}
+
+	groupGases := make(map[string][]Gas, len(gp.Groups))
+	for prefix, gpis := range gp.Groups {
+		gases, err := a.buildGasPipeline(gpis)
+		if err != nil {
+			return err
+		}
+
+		groupGases[prefix] = gases
+	}
+
+	a.Pregases = append(a.Pregases, pregases...)
+	a.Gases = append(a.Gases, gases...)
+	for prefix, gases := range groupGases {
+		a.groupGases[prefix] = append(a.groupGases[prefix], gases...)
+	}
+
+	return nil
+}
+
+// gasPipelineItem is an item of the gas pipeline file.
+type gasPipelineItem struct {
+	Name     string      `toml:"name"`
+	Settings GasSettings `toml:"settings"`
+}
+
+// buildGasPipeline builds the gases of the gpis for the a.
+func (a *Air) buildGasPipeline(gpis []gasPipelineItem) ([]Gas, error) {
+	gases := make([]Gas, 0, len(gpis))
+	for _, gpi := range gpis {
+		g, err := buildGas(
+			gpi.Name,
+			gpi.Settings,
+			&gasDeprecationsWarned,
+			a.WARN,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		gases = append(gases, g)
+	}
+
+	return gases, nil
+}
+
+// GasSettings is the settings used to build a `Gas`. Its typed accessors
+// return zero values and nil errors for absent keys.
+type GasSettings map[string]interface{}
+
+// Bool returns a `bool` for the key from the gs.
+func (gs GasSettings) Bool(key string) (bool, error) {
+	switch v := gs[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+
+	return false, gs.typeError(key, "bool")
+}
+
+// Int returns an `int` for the key from the gs.
+func (gs GasSettings) Int(key string) (int, error) {
+	switch v := gs[key].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+
+	return 0, gs.typeError(key, "int")
+}
+
+// Float64 returns a `float64` for the key from the gs.
+func (gs GasSettings) Float64(key string) (float64, error) {
+	switch v := gs[key].(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+
+	return 0, gs.typeError(key, "float64")
+}
+
+// String returns a `string` for the key from the gs.
+func (gs GasSettings) String(key string) (string, error) {
+	switch v := gs[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	return "", gs.typeError(key, "string")
+}
+
+// Strings returns a `[]string` for the key from the gs.
+func (gs GasSettings) Strings(key string) ([]string, error) {
+	switch v := gs[key].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, i := range v {
+			s, ok := i.(string)
+			if !ok {
+				return nil, gs.typeError(key, "[]string")
+			}
+
+			ss = append(ss, s)
+		}
+
+		return ss, nil
+	}
+
+	return nil, gs.typeError(key, "[]string")
+}
+
+// Duration returns a `time.Duration` for the key from the gs. The value can
+// either be a string accepted by the `time.ParseDuration()` or a number of
+// nanoseconds.
+func (gs GasSettings) Duration(key string) (time.Duration, error) {
+	switch v := gs[key].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return time.ParseDuration(v)
+	case int64:
+		return time.Duration(v), nil
+	case float64:
+		return time.Duration(v), nil
+	}
+
+	return 0, gs.typeError(key, "time.Duration")
+}
+
+// migrate returns a copy of the gs whose deprecated settings of the gds are
+// migrated to their replacements for the gas of the name. The warn is called
+// for each of the deprecated settings that is not yet in the warned. It returns
+// the gs itself if there is nothing to migrate.
+func (gs GasSettings) migrate(
+	name string,
+	gds []GasDeprecation,
+	warned *sync.Map,
+	warn func(m string, extras ...map[string]interface{}),
+) (GasSettings, error) {
+	mgs, copied := gs, false
+	for _, gd := range gds {
+		v, ok := gs[gd.Key]
+		if !ok {
+			continue
+		}
+
+		if _, ok := warned.LoadOrStore(
+			name+"."+gd.Key,
+			struct{}{},
+		); !ok {
+			warn(fmt.Sprintf(
+				"gas %q setting %q is deprecated, "+
+					"use %q instead",
+				name,
+				gd.Key,
+				gd.Replacement,
+			), map[string]interface{}{
+				"gas":         name,
+				"setting":     gd.Key,
+				"replacement": gd.Replacement,
+			})
+		}
+
+		if !copied {
+			mgs, copied = make(GasSettings, len(gs)), true
+			for k, v := range gs {
+				mgs[k] = v
+			}
+		}
+
+		delete(mgs, gd.Key)
+		if _, ok := gs[gd.Replacement]; ok {
+			continue
+		}
+
+		if gd.Migrate != nil {
+			var err error
+			if v, err = gd.Migrate(v); err != nil {
+				return nil, fmt.Errorf(
+					"gas setting %q: %v",
+					gd.Key,
+					err,
+				)
+			}
+		}
+
+		mgs[gd.Replacement] = v
+	}
+
+	return mgs, nil
+}
+
+// typeError returns an error that indicates the value for the key from the gs
+// is not of the type.
+func (gs GasSettings) typeError(key, typ string) error {
+	return fmt.Errorf("gas setting %q must be of type %s", key, typ)
+}
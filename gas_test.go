@@ -0,0 +1,296 @@
+package air
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterGas(t *testing.T) {
+	gf := func(GasSettings) (Gas, error) {
+		return nil, nil
+	}
+
+	assert.PanicsWithValue(t, "air: gas name cannot be empty", func() {
+		RegisterGas("", gf)
+	})
+
+	assert.PanicsWithValue(t, "air: gas factory cannot be nil", func() {
+		RegisterGas("foobar", nil)
+	})
+
+	assert.PanicsWithValue(t, "air: gas already registered", func() {
+		RegisterGas("logger", gf)
+	})
+
+	_, err := BuildGas("unknown", nil)
+	assert.Error(t, err)
+}
+
+func TestRegisterGasPlugin(t *testing.T) {
+	gf := func(GasSettings) (Gas, error) {
+		return func(next Handler) Handler {
+			return next
+		}, nil
+	}
+
+	assert.Error(t, RegisterGasPlugin(GasRegistration{
+		Name:       "plugin_old",
+		APIVersion: GasAPIVersion - 1,
+		Factory:    gf,
+	}))
+
+	assert.Error(t, RegisterGasPlugin(GasRegistration{
+		Name:         "plugin_unsupported",
+		APIVersion:   GasAPIVersion,
+		Capabilities: []string{"teleportation"},
+		Factory:      gf,
+	}))
+
+	assert.Error(t, RegisterGasPlugin(GasRegistration{
+		Name:       "logger",
+		APIVersion: GasAPIVersion,
+		Factory:    gf,
+	}))
+
+	assert.NoError(t, RegisterGasPlugin(GasRegistration{
+		Name:         "plugin_foobar",
+		Version:      "v1.0.0",
+		APIVersion:   GasAPIVersion,
+		Capabilities: []string{"settings"},
+		Factory:      gf,
+	}))
+	defer unregisterGas("plugin_foobar")
+
+	found := false
+	for _, gr := range RegisteredGases() {
+		if gr.Name == "plugin_foobar" {
+			found = true
+			assert.Equal(t, "v1.0.0", gr.Version)
+		}
+	}
+
+	assert.True(t, found)
+
+	g, err := BuildGas("plugin_foobar", nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, g)
+
+	gcs := GasCapabilities()
+	assert.Contains(t, gcs, "plugin")
+	gcs[0] = "foobar"
+	assert.NotContains(t, GasCapabilities(), "foobar")
+}
+
+func TestBuildGasDeprecations(t *testing.T) {
+	var built GasSettings
+	migrateSize := func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("not a string")
+		}
+
+		return int64(len(s)), nil
+	}
+
+	assert.NoError(t, RegisterGasPlugin(GasRegistration{
+		Name:       "plugin_deprecated",
+		APIVersion: GasAPIVersion,
+		Factory: func(gs GasSettings) (Gas, error) {
+			built = gs
+			return func(next Handler) Handler {
+				return next
+			}, nil
+		},
+		Deprecations: []GasDeprecation{
+			{
+				Key:         "old",
+				Replacement: "new",
+			},
+			{
+				Key:         "size",
+				Replacement: "limit",
+				Migrate:     migrateSize,
+			},
+		},
+	}))
+	defer unregisterGas("plugin_deprecated")
+
+	warned := &sync.Map{}
+	warnings := 0
+	warn := func(string, ...map[string]interface{}) {
+		warnings++
+	}
+
+	gs := GasSettings{"old": "foo", "size": "bar", "other": true}
+	_, err := buildGas("plugin_deprecated", gs, warned, warn)
+	assert.NoError(t, err)
+	assert.Equal(t, GasSettings{
+		"new":   "foo",
+		"limit": int64(3),
+		"other": true,
+	}, built)
+	assert.Equal(t, GasSettings{
+		"old":   "foo",
+		"size":  "bar",
+		"other": true,
+	}, gs)
+	assert.Equal(t, 2, warnings)
+
+	// The replacements take precedence and the warnings are logged once.
+	_, err = buildGas("plugin_deprecated", GasSettings{
+		"old": "foo",
+		"new": "bar",
+	}, warned, warn)
+	assert.NoError(t, err)
+	assert.Equal(t, GasSettings{"new": "bar"}, built)
+	assert.Equal(t, 2, warnings)
+
+	_, err = BuildGas("plugin_deprecated", GasSettings{"size": 1})
+	assert.Error(t, err)
+
+	gs = GasSettings{"other": true}
+	_, err = BuildGas("plugin_deprecated", gs)
+	assert.NoError(t, err)
+	assert.Equal(t, gs, built)
+
+	_, err = BuildGas("logger", GasSettings{"format": "${method}"})
+	assert.NoError(t, err)
+
+	_, err = BuildGas("logger", GasSettings{"format": "${method"})
+	assert.Error(t, err)
+}
+
+func TestAirLoadGases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestAirLoadGases")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	RegisterGas("header", func(gs GasSettings) (Gas, error) {
+		v, err := gs.String("value")
+		if err != nil {
+			return nil, err
+		}
+
+		return func(next Handler) Handler {
+			return func(req *Request, res *Response) error {
+				res.Header.Add("X-Gas", v)
+				return next(req, res)
+			}
+		}, nil
+	})
+	defer unregisterGas("header")
+
+	a := New()
+
+	tomlFile := filepath.Join(dir, "gases.toml")
+	assert.NoError(t, ioutil.WriteFile(tomlFile, []byte(`
+[[pregases]]
+name = "header"
+settings = { value = "pregas" }
+
+[[gases]]
+name = "logger"
+settings = { success_sample_rate = 0.5, slow_threshold = "1s" }
+
+[[groups."/foo"]]
+name = "header"
+settings = { value = "group" }
+`), 0644))
+	assert.NoError(t, a.LoadGases(tomlFile))
+	assert.Len(t, a.Pregases, 1)
+	assert.Len(t, a.Gases, 1)
+	assert.Len(t, a.groupGases["/foo"], 1)
+
+	a.LoggerOutput = ioutil.Discard
+	a.Group("/foo").GET("/bar", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/foo/bar", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"pregas", "group"}, rec.Header()["X-Gas"])
+
+	a = New()
+
+	jsonFile := filepath.Join(dir, "gases.json")
+	assert.NoError(t, ioutil.WriteFile(jsonFile, []byte(`{
+	"gases": [{"name": "header", "settings": {"value": "gas"}}]
+}`), 0644))
+	assert.NoError(t, a.LoadGases(jsonFile))
+	assert.Len(t, a.Pregases, 0)
+	assert.Len(t, a.Gases, 1)
+
+	assert.NoError(t, ioutil.WriteFile(jsonFile, []byte(`{
+	"gases": [{"name": "header", "settings": {"value": 1}}]
+}`), 0644))
+	assert.Error(t, a.LoadGases(jsonFile))
+
+	assert.NoError(t, ioutil.WriteFile(jsonFile, []byte(`{
+	"gases": [{"name": "unknown"}]
+}`), 0644))
+	assert.Error(t, a.LoadGases(jsonFile))
+}
+
+func TestGasSettings(t *testing.T) {
+	gs := GasSettings{
+		"bool":     true,
+		"int":      int64(1),
+		"float64":  0.5,
+		"string":   "foobar",
+		"strings":  []interface{}{"foo", "bar"},
+		"duration": "1s",
+	}
+
+	b, err := gs.Bool("bool")
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	i, err := gs.Int("int")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, i)
+
+	_, err = gs.Int("float64")
+	assert.Error(t, err)
+
+	f64, err := gs.Float64("float64")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, f64)
+
+	s, err := gs.String("string")
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", s)
+
+	ss, err := gs.Strings("strings")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, ss)
+
+	d, err := gs.Duration("duration")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, d)
+
+	s, err = gs.String("absent")
+	assert.NoError(t, err)
+	assert.Empty(t, s)
+
+	_, err = gs.Bool("string")
+	assert.Error(t, err)
+}
+
+// unregisterGas unregisters the gas of the name from the gas factory registry,
+// so that the tests can register it again when they are run repeatedly.
+func unregisterGas(name string) {
+	gasRegistry.Lock()
+	delete(gasRegistry.m, name)
+	gasRegistry.Unlock()
+}
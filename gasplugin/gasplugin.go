@@ -0,0 +1,50 @@
+// Package gasplugin loads the gases of the Air from the Go plugins.
+//
+// It is separated from the package air, so that only the binaries that load
+// the plugins are built with the cgo and the dynamic linking required by the
+// package plugin.
+package gasplugin
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/aofei/air"
+)
+
+// Load loads the Go plugin from the filename and registers all the gases it
+// provides by using the `air.RegisterGasPlugin()`.
+//
+// The plugin must export a function named "AirGases" of the type
+// `func() []air.GasRegistration`. It stops at the first gas that fails to
+// register.
+//
+// See the package plugin for the supported platforms.
+func Load(filename string) error {
+	p, err := plugin.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	s, err := p.Lookup("AirGases")
+	if err != nil {
+		return err
+	}
+
+	f, ok := s.(func() []air.GasRegistration)
+	if !ok {
+		return fmt.Errorf(
+			"plugin %q exports AirGases of unexpected type %T",
+			filename,
+			s,
+		)
+	}
+
+	for _, gr := range f() {
+		if err := air.RegisterGasPlugin(gr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+package gasplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	assert.Error(t, Load("nonexistent.so"))
+}
@@ -0,0 +1,191 @@
+package air
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck is a readiness check of the `HealthGas()`, such as a database
+// ping or an upstream reachability check.
+type HealthCheck struct {
+	// Name is the name of the current check. It is used as the key of the
+	// result of the current check in the readiness response.
+	Name string
+
+	// Timeout is the maximum duration allowed for the `Check`. The
+	// current check fails if it is exceeded.
+	//
+	// If it is less than or equal to zero, the
+	// `HealthGasConfig.CheckTimeout` will be used.
+	Timeout time.Duration
+
+	// Check performs the current check. It should return as soon as the
+	// ctx is done.
+	Check func(ctx context.Context) error
+}
+
+// HealthGasConfig is a set of configurations for the `HealthGas()`.
+type HealthGasConfig struct {
+	// LivenessPath is the path of the liveness endpoint, which always
+	// responds with the 200 status code as long as the server is serving.
+	//
+	// If it is empty, the "/healthz" will be used.
+	LivenessPath string
+
+	// ReadinessPath is the path of the readiness endpoint, which runs all
+	// the `Checks` concurrently and responds with the 200 status code if
+	// all of them pass, or the 503 otherwise.
+	//
+	// If it is empty, the "/readyz" will be used.
+	ReadinessPath string
+
+	// Checks is the readiness checks.
+	Checks []*HealthCheck
+
+	// CheckTimeout is the default timeout of the `Checks`.
+	//
+	// If it is less than or equal to zero, 5 seconds will be used.
+	CheckTimeout time.Duration
+}
+
+// HealthGas returns a `Gas` that serves the liveness and the readiness
+// endpoints with the hgc, such as for the Kubernetes probes. Both endpoints
+// respond with the "application/json" content of the form
+//
+//	{
+//		"status": "fail",
+//		"checks": {
+//			"database": {"status": "pass", "duration": "1.2ms"},
+//			"upstream": {"status": "fail", "error": "timeout"}
+//		}
+//	}
+//
+// It is meant to be used as a pregas, such as
+// `a.Pregases = append(a.Pregases, HealthGas(hgc))`, so that the endpoints
+// are served before routing and other gases.
+func HealthGas(hgc HealthGasConfig) Gas {
+	if hgc.LivenessPath == "" {
+		hgc.LivenessPath = "/healthz"
+	}
+
+	if hgc.ReadinessPath == "" {
+		hgc.ReadinessPath = "/readyz"
+	}
+
+	if hgc.CheckTimeout <= 0 {
+		hgc.CheckTimeout = 5 * time.Second
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			if req.Method != http.MethodGet &&
+				req.Method != http.MethodHead {
+				return next(req, res)
+			}
+
+			switch p, _ := splitPathQuery(req.Path); p {
+			case hgc.LivenessPath:
+				return res.WriteJSON(&healthReport{
+					Status: "pass",
+				})
+			case hgc.ReadinessPath:
+			default:
+				return next(req, res)
+			}
+
+			hr := &healthReport{
+				Status: "pass",
+				Checks: make(
+					map[string]*healthCheckResult,
+					len(hgc.Checks),
+				),
+			}
+
+			mu := sync.Mutex{}
+			wg := sync.WaitGroup{}
+			for _, hc := range hgc.Checks {
+				wg.Add(1)
+				go func(hc *HealthCheck) {
+					defer wg.Done()
+
+					timeout := hc.Timeout
+					if timeout <= 0 {
+						timeout = hgc.CheckTimeout
+					}
+
+					hcr := runHealthCheck(
+						req.Context,
+						hc,
+						timeout,
+					)
+
+					mu.Lock()
+					hr.Checks[hc.Name] = hcr
+					if hcr.Status != "pass" {
+						hr.Status = "fail"
+					}
+
+					mu.Unlock()
+				}(hc)
+			}
+
+			wg.Wait()
+
+			res.Header.Set("Cache-Control", "no-store")
+			if hr.Status != "pass" {
+				res.Status = http.StatusServiceUnavailable
+			}
+
+			return res.WriteJSON(hr)
+		}
+	}
+}
+
+// healthReport is the response content of the `HealthGas()`.
+type healthReport struct {
+	Status string                        `json:"status"`
+	Checks map[string]*healthCheckResult `json:"checks,omitempty"`
+}
+
+// healthCheckResult is the result of a `HealthCheck`.
+type healthCheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// runHealthCheck runs the hc within the timeout under the ctx.
+func runHealthCheck(
+	ctx context.Context,
+	hc *HealthCheck,
+	timeout time.Duration,
+) *healthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- hc.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	hcr := &healthCheckResult{
+		Status:   "pass",
+		Duration: time.Since(started).String(),
+	}
+	if err != nil {
+		hcr.Status = "fail"
+		hcr.Error = err.Error()
+	}
+
+	return hcr
+}
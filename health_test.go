@@ -0,0 +1,107 @@
+package air
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthGas(t *testing.T) {
+	a := New()
+	a.Pregases = []Gas{HealthGas(HealthGasConfig{
+		Checks: []*HealthCheck{
+			{
+				Name: "database",
+				Check: func(ctx context.Context) error {
+					return nil
+				},
+			},
+			{
+				Name:    "upstream",
+				Timeout: 10 * time.Millisecond,
+				Check: func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+		},
+	})}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"status":"pass"}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	hr := healthReport{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hr))
+	assert.Equal(t, "fail", hr.Status)
+	assert.Equal(t, "pass", hr.Checks["database"].Status)
+	assert.Equal(t, "fail", hr.Checks["upstream"].Status)
+	assert.Equal(
+		t,
+		context.DeadlineExceeded.Error(),
+		hr.Checks["upstream"].Error,
+	)
+
+	a = New()
+	a.Pregases = []Gas{HealthGas(HealthGasConfig{
+		ReadinessPath: "/ready",
+		Checks: []*HealthCheck{
+			{
+				Name: "database",
+				Check: func(ctx context.Context) error {
+					return nil
+				},
+			},
+		},
+	})}
+
+	req = httptest.NewRequest(http.MethodGet, "/ready", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/ready?probe=kubelet", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"database"`)
+
+	req = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	a = New()
+	a.Pregases = []Gas{HealthGas(HealthGasConfig{
+		Checks: []*HealthCheck{
+			{
+				Name: "database",
+				Check: func(ctx context.Context) error {
+					return errors.New("connection refused")
+				},
+			},
+		},
+	})}
+
+	req = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	hr = healthReport{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hr))
+	assert.Equal(t, "connection refused", hr.Checks["database"].Error)
+}
@@ -0,0 +1,85 @@
+package air
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// splitHost returns the host of the hostport without the port.
+func splitHost(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport
+	}
+
+	return host
+}
+
+// hostProfile is the IDNA profile of the `asciiHost()`. Unlike the
+// `idna.Lookup`, it does not apply the STD3 rules, so that the hosts with
+// underscores (such as the "my_service" of a service discovery) are allowed.
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.StrictDomainName(false),
+)
+
+// asciiHost returns the host in its lowercase ASCII form without the trailing
+// dot, with the internationalized labels converted into the punycode (such as
+// "xn--bcher-kva.example" for "bücher.example"). The IP addresses are returned
+// as is. It returns an error if the host is not a valid IDNA domain name, such
+// as a host mixing the encodings in a way that does not round-trip.
+func asciiHost(host string) (string, error) {
+	if strings.HasPrefix(host, "[") || net.ParseIP(host) != nil {
+		return strings.ToLower(host), nil
+	}
+
+	ah, err := hostProfile.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i < len(ah); i++ {
+		switch c := ah[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z',
+			c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return "", fmt.Errorf("invalid host character %q", c)
+		}
+	}
+
+	return strings.ToLower(ah), nil
+}
+
+// hostWhitelisted reports whether the host is one of the hosts. They are
+// compared in their ASCII forms, so that the internationalized domain names
+// match regardless of their encodings. See RFC 3986, section 3.2.2.
+func hostWhitelisted(hosts []string, host string) bool {
+	ah, err := asciiHost(host)
+	if err != nil {
+		return false
+	}
+
+	for _, h := range hosts {
+		if wh, err := asciiHost(h); err == nil && wh == ah {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hostname returns the host of the r without the port in its lowercase ASCII
+// form, with the internationalized labels converted into the punycode. It
+// returns "" if the host is not a valid IDNA domain name.
+func (r *Request) Hostname() string {
+	h, err := asciiHost(splitHost(r.Authority))
+	if err != nil {
+		return ""
+	}
+
+	return h
+}
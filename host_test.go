@@ -0,0 +1,90 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsciiHost(t *testing.T) {
+	for h, ah := range map[string]string{
+		"example.com":           "example.com",
+		"Example.COM.":          "example.com",
+		"bücher.example":        "xn--bcher-kva.example",
+		"BÜCHER.example":        "xn--bcher-kva.example",
+		"xn--BCHER-KVA.example": "xn--bcher-kva.example",
+		"127.0.0.1":             "127.0.0.1",
+		"[::1]":                 "[::1]",
+		"ｅｘａ.com":               "exa.com",
+		"My_Service":            "my_service",
+		"my_service.internal":   "my_service.internal",
+	} {
+		got, err := asciiHost(h)
+		assert.NoError(t, err, h)
+		assert.Equal(t, ah, got, h)
+	}
+
+	for _, h := range []string{
+		"xn--bcher-kvaü.example",
+		"xn--zz.example",
+		"ex ample.com",
+		"example.com/foo",
+	} {
+		_, err := asciiHost(h)
+		assert.Error(t, err, h)
+	}
+}
+
+func TestHostWhitelist(t *testing.T) {
+	a := New()
+	a.HostWhitelist = []string{"bücher.example"}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString(req.Hostname())
+	})
+
+	for _, host := range []string{
+		"bücher.example",
+		"xn--bcher-kva.example:8080",
+		"BÜCHER.EXAMPLE",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, host)
+		assert.Equal(t, "xn--bcher-kva.example", rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Host = "example.com"
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(
+		t,
+		"http://xn--bcher-kva.example/foo",
+		rec.Header().Get("Location"),
+	)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "xn--bcher-kvaü.example"
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHostUnderscore(t *testing.T) {
+	a := New()
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString(req.Hostname())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "my_service:8080"
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "my_service", rec.Body.String())
+}
@@ -1,6 +1,7 @@
 package air
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/fsnotify/fsnotify"
+	"golang.org/x/text/feature/plural"
 	"golang.org/x/text/language"
 )
 
@@ -19,6 +21,7 @@ type i18n struct {
 	matcher language.Matcher
 	watcher *fsnotify.Watcher
 	once    *sync.Once
+	mutex   sync.Mutex
 }
 
 // newI18n returns a new instance of the `i18n` with the a.
@@ -49,7 +52,9 @@ func newI18n(a *Air) *i18n {
 					)
 				}
 
+				i.mutex.Lock()
 				i.once = &sync.Once{}
+				i.mutex.Unlock()
 			case err := <-i.watcher.Errors:
 				if a.I18nEnabled {
 					a.ERROR(
@@ -68,7 +73,11 @@ func newI18n(a *Air) *i18n {
 
 // localize localizes the r.
 func (i *i18n) localize(r *Request) {
-	i.once.Do(func() {
+	i.mutex.Lock()
+	once := i.once
+	i.mutex.Unlock()
+
+	once.Do(func() {
 		lr, err := filepath.Abs(i.a.LocaleRoot)
 		if err != nil {
 			i.a.ERROR(
@@ -94,6 +103,9 @@ func (i *i18n) localize(r *Request) {
 			return
 		}
 
+		jlfns, _ := filepath.Glob(filepath.Join(lr, "*.json"))
+		lfns = append(lfns, jlfns...)
+
 		ls := make(map[string]map[string]string, len(lfns))
 		ts := make([]language.Tag, 0, len(lfns))
 		for _, lfn := range lfns {
@@ -109,8 +121,14 @@ func (i *i18n) localize(r *Request) {
 				return
 			}
 
-			l := map[string]string{}
-			if err := toml.Unmarshal(b, &l); err != nil {
+			lm := map[string]interface{}{}
+			if filepath.Ext(lfn) == ".json" {
+				err = json.Unmarshal(b, &lm)
+			} else {
+				err = toml.Unmarshal(b, &lm)
+			}
+
+			if err != nil {
 				i.a.ERROR(
 					"air: failed to unmarshal locale file",
 					map[string]interface{}{
@@ -121,11 +139,12 @@ func (i *i18n) localize(r *Request) {
 				return
 			}
 
-			t, err := language.Parse(strings.Replace(
+			l := map[string]string{}
+			flattenLocale("", lm, l)
+
+			t, err := language.Parse(strings.TrimSuffix(
 				filepath.Base(lfn),
-				".toml",
-				"",
-				1,
+				filepath.Ext(lfn),
 			))
 			if err != nil {
 				i.a.ERROR(
@@ -142,8 +161,10 @@ func (i *i18n) localize(r *Request) {
 			ts = append(ts, t)
 		}
 
+		i.mutex.Lock()
 		i.locales = ls
 		i.matcher = language.NewMatcher(ts)
+		i.mutex.Unlock()
 
 		if err := i.watcher.Add(lr); err != nil {
 			i.a.ERROR(
@@ -155,16 +176,86 @@ func (i *i18n) localize(r *Request) {
 		}
 	})
 
-	t, _ := language.MatchStrings(i.matcher, r.Header["Accept-Language"]...)
-	l := i.locales[t.String()]
+	var lss []string
+	if i.a.LocaleQueryName != "" {
+		if p := r.Param(i.a.LocaleQueryName); p != nil {
+			lss = append(lss, p.Value().String())
+		}
+	}
+
+	if i.a.LocaleCookieName != "" {
+		if c := r.Cookie(i.a.LocaleCookieName); c != nil {
+			lss = append(lss, c.Value)
+		}
+	}
+
+	lss = append(lss, r.Header["Accept-Language"]...)
+
+	i.mutex.Lock()
+	locales, matcher := i.locales, i.matcher
+	i.mutex.Unlock()
+
+	t, _ := language.MatchStrings(matcher, lss...)
+	l, ok := locales[t.String()]
+	if ok {
+		r.locale = t.String()
+	} else {
+		r.locale = i.a.LocaleBase
+	}
 
 	r.localizedString = func(key string) string {
 		if v, ok := l[key]; ok {
 			return v
-		} else if v, ok := i.locales[i.a.LocaleBase][key]; ok {
+		} else if v, ok := locales[i.a.LocaleBase][key]; ok {
 			return v
 		}
 
 		return key
 	}
 }
+
+// flattenLocale flattens the nested tables of the m into the l with the keys
+// joined by ".", such as the "nav.home" for the `{"nav": {"home": "Home"}}`.
+func flattenLocale(
+	prefix string,
+	m map[string]interface{},
+	l map[string]string,
+) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flattenLocale(k, v, l)
+		case string:
+			l[k] = v
+		default:
+			l[k] = fmt.Sprint(v)
+		}
+	}
+}
+
+// pluralForms is the names of the `plural.Form`s.
+var pluralForms = []string{"other", "zero", "one", "two", "few", "many"}
+
+// pluralForm returns the name of the CLDR plural form of the count in the
+// locale, such as "one" and "other".
+func pluralForm(locale string, count int) string {
+	t, err := language.Parse(locale)
+	if err != nil {
+		return "other"
+	}
+
+	if count < 0 {
+		count = -count
+	}
+
+	f := plural.Cardinal.MatchPlural(t, count, 0, 0, 0, 0)
+	if int(f) >= len(pluralForms) {
+		return "other"
+	}
+
+	return pluralForms[f]
+}
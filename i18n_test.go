@@ -0,0 +1,108 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLocalizedStringf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestRequestLocalizedStringf")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "en-US.toml"),
+		[]byte(`
+hello = "Hello, %s!"
+
+[apples]
+one = "%d apple"
+other = "%d apples"
+`),
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "zh-CN.json"),
+		[]byte(`{
+	"hello": "你好，%s！",
+	"apples": {"other": "%d 个苹果"},
+	"nav": {"home": "首页"}
+}`),
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "index.html"),
+		[]byte(`{{locstrf "apples" 1}}`),
+		0644,
+	))
+
+	a := New()
+	a.I18nEnabled = true
+	a.LocaleRoot = dir
+	a.LocaleQueryName = "lang"
+	a.LocaleCookieName = "lang"
+	a.TemplateRoot = dir
+
+	var (
+		locale  string
+		hello   string
+		apples1 string
+		apples2 string
+		home    string
+	)
+
+	h := func(req *Request, res *Response) error {
+		locale = req.Locale()
+		hello = req.LocalizedStringf("hello", "Air")
+		apples1 = req.LocalizedStringf("apples", 1)
+		apples2 = req.LocalizedStringf("apples", 2)
+		home = req.LocalizedString("nav.home")
+		return res.Render(nil, "index.html")
+	}
+
+	a.GET("/", h)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "en-US")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "en-US", locale)
+	assert.Equal(t, "Hello, Air!", hello)
+	assert.Equal(t, "1 apple", apples1)
+	assert.Equal(t, "2 apples", apples2)
+	assert.Equal(t, "nav.home", home)
+	assert.Equal(t, "1 apple", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "en-US")
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "zh-CN"})
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "zh-CN", locale)
+	assert.Equal(t, "你好，Air！", hello)
+	assert.Equal(t, "1 个苹果", apples1)
+	assert.Equal(t, "首页", home)
+
+	req = httptest.NewRequest(http.MethodGet, "/?lang=en-US", nil)
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "zh-CN"})
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "en-US", locale)
+
+	a = New()
+	a.LocaleRoot = dir
+	a.TemplateRoot = dir
+	a.GET("/", h)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "en-US", locale)
+	assert.Equal(t, "hello%!(EXTRA string=Air)", hello)
+}
@@ -0,0 +1,145 @@
+package air
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// internalNetworks is the named networks supported by the
+// `InternalGasConfig.AllowedNetworks`.
+var internalNetworks = map[string][]string{
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"private": {
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"fc00::/7",
+	},
+	"link_local": {"169.254.0.0/16", "fe80::/10"},
+}
+
+// InternalGasConfig is a set of configurations for the `InternalGas()`.
+type InternalGasConfig struct {
+	// AllowedNetworks is the networks that are allowed to access. Each of
+	// them is either a CIDR (such as "203.0.113.0/24"), an IP address or
+	// one of the named networks "loopback", "private" (RFC 1918 and RFC
+	// 4193) and "link_local".
+	//
+	// If it is nil, the ["loopback", "private"] will be used.
+	AllowedNetworks []string
+
+	// AuthGas is the `Gas` that the requests from the networks not allowed
+	// are passed through instead of being rejected, such as a gas that
+	// checks an admin token.
+	//
+	// If it is nil, those requests will be rejected with the 403 status
+	// code.
+	AuthGas Gas
+}
+
+// InternalGas returns a `Gas` that restricts the access of the operational
+// endpoints (such as health checks, metrics, pprof and admin pages) to the
+// internal networks with the igc. It is meant to be used in one place, such as
+// `a.Group("/internal", InternalGas(igc))`, to prevent accidental public
+// exposure of the internals.
+//
+// The network of a request is determined by the `Request#RemoteAddress()`,
+// since the headers set by proxies can be forged by clients. The
+// `Request#ClientAddress()` is used instead if the `Air#TrustedProxies` or the
+// `Air#TrustedProxyHops` is set.
+//
+// It panics if any of the `igc.AllowedNetworks` is invalid.
+func InternalGas(igc InternalGasConfig) Gas {
+	ipns, err := parseInternalNetworks(igc.AllowedNetworks)
+	if err != nil {
+		panic(fmt.Errorf("air: %v", err))
+	}
+
+	return func(next Handler) Handler {
+		var authed Handler
+		if igc.AuthGas != nil {
+			authed = igc.AuthGas(next)
+		}
+
+		return func(req *Request, res *Response) error {
+			addr := req.RemoteAddress()
+			if len(req.Air.TrustedProxies) > 0 ||
+				req.Air.TrustedProxyHops > 0 {
+				addr = req.ClientAddress()
+			}
+
+			if ip := addressIP(addr); ip != nil {
+				for _, ipn := range ipns {
+					if ipn.Contains(ip) {
+						return next(req, res)
+					}
+				}
+			}
+
+			if authed != nil {
+				return authed(req, res)
+			}
+
+			res.Status = http.StatusForbidden
+
+			return errors.New(http.StatusText(res.Status))
+		}
+	}
+}
+
+// newInternalGas returns a new `InternalGas()` built with the gs. It is
+// registered as "internal" in the gas factory registry.
+func newInternalGas(gs GasSettings) (Gas, error) {
+	igc := InternalGasConfig{}
+
+	var err error
+	if igc.AllowedNetworks, err = gs.Strings(
+		"allowed_networks",
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := parseInternalNetworks(igc.AllowedNetworks); err != nil {
+		return nil, err
+	}
+
+	return InternalGas(igc), nil
+}
+
+// parseInternalNetworks parses the ns into IP networks. The named networks are
+// expanded and the IP addresses are treated as single-host networks.
+func parseInternalNetworks(ns []string) ([]*net.IPNet, error) {
+	if ns == nil {
+		ns = []string{"loopback", "private"}
+	}
+
+	var cidrs []string
+	for _, n := range ns {
+		if nns, ok := internalNetworks[n]; ok {
+			cidrs = append(cidrs, nns...)
+		} else if ip := net.ParseIP(n); ip == nil {
+			cidrs = append(cidrs, n)
+		} else if ip.To4() != nil {
+			cidrs = append(cidrs, n+"/32")
+		} else {
+			cidrs = append(cidrs, n+"/128")
+		}
+	}
+
+	ipns := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipn, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"invalid allowed network %q",
+				cidr,
+			)
+		}
+
+		ipns = append(ipns, ipn)
+	}
+
+	return ipns, nil
+}
@@ -0,0 +1,86 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInternalGas(t *testing.T) {
+	a := New()
+	a.Group("/internal", InternalGas(InternalGasConfig{})).GET(
+		"/health",
+		func(req *Request, res *Response) error {
+			return res.WriteString("ok")
+		},
+	)
+
+	for addr, status := range map[string]int{
+		"127.0.0.1:1234":   http.StatusOK,
+		"[::1]:1234":       http.StatusOK,
+		"10.1.2.3:1234":    http.StatusOK,
+		"192.168.1.1:1234": http.StatusOK,
+		"203.0.113.1:1234": http.StatusForbidden,
+		"invalid":          http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(
+			http.MethodGet,
+			"/internal/health",
+			nil,
+		)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, addr)
+	}
+
+	a = New()
+	authGas := func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			if req.Header.Get("X-Token") != "secret" {
+				res.Status = http.StatusUnauthorized
+				return res.WriteString("unauthorized")
+			}
+
+			return next(req, res)
+		}
+	}
+
+	a.GET(
+		"/health",
+		func(req *Request, res *Response) error {
+			return res.WriteString("ok")
+		},
+		InternalGas(InternalGasConfig{
+			AllowedNetworks: []string{"203.0.113.0/24"},
+			AuthGas:         authGas,
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "203.0.113.1:1234"
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Token", "secret")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Panics(t, func() {
+		InternalGas(InternalGasConfig{
+			AllowedNetworks: []string{"foobar"},
+		})
+	})
+}
@@ -0,0 +1,434 @@
+package air
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// InvalidationBus propagates the cache invalidations across the instances of
+// a cluster, so that the per-instance in-memory caches stay usable. The
+// `StoreInvalidationBus` propagates them through a shared `Store`. No adapter
+// for the pub/sub systems such as the Redis and the NATS is built in, they can
+// be implemented outside this framework.
+//
+// All the methods of it must be safe for concurrent use.
+type InvalidationBus interface {
+	// Publish publishes the invalidation of the key to all the
+	// subscribers, including the ones of the current instance.
+	Publish(key string) error
+
+	// Subscribe subscribes to the invalidations with the f. The returned
+	// function unsubscribes the f.
+	Subscribe(f func(key string)) (func(), error)
+}
+
+// LocalInvalidationBus is an `InvalidationBus` that only propagates the
+// invalidations within the current process. It is suited for single-binary
+// deployments and testing.
+type LocalInvalidationBus struct {
+	mu   sync.RWMutex
+	fs   map[int]func(key string)
+	next int
+}
+
+// Publish implements the `InvalidationBus`.
+func (lib *LocalInvalidationBus) Publish(key string) error {
+	lib.mu.RLock()
+	fs := make([]func(key string), 0, len(lib.fs))
+	for _, f := range lib.fs {
+		fs = append(fs, f)
+	}
+
+	lib.mu.RUnlock()
+
+	for _, f := range fs {
+		f(key)
+	}
+
+	return nil
+}
+
+// Subscribe implements the `InvalidationBus`.
+func (lib *LocalInvalidationBus) Subscribe(f func(key string)) (func(), error) {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	if lib.fs == nil {
+		lib.fs = map[int]func(key string){}
+	}
+
+	id := lib.next
+	lib.next++
+	lib.fs[id] = f
+
+	return func() {
+		lib.mu.Lock()
+		delete(lib.fs, id)
+		lib.mu.Unlock()
+	}, nil
+}
+
+// StoreInvalidationBus is an `InvalidationBus` that propagates the
+// invalidations across the instances of a cluster through a `Store` shared by
+// all of them. The invalidations are appended to a log kept in the `Store`,
+// which every instance polls for the ones published by the others.
+//
+// No built-in `Store` can be shared by multiple processes (the `BoltStore`
+// locks its file exclusively), so an adapter of an external store (such as the
+// Redis or the etcd) implemented outside this framework is required for it to
+// work across the instances.
+type StoreInvalidationBus struct {
+	store     Store
+	prefix    string
+	retention time.Duration
+	local     LocalInvalidationBus
+	mu        sync.Mutex
+	cursor    uint64
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+// NewStoreInvalidationBus returns a new instance of the `StoreInvalidationBus`
+// that keeps its log in the s under the keys prefixed with the prefix, and
+// polls the log every interval.
+//
+// The invalidations are kept in the s for 100 times the interval, so the
+// instances that fall behind for longer than that miss some of them. The
+// invalidations published by the current instance are delivered to its
+// subscribers at once, and again when they are polled.
+func NewStoreInvalidationBus(
+	s Store,
+	prefix string,
+	interval time.Duration,
+) (*StoreInvalidationBus, error) {
+	sib := &StoreInvalidationBus{
+		store:     s,
+		prefix:    prefix,
+		retention: 100 * interval,
+		closeChan: make(chan struct{}),
+	}
+
+	var err error
+	if sib.cursor, err = sib.head(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				sib.poll()
+			case <-sib.closeChan:
+				return
+			}
+		}
+	}()
+
+	return sib, nil
+}
+
+// Publish implements the `InvalidationBus`.
+func (sib *StoreInvalidationBus) Publish(key string) error {
+	sib.mu.Lock()
+	n, err := sib.head()
+	if err == nil {
+		if n < sib.cursor {
+			n = sib.cursor
+		}
+
+		err = sib.append(n+1, key)
+	}
+
+	sib.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return sib.local.Publish(key)
+}
+
+// Subscribe implements the `InvalidationBus`.
+func (sib *StoreInvalidationBus) Subscribe(
+	f func(key string),
+) (func(), error) {
+	return sib.local.Subscribe(f)
+}
+
+// Close stops polling the log of the sib. After one call to it, subsequent
+// calls have no effect.
+func (sib *StoreInvalidationBus) Close() error {
+	sib.closeOnce.Do(func() {
+		close(sib.closeChan)
+	})
+
+	return nil
+}
+
+// append appends the key to the log of the sib at the first free position that
+// is not less than the n, and then advances the head of the log to it.
+func (sib *StoreInvalidationBus) append(n uint64, key string) error {
+	for ; ; n++ {
+		set, err := sib.store.SetIfAbsent(
+			sib.entryKey(n),
+			[]byte(key),
+			sib.retention,
+		)
+		if err != nil {
+			return err
+		} else if set {
+			break
+		}
+	}
+
+	for {
+		old, err := sib.store.Get(sib.prefix + "head")
+		if err != nil {
+			return err
+		}
+
+		if h, _ := strconv.ParseUint(string(old), 10, 64); h >= n {
+			return nil
+		}
+
+		var (
+			head    = []byte(strconv.FormatUint(n, 10))
+			swapped bool
+		)
+		if old == nil {
+			swapped, err = sib.store.SetIfAbsent(
+				sib.prefix+"head",
+				head,
+				0,
+			)
+		} else {
+			swapped, err = sib.store.CompareAndSet(
+				sib.prefix+"head",
+				old,
+				head,
+				0,
+			)
+		}
+
+		if err != nil || swapped {
+			return err
+		}
+	}
+}
+
+// poll delivers the invalidations appended to the log of the sib since the last
+// call to it.
+func (sib *StoreInvalidationBus) poll() {
+	sib.mu.Lock()
+	defer sib.mu.Unlock()
+
+	for {
+		v, err := sib.store.Get(sib.entryKey(sib.cursor + 1))
+		if err != nil {
+			return
+		} else if v != nil {
+			sib.cursor++
+			sib.local.Publish(string(v))
+			continue
+		}
+
+		// The position is free only if it has not been appended to
+		// yet, or if it has expired, which is the case when the head
+		// is beyond it. It is checked again after the head is read,
+		// since it may have been appended to in the meantime.
+		h, err := sib.head()
+		if err != nil || h <= sib.cursor {
+			return
+		}
+
+		v, err = sib.store.Get(sib.entryKey(sib.cursor + 1))
+		if err != nil {
+			return
+		}
+
+		sib.cursor++
+		if v != nil {
+			sib.local.Publish(string(v))
+		}
+	}
+}
+
+// head returns the head of the log of the sib.
+func (sib *StoreInvalidationBus) head() (uint64, error) {
+	v, err := sib.store.Get(sib.prefix + "head")
+	if err != nil || v == nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(string(v), 10, 64)
+}
+
+// entryKey returns the key of the entry at the position n of the log of the
+// sib.
+func (sib *StoreInvalidationBus) entryKey(n uint64) string {
+	return sib.prefix + "entry:" + strconv.FormatUint(n, 10)
+}
+
+// CachedStore is a `Store` that caches the values of its underlying `Store` in
+// memory, and keeps the caches of all the instances of a cluster consistent by
+// using an `InvalidationBus`. Every write through it purges the key from the
+// caches of all the instances.
+type CachedStore struct {
+	store       Store
+	bus         InvalidationBus
+	ttl         time.Duration
+	maxEntries  int
+	mu          sync.RWMutex
+	entries     map[string]*cachedStoreEntry
+	generation  uint64
+	unsubscribe func()
+}
+
+// cachedStoreEntry is an entry of the `CachedStore`.
+type cachedStoreEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewCachedStore returns a new instance of the `CachedStore` that caches at
+// most the maxEntries values of the s for at most the ttl and subscribes to the
+// bus. A random value is evicted to make room for a new one when the
+// maxEntries is reached. If the maxEntries is less than or equal to zero,
+// 10000 will be used.
+//
+// Since the cached values are not aware of the TTLs set to the s, a value may
+// be served for at most the ttl after it expires in the s.
+func NewCachedStore(
+	s Store,
+	bus InvalidationBus,
+	ttl time.Duration,
+	maxEntries int,
+) (*CachedStore, error) {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+
+	cs := &CachedStore{
+		store:      s,
+		bus:        bus,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*cachedStoreEntry{},
+	}
+
+	var err error
+	if cs.unsubscribe, err = bus.Subscribe(cs.evict); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// Get implements the `Store`.
+func (cs *CachedStore) Get(key string) ([]byte, error) {
+	cs.mu.RLock()
+	e := cs.entries[key]
+	generation := cs.generation
+	cs.mu.RUnlock()
+	if e != nil && time.Now().Before(e.expires) {
+		return e.value, nil
+	}
+
+	v, err := cs.store.Get(key)
+	if err != nil || v == nil {
+		return v, err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// The v may have been outdated by an eviction in the meantime.
+	if cs.generation != generation {
+		return v, nil
+	}
+
+	if _, ok := cs.entries[key]; !ok && len(cs.entries) >= cs.maxEntries {
+		for k := range cs.entries {
+			delete(cs.entries, k)
+			break
+		}
+	}
+
+	cs.entries[key] = &cachedStoreEntry{
+		value:   v,
+		expires: time.Now().Add(cs.ttl),
+	}
+
+	return v, nil
+}
+
+// Set implements the `Store`.
+func (cs *CachedStore) Set(key string, value []byte, ttl time.Duration) error {
+	if err := cs.store.Set(key, value, ttl); err != nil {
+		return err
+	}
+
+	return cs.Purge(key)
+}
+
+// SetIfAbsent implements the `Store`.
+func (cs *CachedStore) SetIfAbsent(
+	key string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	set, err := cs.store.SetIfAbsent(key, value, ttl)
+	if err != nil || !set {
+		return set, err
+	}
+
+	return true, cs.Purge(key)
+}
+
+// CompareAndSet implements the `Store`.
+func (cs *CachedStore) CompareAndSet(
+	key string,
+	old []byte,
+	new []byte,
+	ttl time.Duration,
+) (bool, error) {
+	swapped, err := cs.store.CompareAndSet(key, old, new, ttl)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+
+	return true, cs.Purge(key)
+}
+
+// Delete implements the `Store`.
+func (cs *CachedStore) Delete(key string) error {
+	if err := cs.store.Delete(key); err != nil {
+		return err
+	}
+
+	return cs.Purge(key)
+}
+
+// Purge purges the cached value for the key from all the instances without
+// touching the underlying `Store`.
+func (cs *CachedStore) Purge(key string) error {
+	cs.evict(key)
+	return cs.bus.Publish(key)
+}
+
+// Close unsubscribes the cs from its `InvalidationBus`.
+func (cs *CachedStore) Close() error {
+	cs.unsubscribe()
+	return nil
+}
+
+// evict evicts the cached value for the key from the cs.
+func (cs *CachedStore) evict(key string) {
+	cs.mu.Lock()
+	delete(cs.entries, key)
+	cs.generation++
+	cs.mu.Unlock()
+}
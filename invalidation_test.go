@@ -0,0 +1,266 @@
+package air
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestCachedStore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	bus := &LocalInvalidationBus{}
+
+	cs1, err := NewCachedStore(bs, bus, time.Minute, 0)
+	assert.NoError(t, err)
+	defer cs1.Close()
+
+	cs2, err := NewCachedStore(bs, bus, time.Minute, 0)
+	assert.NoError(t, err)
+
+	var s Store = cs1
+
+	assert.NoError(t, s.Set("foo", []byte("bar"), 0))
+
+	v, err := cs2.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), v)
+
+	// Bypass the caches.
+	assert.NoError(t, bs.Set("foo", []byte("baz"), 0))
+
+	v, err = cs2.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), v)
+
+	assert.NoError(t, cs1.Purge("foo"))
+
+	v, err = cs2.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("baz"), v)
+
+	assert.NoError(t, s.Delete("foo"))
+
+	v, err = cs2.Get("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	set, err := s.SetIfAbsent("foo", []byte("qux"), 0)
+	assert.NoError(t, err)
+	assert.True(t, set)
+
+	v, err = cs2.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("qux"), v)
+
+	swapped, err := s.CompareAndSet("foo", []byte("qux"), []byte("bar"), 0)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	v, err = cs2.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), v)
+
+	assert.NoError(t, cs2.Close())
+	assert.NoError(t, bs.Set("foo", []byte("baz"), 0))
+	assert.NoError(t, cs1.Purge("foo"))
+
+	v, err = cs2.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), v)
+}
+
+func TestCachedStoreMaxEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestCachedStoreMaxEntries")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	cs, err := NewCachedStore(bs, &LocalInvalidationBus{}, time.Minute, 2)
+	assert.NoError(t, err)
+	defer cs.Close()
+
+	for _, k := range []string{"foo", "bar", "baz"} {
+		assert.NoError(t, bs.Set(k, []byte(k), 0))
+
+		v, err := cs.Get(k)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(k), v)
+	}
+
+	assert.Len(t, cs.entries, 2)
+
+	// The values read before an eviction are not cached.
+	assert.NoError(t, bs.Set("qux", []byte("qux"), 0))
+	cs.store = storeFunc(func(key string) ([]byte, error) {
+		v, err := bs.Get(key)
+		cs.evict(key)
+		return v, err
+	})
+
+	v, err := cs.Get("qux")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("qux"), v)
+	assert.NotContains(t, cs.entries, "qux")
+}
+
+// storeFunc is a `Store` whose Get is the function itself.
+type storeFunc func(key string) ([]byte, error)
+
+func (sf storeFunc) Get(key string) ([]byte, error) {
+	return sf(key)
+}
+
+func (sf storeFunc) Set(string, []byte, time.Duration) error {
+	return nil
+}
+
+func (sf storeFunc) SetIfAbsent(string, []byte, time.Duration) (bool, error) {
+	return false, nil
+}
+
+func (sf storeFunc) CompareAndSet(
+	string,
+	[]byte,
+	[]byte,
+	time.Duration,
+) (bool, error) {
+	return false, nil
+}
+
+func (sf storeFunc) Delete(string) error {
+	return nil
+}
+
+func TestStoreInvalidationBus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestStoreInvalidationBus")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	sib1, err := NewStoreInvalidationBus(bs, "bus:", time.Millisecond)
+	assert.NoError(t, err)
+	defer sib1.Close()
+
+	sib2, err := NewStoreInvalidationBus(bs, "bus:", time.Millisecond)
+	assert.NoError(t, err)
+	defer sib2.Close()
+
+	keys1 := make(chan string, 10)
+	_, err = sib1.Subscribe(func(key string) {
+		keys1 <- key
+	})
+	assert.NoError(t, err)
+
+	keys2 := make(chan string, 10)
+	unsubscribe, err := sib2.Subscribe(func(key string) {
+		keys2 <- key
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sib1.Publish("foo"))
+	assert.NoError(t, sib1.Publish("bar"))
+	assert.Equal(t, "foo", <-keys1)
+	assert.Equal(t, "bar", <-keys1)
+
+	for _, want := range []string{"foo", "bar"} {
+		select {
+		case key := <-keys2:
+			assert.Equal(t, want, key)
+		case <-time.After(time.Second):
+			t.Fatalf("invalidation of %q not propagated", want)
+		}
+	}
+
+	unsubscribe()
+
+	// The invalidations published before are not delivered to the new
+	// instances.
+	sib3, err := NewStoreInvalidationBus(bs, "bus:", time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, sib3.Close())
+	assert.NoError(t, sib3.Close())
+	assert.Equal(t, uint64(2), sib3.cursor)
+
+	dir2, err := ioutil.TempDir("", "air.TestStoreInvalidationBus")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir2)
+
+	bs2, err := NewBoltStore(filepath.Join(dir2, "air.db"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, bs2.Close())
+
+	_, err = NewStoreInvalidationBus(bs2, "bus:", time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestStoreInvalidationBusPollRace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestStoreInvalidationBusPollRace")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	sib2, err := NewStoreInvalidationBus(bs, "bus:", time.Hour)
+	assert.NoError(t, err)
+	defer sib2.Close()
+
+	// The entry is appended by another instance right after it is found
+	// absent.
+	raced := false
+	rs := &getHookStore{
+		Store: bs,
+		hook: func(key string) {
+			if key == "bus:entry:1" && !raced {
+				raced = true
+				assert.NoError(t, sib2.Publish("foo"))
+			}
+		},
+	}
+
+	sib1, err := NewStoreInvalidationBus(rs, "bus:", time.Hour)
+	assert.NoError(t, err)
+	defer sib1.Close()
+
+	var keys []string
+	_, err = sib1.Subscribe(func(key string) {
+		keys = append(keys, key)
+	})
+	assert.NoError(t, err)
+
+	sib1.poll()
+	assert.True(t, raced)
+	assert.Equal(t, []string{"foo"}, keys)
+	assert.Equal(t, uint64(1), sib1.cursor)
+}
+
+// getHookStore is a `Store` that calls the hook after every Get.
+type getHookStore struct {
+	Store
+
+	hook func(key string)
+}
+
+func (ghs *getHookStore) Get(key string) ([]byte, error) {
+	v, err := ghs.Store.Get(key)
+	ghs.hook(key)
+	return v, err
+}
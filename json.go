@@ -0,0 +1,83 @@
+package air
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONSerializer is used to encode and decode JSON. It can be implemented to
+// plug faster third-party JSON encoders into the `Air#JSONSerializer`.
+//
+// The `jsoniter.API` of the github.com/json-iterator/go and the `sonic.API` of
+// the github.com/bytedance/sonic already implement it, and the
+// github.com/segmentio/encoding/json can be plugged by using the
+// `JSONSerializerFuncs`.
+type JSONSerializer interface {
+	// Marshal returns the JSON encoding of the v.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal parses the JSON-encoded b and stores the result in the
+	// value pointed to by the v.
+	Unmarshal(b []byte, v interface{}) error
+}
+
+// JSONSerializerFuncs is an adapter to allow the use of ordinary functions as a
+// `JSONSerializer`, such as the `json.Marshal()` and the `json.Unmarshal()` of
+// the github.com/segmentio/encoding/json.
+type JSONSerializerFuncs struct {
+	// MarshalFunc is the function used by the `Marshal()`.
+	MarshalFunc func(v interface{}) ([]byte, error)
+
+	// UnmarshalFunc is the function used by the `Unmarshal()`.
+	UnmarshalFunc func(b []byte, v interface{}) error
+}
+
+// Marshal implements the `JSONSerializer`.
+func (jsf JSONSerializerFuncs) Marshal(v interface{}) ([]byte, error) {
+	return jsf.MarshalFunc(v)
+}
+
+// Unmarshal implements the `JSONSerializer`.
+func (jsf JSONSerializerFuncs) Unmarshal(b []byte, v interface{}) error {
+	return jsf.UnmarshalFunc(b, v)
+}
+
+// jsonSerializer is a JSON serializer based on the encoding/json.
+type jsonSerializer struct {
+	a *Air
+}
+
+// newJSONSerializer returns a new instance of the `jsonSerializer` with the a.
+func newJSONSerializer(a *Air) *jsonSerializer {
+	return &jsonSerializer{
+		a: a,
+	}
+}
+
+// Marshal implements the `JSONSerializer`.
+func (js *jsonSerializer) Marshal(v interface{}) ([]byte, error) {
+	indent := js.a.JSONIndent
+	if indent == "" && js.a.DebugMode {
+		indent = "\t"
+	}
+
+	buf := bytes.Buffer{}
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(!js.a.JSONHTMLEscapingDisabled)
+	e.SetIndent("", indent)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// Unmarshal implements the `JSONSerializer`.
+func (js *jsonSerializer) Unmarshal(b []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	if js.a.JSONUnknownFieldsDisallowed {
+		d.DisallowUnknownFields()
+	}
+
+	return d.Decode(v)
+}
@@ -0,0 +1,79 @@
+package air
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSerializer(t *testing.T) {
+	a := New()
+	js := a.JSONSerializer
+
+	b, err := js.Marshal(map[string]string{"foo": "<bar>"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"foo":"\u003cbar\u003e"}`, string(b))
+
+	a.JSONHTMLEscapingDisabled = true
+	a.JSONIndent = "  "
+
+	b, err = js.Marshal(map[string]string{"foo": "<bar>"})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"foo\": \"<bar>\"\n}", string(b))
+
+	var v struct {
+		Foo string `json:"foo"`
+	}
+
+	assert.NoError(t, js.Unmarshal([]byte(`{"foo":"bar","bar":1}`), &v))
+	assert.Equal(t, "bar", v.Foo)
+
+	a.JSONUnknownFieldsDisallowed = true
+
+	assert.Error(t, js.Unmarshal([]byte(`{"foo":"bar","bar":1}`), &v))
+}
+
+func TestJSONSerializerFuncs(t *testing.T) {
+	a := New()
+
+	marshaled, unmarshaled := false, false
+	a.JSONSerializer = JSONSerializerFuncs{
+		MarshalFunc: func(v interface{}) ([]byte, error) {
+			marshaled = true
+			return json.Marshal(v)
+		},
+		UnmarshalFunc: func(b []byte, v interface{}) error {
+			unmarshaled = true
+			return json.Unmarshal(b, v)
+		},
+	}
+
+	a.POST("/", func(req *Request, res *Response) error {
+		var v struct {
+			Foo string `json:"foo"`
+		}
+
+		if err := req.Bind(&v); err != nil {
+			return err
+		}
+
+		return res.WriteJSON(&v)
+	})
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		strings.NewReader(`{"foo":"bar"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"foo":"bar"}`, rec.Body.String())
+	assert.True(t, marshaled)
+	assert.True(t, unmarshaled)
+}
@@ -0,0 +1,187 @@
+package air
+
+import (
+	"sync"
+	"time"
+)
+
+// LeaderElector elects a leader among the instances that share the same key in
+// the same `Store`. It can be used to run scheduled jobs exactly once across
+// multiple instances.
+//
+// The leader holds a lease which is renewed every third of the `LeaseDuration`.
+// When the leader dies, another instance takes over after the lease expires.
+type LeaderElector struct {
+	// Store is where the lease is kept. It must be shared by all the
+	// instances. No built-in `Store` can be shared by multiple processes
+	// (the `BoltStore` locks its file exclusively), so an adapter of an
+	// external store (such as the Redis or the etcd) implemented outside
+	// this framework is required for multi-instance deployments.
+	Store Store
+
+	// Key is the key of the lease in the `Store`.
+	Key string
+
+	// ID is the unique identity of the current instance.
+	//
+	// The default value is a random hex string.
+	ID string
+
+	// LeaseDuration is the duration of the lease held by the leader.
+	//
+	// The default value is 15 seconds.
+	LeaseDuration time.Duration
+
+	// ElectedHandler is the handler that handles the current instance
+	// becoming the leader.
+	ElectedHandler func()
+
+	// DeposedHandler is the handler that handles the current instance
+	// losing the leadership.
+	DeposedHandler func()
+
+	// ErrorHandler is the handler that handles errors occur when accessing
+	// the `Store`. When an error occurs, the current instance is
+	// considered no longer the leader.
+	ErrorHandler func(err error)
+
+	mutex    sync.Mutex
+	leader   bool
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// NewLeaderElector returns a new instance of the `LeaderElector` with the s and
+// the key.
+func NewLeaderElector(s Store, key string) *LeaderElector {
+	return &LeaderElector{
+		Store:         s,
+		Key:           key,
+		ID:            randomHex(16),
+		LeaseDuration: 15 * time.Second,
+	}
+}
+
+// Start starts campaigning for the leadership in the background. After one
+// call to it, subsequent calls have no effect until the `le#Stop()` is called.
+func (le *LeaderElector) Start() {
+	le.mutex.Lock()
+	if le.stopChan != nil {
+		le.mutex.Unlock()
+		return
+	}
+
+	stopChan := make(chan struct{})
+	doneChan := make(chan struct{})
+	le.stopChan = stopChan
+	le.doneChan = doneChan
+	le.mutex.Unlock()
+
+	go func() {
+		defer close(doneChan)
+
+		le.campaign()
+
+		t := time.NewTicker(le.LeaseDuration / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				le.campaign()
+			case <-stopChan:
+				le.resign()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops campaigning and gives up the leadership (if any) so that another
+// instance can take over immediately.
+func (le *LeaderElector) Stop() {
+	le.mutex.Lock()
+	stopChan, doneChan := le.stopChan, le.doneChan
+	le.stopChan = nil
+	le.doneChan = nil
+	le.mutex.Unlock()
+
+	if stopChan != nil {
+		close(stopChan)
+		<-doneChan
+	}
+}
+
+// IsLeader reports whether the current instance is the leader.
+func (le *LeaderElector) IsLeader() bool {
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+	return le.leader
+}
+
+// Do calls the f only if the current instance is the leader. It reports
+// whether the f has been called.
+func (le *LeaderElector) Do(f func()) bool {
+	if !le.IsLeader() {
+		return false
+	}
+
+	f()
+
+	return true
+}
+
+// campaign acquires or renews the lease.
+func (le *LeaderElector) campaign() {
+	id := []byte(le.ID)
+	ok, err := le.Store.SetIfAbsent(le.Key, id, le.LeaseDuration)
+	if err == nil && !ok {
+		ok, err = le.Store.CompareAndSet(
+			le.Key,
+			id,
+			id,
+			le.LeaseDuration,
+		)
+	}
+
+	if err != nil && le.ErrorHandler != nil {
+		le.ErrorHandler(err)
+	}
+
+	le.setLeader(err == nil && ok)
+}
+
+// resign releases the lease if the current instance is the leader.
+func (le *LeaderElector) resign() {
+	if !le.IsLeader() {
+		return
+	}
+
+	id := []byte(le.ID)
+	if _, err := le.Store.CompareAndSet(
+		le.Key,
+		id,
+		nil,
+		0,
+	); err != nil && le.ErrorHandler != nil {
+		le.ErrorHandler(err)
+	}
+
+	le.setLeader(false)
+}
+
+// setLeader sets whether the current instance is the leader and calls the
+// matching handler if it has changed.
+func (le *LeaderElector) setLeader(leader bool) {
+	le.mutex.Lock()
+	changed := le.leader != leader
+	le.leader = leader
+	le.mutex.Unlock()
+
+	if !changed {
+		return
+	} else if leader && le.ElectedHandler != nil {
+		le.ElectedHandler()
+	} else if !leader && le.DeposedHandler != nil {
+		le.DeposedHandler()
+	}
+}
@@ -0,0 +1,93 @@
+package air
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderElector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestLeaderElector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	le1 := NewLeaderElector(bs, "leader")
+	le1.LeaseDuration = 30 * time.Millisecond
+
+	cs := &campaignCountingStore{Store: bs}
+	elected := make(chan struct{}, 1)
+	le2 := NewLeaderElector(cs, "leader")
+	le2.LeaseDuration = 30 * time.Millisecond
+	le2.ElectedHandler = func() {
+		elected <- struct{}{}
+	}
+
+	assert.NotEqual(t, le1.ID, le2.ID)
+
+	le1.Start()
+	le1.Start()
+	for deadline := time.Now().Add(time.Second); !le1.IsLeader(); {
+		if time.Now().After(deadline) {
+			t.Fatal("leadership not taken")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	le2.Start()
+	defer le2.Stop()
+
+	// The first campaign of the le2 is done once the second one begins.
+	for deadline := time.Now().Add(time.Second); cs.count() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("leadership not campaigned for")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.True(t, le1.IsLeader())
+	assert.False(t, le2.IsLeader())
+	assert.True(t, le1.Do(func() {}))
+	assert.False(t, le2.Do(func() {}))
+
+	le1.Stop()
+	assert.False(t, le1.IsLeader())
+
+	select {
+	case <-elected:
+	case <-time.After(time.Second):
+		t.Fatal("leadership not taken over")
+	}
+
+	assert.True(t, le2.IsLeader())
+}
+
+// campaignCountingStore is a `Store` that counts the campaigns made through it.
+type campaignCountingStore struct {
+	Store
+
+	campaigns int32
+}
+
+func (ccs *campaignCountingStore) SetIfAbsent(
+	key string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	atomic.AddInt32(&ccs.campaigns, 1)
+	return ccs.Store.SetIfAbsent(key, value, ttl)
+}
+
+func (ccs *campaignCountingStore) count() int32 {
+	return atomic.LoadInt32(&ccs.campaigns)
+}
@@ -2,8 +2,14 @@ package air
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -24,6 +30,19 @@ func newLogger(a *Air) *logger {
 
 // log logs the m at the ll with the optional es.
 func (l *logger) log(ll LoggerLevel, m string, es ...map[string]interface{}) {
+	l.logTo(l.a.LoggerOutput, 3, ll, m, es...)
+}
+
+// logTo logs the m at the ll with the optional es into the w. The skip is the
+// number of the stack frames to ascend to the caller that is logged in the
+// debug mode, with 0 identifying the caller of the logTo.
+func (l *logger) logTo(
+	w io.Writer,
+	skip int,
+	ll LoggerLevel,
+	m string,
+	es ...map[string]interface{},
+) {
 	if !l.a.DebugMode && ll < l.a.LoggerLevel {
 		return
 	}
@@ -38,7 +57,7 @@ func (l *logger) log(ll LoggerLevel, m string, es ...map[string]interface{}) {
 		"message":  m,
 	}
 	if l.a.DebugMode {
-		_, fn, l, _ := runtime.Caller(2)
+		_, fn, l, _ := runtime.Caller(skip)
 		fs["caller"] = fmt.Sprintf("%s:%d", fn, l)
 	}
 
@@ -70,8 +89,8 @@ func (l *logger) log(ll LoggerLevel, m string, es ...map[string]interface{}) {
 		b = []byte(s)
 	}
 
-	l.a.LoggerOutput.Write(b)
-	l.a.LoggerOutput.Write([]byte{'\n'})
+	w.Write(b)
+	w.Write([]byte{'\n'})
 }
 
 // LoggerLevel is the level of the logger.
@@ -121,3 +140,441 @@ func (ll LoggerLevel) String() string {
 
 	return "off"
 }
+
+// LoggerGasConfig is a set of configurations for the `LoggerGas()`.
+type LoggerGasConfig struct {
+	// SuccessSampleRate is the rate at which the successful requests are
+	// logged. It is a float between 0 and 1. For example, 0.01 means
+	// that only about 1% of the successful requests will be logged.
+	//
+	// A request is successful when it is neither failed nor slow. The
+	// failed requests (those whose handlers return errors or whose status
+	// codes are greater than or equal to 500) and the slow requests
+	// (see the `SlowThreshold`) are always logged.
+	//
+	// If it is not between 0 and 1 (exclusive), all the successful
+	// requests will be logged.
+	SuccessSampleRate float64
+
+	// SlowThreshold is the latency threshold above which a request is
+	// considered slow.
+	//
+	// If it is zero, no request will be considered slow.
+	SlowThreshold time.Duration
+
+	// Predicate reports whether a successful request should be logged.
+	//
+	// If it is not nil, it will be used instead of the
+	// `SuccessSampleRate`.
+	Predicate func(
+		req *Request,
+		res *Response,
+		latency time.Duration,
+	) bool
+
+	// Tags is the tags of the fields that will be logged for each request.
+	//
+	// The supported tags are "client_address", "remote_address",
+	// "authority", "method", "path", "route", "protocol", "tls", "status",
+	// "latency", "bytes_in", "bytes_out", "trace_id" and the lookups
+	// "header_<name>", "response_header_<name>", "query_<name>",
+	// "form_<name>", "cookie_<name>" and "baggage_<key>". Unsupported tags
+	// will be silently ignored.
+	//
+	// The "authority" is the `Request#Authority`, and the "route" is the
+	// path pattern of the matched route, such as "/users/:id".
+	//
+	// If it is nil, the ["client_address", "method", "path", "status",
+	// "latency", "bytes_in", "bytes_out"] will be used.
+	Tags []string
+
+	// CustomFields returns the extra fields that will be logged for each
+	// request, such as the ID of the current user or tenant. They take
+	// precedence over the fields of the `Tags`.
+	CustomFields func(req *Request, res *Response) map[string]interface{}
+
+	// Tenant returns the tenant of a request, such as the ID of the
+	// customer resolved from its host or its API key. It is logged as the
+	// field "tenant".
+	//
+	// If it is nil, no tenant will be logged.
+	Tenant func(req *Request) string
+
+	// TenantLimit is the maximum number of the distinct tenants logged.
+	// The tenants beyond it are logged as the `TenantOther`.
+	//
+	// If it is not positive, the 100 will be used.
+	TenantLimit int
+
+	// TenantOutputs returns the output destination of the logs of the
+	// tenant, so that the logs of each tenant can be partitioned. The
+	// `Air#LoggerOutput` is used if it returns nil.
+	//
+	// If it is nil, all the logs will go to the `Air#LoggerOutput`.
+	TenantOutputs func(tenant string) io.Writer
+
+	// Format is the template of the fields that will be logged for each
+	// request, such as "${remote_ip} ${method} ${uri} ${status}". It is
+	// converted into the `Tags` (if it is nil) with a warning logged once.
+	// The placeholders named differently are mapped to the tags, such as
+	// the "${user_agent}" to the "header_User-Agent" and the "${host}" to
+	// the "authority", and the ones of the fields that are always logged
+	// (the "${time_rfc3339}" and the "${error}") are dropped.
+	//
+	// Deprecated: Use the `Tags` instead.
+	Format string
+}
+
+// LoggerGas returns a `Gas` that logs every request-response cycle it
+// processes by using the logger of the `Air` with the lgc.
+//
+// The failed requests are logged at the `LoggerLevelError` and the others are
+// logged at the `LoggerLevelInfo`.
+//
+// It panics if the deprecated `lgc.Format` is invalid, such as having an
+// unsupported placeholder.
+func LoggerGas(lgc LoggerGasConfig) Gas {
+	if lgc.Format != "" && lgc.Tags == nil {
+		tags, err := loggerGasFormatTags(lgc.Format)
+		if err != nil {
+			panic(fmt.Errorf(
+				"air: invalid logger gas format: %v",
+				err,
+			))
+		}
+
+		lgc.Tags = tags
+	}
+
+	formatWarning := sync.Once{}
+	warnFormat := func(a *Air) {
+		formatWarning.Do(func() {
+			a.WARN(
+				"logger gas format is deprecated, use tags",
+				map[string]interface{}{
+					"tags": lgc.Tags,
+				},
+			)
+		})
+	}
+
+	tl := newTenantLimiter(lgc.TenantLimit)
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			if lgc.Format != "" {
+				warnFormat(req.Air)
+			}
+
+			startTime := time.Now()
+			err := next(req, res)
+			latency := time.Since(startTime)
+
+			failed := err != nil ||
+				res.Status >= http.StatusInternalServerError
+			slow := lgc.SlowThreshold > 0 &&
+				latency >= lgc.SlowThreshold
+			if !failed && !slow && !lgc.sampled(req, res, latency) {
+				return err
+			}
+
+			tags := lgc.Tags
+			if tags == nil {
+				tags = defaultLoggerGasTags
+			}
+
+			extras := make(map[string]interface{}, len(tags)+2)
+			for _, tag := range tags {
+				if v, ok := loggerGasField(
+					tag,
+					req,
+					res,
+					latency,
+				); ok {
+					extras[tag] = v
+				}
+			}
+
+			if lgc.CustomFields != nil {
+				for k, v := range lgc.CustomFields(req, res) {
+					extras[k] = v
+				}
+			}
+
+			w := req.Air.LoggerOutput
+			if lgc.Tenant != nil {
+				tenant := tl.limited(lgc.Tenant(req))
+				extras["tenant"] = tenant
+				var tw io.Writer
+				if lgc.TenantOutputs != nil {
+					tw = lgc.TenantOutputs(tenant)
+				}
+
+				if tw != nil {
+					w = tw
+				}
+			}
+
+			extras["slow"] = slow
+			if err != nil {
+				extras["error"] = err.Error()
+			}
+
+			ll, m := LoggerLevelInfo, "air: request served"
+			if failed {
+				ll, m = LoggerLevelError, "air: request failed"
+			}
+
+			req.Air.logger.logTo(w, 1, ll, m, extras)
+
+			return err
+		}
+	}
+}
+
+// defaultLoggerGasTags is the default tags of the `LoggerGasConfig`.
+var defaultLoggerGasTags = []string{
+	"client_address",
+	"method",
+	"path",
+	"status",
+	"latency",
+	"bytes_in",
+	"bytes_out",
+}
+
+// loggerGasField returns the value of the field for the tag from the req, the
+// res and the latency. It reports false if the tag is unsupported or the
+// looked up value is absent.
+func loggerGasField(
+	tag string,
+	req *Request,
+	res *Response,
+	latency time.Duration,
+) (interface{}, bool) {
+	switch tag {
+	case "client_address":
+		return req.ClientAddress(), true
+	case "remote_address":
+		return req.RemoteAddress(), true
+	case "authority":
+		return req.Authority, true
+	case "method":
+		return req.Method, true
+	case "path":
+		return req.Path, true
+	case "route":
+		if req.route == nil {
+			return "", false
+		}
+
+		return req.route.Path, true
+	case "protocol":
+		return req.hr.Proto, true
+	case "tls":
+		return req.hr.TLS != nil, true
+	case "status":
+		return res.Status, true
+	case "latency":
+		return int64(latency), true
+	case "bytes_in":
+		return req.ContentLength, true
+	case "bytes_out":
+		return res.ContentLength, true
+	case "trace_id":
+		if tc := req.TraceContext(); tc != nil {
+			return tc.TraceID, true
+		}
+
+		return nil, false
+	}
+
+	i := strings.IndexByte(tag, '_')
+	if i < 0 {
+		return nil, false
+	}
+
+	var vs []string
+	switch name := tag[i+1:]; tag[:i+1] {
+	case "header_":
+		vs = req.Header[http.CanonicalHeaderKey(name)]
+	case "response_":
+		if !strings.HasPrefix(name, "header_") {
+			return nil, false
+		}
+
+		vs = res.Header[http.CanonicalHeaderKey(name[7:])]
+	case "query_":
+		_, q := splitPathQuery(req.Path)
+		if qvs, err := url.ParseQuery(q); err == nil {
+			vs = qvs[name]
+		}
+	case "form_":
+		req.parseOtherParamsOnce.Do(req.parseOtherParams)
+		vs = req.hr.PostForm[name]
+		if len(vs) == 0 && req.hr.MultipartForm != nil {
+			vs = req.hr.MultipartForm.Value[name]
+		}
+	case "cookie_":
+		if c := req.Cookie(name); c != nil {
+			vs = []string{c.Value}
+		}
+	case "baggage_":
+		if tc := req.TraceContext(); tc != nil {
+			if v, ok := tc.Baggage[name]; ok {
+				vs = []string{v}
+			}
+		}
+	}
+
+	if len(vs) == 0 {
+		return nil, false
+	}
+
+	return strings.Join(vs, ", "), true
+}
+
+// newLoggerGas returns a new `LoggerGas()` built with the gs. It is registered
+// as "logger" in the gas factory registry.
+func newLoggerGas(gs GasSettings) (Gas, error) {
+	lgc := LoggerGasConfig{}
+
+	var err error
+	if lgc.SuccessSampleRate, err = gs.Float64(
+		"success_sample_rate",
+	); err != nil {
+		return nil, err
+	}
+
+	if lgc.SlowThreshold, err = gs.Duration("slow_threshold"); err != nil {
+		return nil, err
+	}
+
+	if lgc.Tags, err = gs.Strings("tags"); err != nil {
+		return nil, err
+	}
+
+	return LoggerGas(lgc), nil
+}
+
+// loggerGasFormatPlaceholders is the tags of the placeholders of the
+// deprecated `LoggerGasConfig.Format` that are named differently. The empty
+// tags are of the placeholders whose fields are always logged, such as the
+// "${time_rfc3339}" (which is the "time") and the "${error}".
+var loggerGasFormatPlaceholders = map[string]string{
+	"remote_ip":         "client_address",
+	"uri":               "path",
+	"host":              "authority",
+	"id":                "header_X-Request-Id",
+	"referer":           "header_Referer",
+	"user_agent":        "header_User-Agent",
+	"latency_human":     "latency",
+	"time_unix":         "",
+	"time_unix_milli":   "",
+	"time_unix_micro":   "",
+	"time_unix_nano":    "",
+	"time_rfc3339":      "",
+	"time_rfc3339_nano": "",
+	"time_custom":       "",
+	"error":             "",
+}
+
+// loggerGasTagSupported reports whether the tag is supported by the
+// `LoggerGasConfig.Tags`.
+func loggerGasTagSupported(tag string) bool {
+	switch tag {
+	case "client_address", "remote_address", "authority", "method", "path",
+		"route", "protocol", "tls", "status", "latency", "bytes_in",
+		"bytes_out", "trace_id":
+		return true
+	}
+
+	for _, prefix := range []string{
+		"header_",
+		"response_header_",
+		"query_",
+		"form_",
+		"cookie_",
+		"baggage_",
+	} {
+		if len(tag) > len(prefix) && strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loggerGasFormatTags returns the tags of the placeholders in the format of the
+// deprecated `LoggerGasConfig.Format`, such as the "${method}" and the
+// "${header:User-Agent}" (which is the "header_User-Agent"). It returns an
+// error if any of the placeholders is unsupported.
+func loggerGasFormatTags(format string) ([]string, error) {
+	var tags []string
+	for {
+		i := strings.Index(format, "${")
+		if i < 0 {
+			break
+		}
+
+		format = format[i+2:]
+		j := strings.IndexByte(format, '}')
+		if j < 0 {
+			return nil, errors.New("unclosed placeholder")
+		}
+
+		placeholder := format[:j]
+		format = format[j+1:]
+
+		tag, ok := loggerGasFormatPlaceholders[placeholder]
+		if !ok {
+			tag = strings.Replace(placeholder, ":", "_", 1)
+		} else if tag == "" {
+			continue
+		}
+
+		if !loggerGasTagSupported(tag) {
+			return nil, fmt.Errorf(
+				"unsupported placeholder ${%s}",
+				placeholder,
+			)
+		}
+
+		if !stringSliceContains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+// migrateLoggerGasFormat migrates the value of the deprecated "format" setting
+// of the "logger" gas into the value of the "tags".
+func migrateLoggerGasFormat(v interface{}) (interface{}, error) {
+	format, ok := v.(string)
+	if !ok {
+		return nil, errors.New("must be of type string")
+	}
+
+	tags, err := loggerGasFormatTags(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// sampled reports whether the successful request-response cycle of the req and
+// the res with the latency should be logged.
+func (lgc LoggerGasConfig) sampled(
+	req *Request,
+	res *Response,
+	latency time.Duration,
+) bool {
+	if lgc.Predicate != nil {
+		return lgc.Predicate(req, res, latency)
+	} else if lgc.SuccessSampleRate <= 0 || lgc.SuccessSampleRate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < lgc.SuccessSampleRate
+}
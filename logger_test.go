@@ -2,7 +2,14 @@ package air
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -56,3 +63,235 @@ func TestLoggerLevelString(t *testing.T) {
 	assert.Equal(t, "off", LoggerLevelOff.String())
 	assert.Equal(t, "off", LoggerLevel(255).String())
 }
+
+func TestLoggerGas(t *testing.T) {
+	a := New()
+
+	buf := bytes.Buffer{}
+	a.LoggerOutput = &buf
+
+	a.Gases = []Gas{LoggerGas(LoggerGasConfig{})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, buf.String(), "\"message\":\"air: request served\"")
+	assert.Contains(t, buf.String(), "\"status\":200")
+
+	a = New()
+
+	buf.Reset()
+	a.LoggerOutput = &buf
+
+	a.Gases = []Gas{LoggerGas(LoggerGasConfig{
+		SlowThreshold: 10 * time.Millisecond,
+		Predicate: func(*Request, *Response, time.Duration) bool {
+			return false
+		},
+	})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+	a.GET("/slow", func(req *Request, res *Response) error {
+		time.Sleep(20 * time.Millisecond)
+		return res.WriteString("Foobar")
+	})
+	a.GET("/error", func(req *Request, res *Response) error {
+		return errors.New("foobar")
+	})
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, buf.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/slow", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, buf.String(), "\"slow\":true")
+
+	buf.Reset()
+
+	req = httptest.NewRequest(http.MethodGet, "/error", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, buf.String(), "\"message\":\"air: request failed\"")
+	assert.Contains(t, buf.String(), "\"error\":\"foobar\"")
+}
+
+func TestLoggerGasTags(t *testing.T) {
+	a := New()
+
+	buf := bytes.Buffer{}
+	a.LoggerOutput = &buf
+
+	a.Gases = []Gas{LoggerGas(LoggerGasConfig{
+		Tags: []string{
+			"route",
+			"protocol",
+			"tls",
+			"header_x-foo",
+			"response_header_x-bar",
+			"query_foo",
+			"cookie_foo",
+			"header_absent",
+			"unsupported",
+		},
+		CustomFields: func(*Request, *Response) map[string]interface{} {
+			return map[string]interface{}{
+				"user_id": 1,
+			}
+		},
+	})}
+	a.GET("/users/:id", func(req *Request, res *Response) error {
+		res.Header.Set("X-Bar", "bar")
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1?foo=bar", nil)
+	req.Header.Set("X-Foo", "foo")
+	req.AddCookie(&http.Cookie{
+		Name:  "foo",
+		Value: "cookie",
+	})
+
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	m := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "/users/:id", m["route"])
+	assert.Equal(t, "HTTP/1.1", m["protocol"])
+	assert.Equal(t, false, m["tls"])
+	assert.Equal(t, "foo", m["header_x-foo"])
+	assert.Equal(t, "bar", m["response_header_x-bar"])
+	assert.Equal(t, "bar", m["query_foo"])
+	assert.Equal(t, "cookie", m["cookie_foo"])
+	assert.Equal(t, float64(1), m["user_id"])
+	assert.NotContains(t, m, "header_absent")
+	assert.NotContains(t, m, "unsupported")
+	assert.NotContains(t, m, "method")
+}
+
+func TestLoggerGasFormat(t *testing.T) {
+	assert.Panics(t, func() {
+		LoggerGas(LoggerGasConfig{
+			Format: "${method",
+		})
+	})
+
+	assert.Panics(t, func() {
+		LoggerGas(LoggerGasConfig{
+			Format: "${method} ${foobar}",
+		})
+	})
+
+	_, err := loggerGasFormatTags("${method} ${foobar}")
+	assert.EqualError(t, err, "unsupported placeholder ${foobar}")
+
+	tags, err := loggerGasFormatTags("${time_rfc3339} ${host} " +
+		"${user_agent} ${referer} ${status} ${error} ${query:foo}")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"authority",
+		"header_User-Agent",
+		"header_Referer",
+		"status",
+		"query_foo",
+	}, tags)
+
+	_, err = migrateLoggerGasFormat("${header:}")
+	assert.Error(t, err)
+
+	a := New()
+
+	buf := bytes.Buffer{}
+	a.LoggerOutput = &buf
+
+	a.Gases = []Gas{LoggerGas(LoggerGasConfig{
+		Format: "${remote_ip} ${method} ${uri} ${header:X-Foo} " +
+			"${method}",
+	})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Foo", "foo")
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+
+	m := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &m))
+	assert.Equal(t, "warn", m["level"])
+	assert.Equal(t, []interface{}{
+		"client_address",
+		"method",
+		"path",
+		"header_X-Foo",
+	}, m["tags"])
+
+	m = map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &m))
+	assert.Equal(t, "info", m["level"])
+	assert.Equal(t, "GET", m["method"])
+	assert.Equal(t, "/", m["path"])
+	assert.Equal(t, "foo", m["header_X-Foo"])
+	assert.Contains(t, m, "client_address")
+	assert.NotContains(t, m, "status")
+}
+
+func TestLoggerGasTenant(t *testing.T) {
+	a := New()
+
+	buf := bytes.Buffer{}
+	a.LoggerOutput = &buf
+
+	acmeBuf := bytes.Buffer{}
+	a.Gases = []Gas{LoggerGas(LoggerGasConfig{
+		Tenant: func(req *Request) string {
+			return req.Header.Get("X-Tenant")
+		},
+		TenantLimit: 1,
+		TenantOutputs: func(tenant string) io.Writer {
+			if tenant == "acme" {
+				return &acmeBuf
+			}
+
+			return nil
+		},
+	})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant", "acme")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, buf.String())
+	assert.Contains(t, acmeBuf.String(), "\"tenant\":\"acme\"")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant", "globex")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, buf.String(), "\"tenant\":\"other\"")
+	assert.NotContains(t, acmeBuf.String(), "globex")
+}
@@ -0,0 +1,327 @@
+package air
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash"
+)
+
+// MemoizeGasConfig is a set of configurations for the `MemoizeGas()`.
+type MemoizeGasConfig struct {
+	// Input returns a pointer to a new value that the requests are bound
+	// into by using the `Request#Bind()`, such as
+	// `func() interface{} { return &ListUsersInput{} }`. The bound value,
+	// rather than the raw URL, keys the memoized results, so the requests
+	// that differ only in the order or the encoding of their params share
+	// the same result.
+	//
+	// It must not be nil.
+	Input func() interface{}
+
+	// TTL is the duration for which a result is memoized.
+	//
+	// If it is less than or equal to zero, one minute will be used.
+	TTL time.Duration
+
+	// MaxEntries is the maximum number of the memoized results. A random
+	// result is evicted to make room for a new one when it is reached.
+	//
+	// If it is less than or equal to zero, 1000 will be used.
+	MaxEntries int
+
+	// MaxBodyBytes is the maximum number of bytes of the message body of a
+	// result to be memoized.
+	//
+	// If it is less than or equal to zero, 1 MiB will be used.
+	MaxBodyBytes int
+
+	// VaryHeaders is the names of the request headers that also key the
+	// memoized results, such as the "Accept-Language" for the localized
+	// results.
+	//
+	// The requests that carry the credentials (the "Authorization" or the
+	// "Cookie" header) are passed through as is, unless the header is one
+	// of the `VaryHeaders`, so that the results of a caller are never
+	// replayed to another.
+	VaryHeaders []string
+
+	// Tags returns the invalidation tags of the result of the bound input,
+	// such as the "user:1". A result is evicted whenever one of its tags
+	// is published to the `InvalidationBus`.
+	Tags func(input interface{}) []string
+
+	// InvalidationBus is where the invalidations of the tags are
+	// subscribed to. It is required for the `Tags` to take effect.
+	InvalidationBus InvalidationBus
+}
+
+// memoizeEntry is a memoized result of the `MemoizeGas()`.
+type memoizeEntry struct {
+	header  http.Header
+	body    []byte
+	tags    []string
+	expires time.Time
+}
+
+// MemoizeGas returns a `Gas` that memoizes the successful results of the GET
+// requests it processes with the mgc, keyed by the route, the canonicalized
+// input bound from each request and the `mgc.VaryHeaders`. A memoized result is
+// replayed without calling the handler until it expires or is invalidated.
+//
+// The requests that fail to be bound are passed through as is. The results are
+// fully buffered before being sent, except the ones that are flushed or are
+// event streams, which are streamed without being memoized.
+func MemoizeGas(mgc MemoizeGasConfig) Gas {
+	if mgc.Input == nil {
+		panic("air: memoize gas input cannot be nil")
+	}
+
+	if mgc.TTL <= 0 {
+		mgc.TTL = time.Minute
+	}
+
+	if mgc.MaxEntries <= 0 {
+		mgc.MaxEntries = 1000
+	}
+
+	if mgc.MaxBodyBytes <= 0 {
+		mgc.MaxBodyBytes = 1 << 20
+	}
+
+	mc := &memoizeCache{
+		maxEntries: mgc.MaxEntries,
+		entries:    map[string]*memoizeEntry{},
+	}
+
+	if mgc.InvalidationBus != nil {
+		_, err := mgc.InvalidationBus.Subscribe(mc.invalidate)
+		if err != nil {
+			panic(fmt.Errorf("air: %v", err))
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			if req.Method != http.MethodGet ||
+				mgc.credentialed(req) {
+				return next(req, res)
+			}
+
+			input := mgc.Input()
+			if err := req.Bind(input); err != nil {
+				return next(req, res)
+			}
+
+			key, err := mgc.key(req, input)
+			if err != nil {
+				return next(req, res)
+			}
+
+			if e := mc.get(key); e != nil && e.replay(res) {
+				return nil
+			}
+
+			rb := newResponseBuffer(res)
+			if rb == nil {
+				return next(req, res)
+			}
+
+			// The headers set before the handler (such as by the
+			// outer gases) belong to the req rather than the
+			// result.
+			preHeader := rb.Header().Clone()
+
+			err = next(req, res)
+			if err != nil || !res.Written || rb.streaming ||
+				rb.status != http.StatusOK ||
+				rb.Header().Get("Set-Cookie") != "" {
+				if ferr := rb.flush(); err == nil {
+					err = ferr
+				}
+
+				return err
+			}
+
+			rb.complete()
+			if rb.body.Len() > mgc.MaxBodyBytes {
+				return rb.flush()
+			}
+
+			e := &memoizeEntry{
+				header:  headerDiff(preHeader, rb.Header()),
+				body:    rb.body.Bytes(),
+				expires: time.Now().Add(mgc.TTL),
+			}
+			if mgc.Tags != nil {
+				e.tags = mgc.Tags(input)
+			}
+
+			mc.set(key, e)
+
+			return rb.flush()
+		}
+	}
+}
+
+// memoizeCache is the cache of the `memoizeEntry`s of a `MemoizeGas()`.
+type memoizeCache struct {
+	sync.Mutex
+
+	maxEntries int
+	entries    map[string]*memoizeEntry
+}
+
+// get returns the unexpired entry for the key. It returns nil if not found.
+func (mc *memoizeCache) get(key string) *memoizeEntry {
+	mc.Lock()
+	defer mc.Unlock()
+
+	e := mc.entries[key]
+	if e != nil && !time.Now().Before(e.expires) {
+		delete(mc.entries, key)
+		return nil
+	}
+
+	return e
+}
+
+// set sets the e for the key. A random entry is evicted if the mc is full.
+func (mc *memoizeCache) set(key string, e *memoizeEntry) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	if len(mc.entries) >= mc.maxEntries {
+		for k := range mc.entries {
+			delete(mc.entries, k)
+			break
+		}
+	}
+
+	mc.entries[key] = e
+}
+
+// invalidate evicts the entries tagged with the tag.
+func (mc *memoizeCache) invalidate(tag string) {
+	mc.Lock()
+	defer mc.Unlock()
+
+	for k, e := range mc.entries {
+		if stringSliceContains(e.tags, tag) {
+			delete(mc.entries, k)
+		}
+	}
+}
+
+// credentialed reports whether the req carries the credentials that are not
+// one of the `mgc.VaryHeaders`.
+func (mgc MemoizeGasConfig) credentialed(req *Request) bool {
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if req.Header.Get(name) == "" {
+			continue
+		}
+
+		varied := false
+		for _, vh := range mgc.VaryHeaders {
+			if strings.EqualFold(vh, name) {
+				varied = true
+				break
+			}
+		}
+
+		if !varied {
+			return true
+		}
+	}
+
+	return false
+}
+
+// key returns the memoization key of the req with the bound input.
+func (mgc MemoizeGasConfig) key(
+	req *Request,
+	input interface{},
+) (string, error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+
+	route := ""
+	if req.route != nil {
+		route = req.route.Path
+	}
+
+	d := xxhash.New()
+	d.Write([]byte(route))
+	d.Write([]byte{0})
+	d.Write(b)
+	for _, vh := range mgc.VaryHeaders {
+		d.Write([]byte{0})
+		d.Write([]byte(req.Header.Get(vh)))
+	}
+
+	// The memoized message bodies may have been gzipped.
+	if req.Air.GzipEnabled {
+		d.Write([]byte{0})
+		if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+			d.Write([]byte("gzip"))
+		}
+	}
+
+	return base64.RawURLEncoding.EncodeToString(d.Sum(nil)), nil
+}
+
+// replay writes the me to the res. It reports false if the res cannot be
+// written directly.
+func (me *memoizeEntry) replay(res *Response) bool {
+	rw, ok := res.hrw.(*responseWriter)
+	if !ok || res.Written {
+		return false
+	}
+
+	h := rw.w.Header()
+	for k, vs := range me.header {
+		h[k] = append([]string(nil), vs...)
+	}
+
+	rw.w.WriteHeader(http.StatusOK)
+	n, _ := rw.w.Write(me.body)
+
+	res.Status = http.StatusOK
+	res.ContentLength = int64(n)
+	res.Written = true
+
+	return true
+}
+
+// headerDiff returns the entries of the h that are absent from or different in
+// the pre.
+func headerDiff(pre, h http.Header) http.Header {
+	d := http.Header{}
+	for k, vs := range h {
+		pvs, ok := pre[k]
+		if ok && len(pvs) == len(vs) {
+			same := true
+			for i := range vs {
+				if vs[i] != pvs[i] {
+					same = false
+					break
+				}
+			}
+
+			if same {
+				continue
+			}
+		}
+
+		d[k] = append([]string(nil), vs...)
+	}
+
+	return d
+}
@@ -0,0 +1,111 @@
+package air
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoizeTestInput struct {
+	Page int
+	Sort string
+}
+
+func TestMemoizeGas(t *testing.T) {
+	lib := &LocalInvalidationBus{}
+
+	requestIDs := 0
+
+	a := New()
+	a.Pregases = []Gas{func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			requestIDs++
+			res.Header.Set("X-Request-Id", fmt.Sprint(requestIDs))
+			return next(req, res)
+		}
+	}}
+	a.Gases = []Gas{MemoizeGas(MemoizeGasConfig{
+		Input: func() interface{} {
+			return &memoizeTestInput{}
+		},
+		Tags: func(input interface{}) []string {
+			mti := input.(*memoizeTestInput)
+			return []string{fmt.Sprint("page:", mti.Page)}
+		},
+		InvalidationBus: lib,
+	})}
+
+	calls := 0
+	a.GET("/users", func(req *Request, res *Response) error {
+		calls++
+		res.Header.Set("X-Calls", fmt.Sprint(calls))
+		return res.WriteString("Foobar")
+	})
+
+	for i, target := range []string{
+		"/users?Page=2&Sort=name",
+		"/users?Sort=name&Page=2",
+		"/users?Sort=na%6De&Page=02",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Calls"))
+		assert.Equal(
+			t,
+			fmt.Sprint(i+1),
+			rec.Header().Get("X-Request-Id"),
+		)
+		assert.Equal(t, "Foobar", rec.Body.String())
+	}
+
+	assert.Equal(t, 1, calls)
+
+	for _, name := range []string{"Authorization", "Cookie"} {
+		req := httptest.NewRequest(
+			http.MethodGet,
+			"/users?Page=2&Sort=name",
+			nil,
+		)
+		req.Header.Set(name, "foobar")
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Foobar", rec.Body.String())
+	}
+
+	assert.Equal(t, 3, calls)
+
+	req := httptest.NewRequest(http.MethodGet, "/users?Page=3", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 4, calls)
+
+	assert.NoError(t, lib.Publish("page:2"))
+
+	req = httptest.NewRequest(
+		http.MethodGet,
+		"/users?Page=2&Sort=name",
+		nil,
+	)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get("X-Calls"))
+	assert.Equal(t, 5, calls)
+
+	req = httptest.NewRequest(http.MethodGet, "/users?Page=3", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 5, calls)
+
+	assert.Panics(t, func() {
+		MemoizeGas(MemoizeGasConfig{})
+	})
+}
@@ -0,0 +1,478 @@
+package air
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UsageMeter meters the units consumed by the requests per API key, which is
+// the basis for the usage-based billing.
+//
+// The handlers report the units they consume by using the `Request#Meter()`,
+// and the units can also be derived automatically from the bytes transferred
+// and the time spent. The units are aggregated in memory and added to the
+// totals in the `Store` every `SnapshotInterval`, so that the totals survive
+// restarts and are shared by all the instances. The API keys can also be given
+// quotas, and their clients are warned as they approach the quotas.
+type UsageMeter struct {
+	// Store is where the totals are kept. It must be shared by all the
+	// instances.
+	Store Store
+
+	// KeyPrefix is the prefix of the keys of the totals in the `Store`.
+	// The key of a total is the `KeyPrefix` followed by its API key.
+	//
+	// The default value is "air:usage:".
+	KeyPrefix string
+
+	// APIKey returns the API key of a request. The requests without API
+	// keys are not metered.
+	//
+	// The default value returns the value of the "X-API-Key" header, or
+	// the token of the "Authorization" header of the bearer scheme.
+	APIKey func(req *Request) string
+
+	// BytesPerUnit is the number of the bytes transferred (received and
+	// sent) that are metered as one unit.
+	//
+	// The default value is zero, which means that the bytes are not
+	// metered.
+	BytesPerUnit int64
+
+	// DurationPerUnit is the amount of the time spent handling a request
+	// that is metered as one unit. It is measured in wall-clock time,
+	// which approximates the CPU time for the CPU-bound handlers.
+	//
+	// The default value is zero, which means that the time is not metered.
+	DurationPerUnit time.Duration
+
+	// SnapshotInterval is the interval at which the aggregated units are
+	// added to the totals in the `Store`.
+	//
+	// The default value is one minute.
+	SnapshotInterval time.Duration
+
+	// Quota returns the quota of the units of the API key. The requests
+	// with API keys that have used up their quotas are rejected with the
+	// 429, and the responses carry the "X-Quota-Limit" and the
+	// "X-Quota-Remaining" headers. A quota that is not positive means
+	// unlimited.
+	//
+	// The default value is nil, which means that all the API keys are
+	// unlimited.
+	Quota func(apiKey string) int64
+
+	// WarningThresholds is the fractions of the quotas at which the
+	// clients are warned before they are rejected. The responses to the
+	// clients that have crossed one of them carry the
+	// "X-RateLimit-Warning" header, and the `WarningHandler` is called
+	// once every time an API key crosses one of them.
+	//
+	// The default value is [0.8, 0.9].
+	WarningThresholds []float64
+
+	// WarningHandler is the handler that handles an API key crossing the
+	// threshold of one of the `WarningThresholds`, such as by calling a
+	// webhook to notify the owner of the API key. It is called in its own
+	// goroutine.
+	WarningHandler func(
+		apiKey string,
+		usage int64,
+		quota int64,
+		threshold float64,
+	)
+
+	// ErrorHandler is the handler that handles errors occur when accessing
+	// the `Store`. The units that fail to be added are kept for the next
+	// snapshot.
+	ErrorHandler func(err error)
+
+	mutex    sync.Mutex
+	pending  map[string]usageMeterPending
+	warned   map[string]float64
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// NewUsageMeter returns a new instance of the `UsageMeter` with the s.
+func NewUsageMeter(s Store) *UsageMeter {
+	return &UsageMeter{
+		Store:             s,
+		KeyPrefix:         "air:usage:",
+		APIKey:            defaultUsageMeterAPIKey,
+		SnapshotInterval:  time.Minute,
+		WarningThresholds: []float64{0.8, 0.9},
+		pending:           map[string]usageMeterPending{},
+		warned:            map[string]float64{},
+	}
+}
+
+// usageMeterPending is the usage of an API key of the `UsageMeter` that has not
+// been snapshotted yet. The bytes and the duration are kept as is, and
+// converted into units only when they are snapshotted, so that the fractional
+// units of the requests are not lost.
+type usageMeterPending struct {
+	units    int64
+	bytes    int64
+	duration time.Duration
+}
+
+// defaultUsageMeterAPIKey is the default `UsageMeter#APIKey`.
+func defaultUsageMeterAPIKey(req *Request) string {
+	if k := req.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+
+	a := req.Header.Get("Authorization")
+	if len(a) > 7 && strings.EqualFold(a[:7], "Bearer ") {
+		return strings.TrimSpace(a[7:])
+	}
+
+	return ""
+}
+
+// Gas returns a `Gas` that meters every request it processes into the um.
+func (um *UsageMeter) Gas() Gas {
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			key := um.APIKey(req)
+			if key == "" {
+				return next(req, res)
+			}
+
+			quota, usage := int64(0), int64(0)
+			if um.Quota != nil {
+				quota = um.Quota(key)
+			}
+
+			if quota > 0 {
+				var err error
+				if usage, err = um.Usage(key); err != nil {
+					return err
+				}
+
+				err = um.checkQuota(res, usage, quota)
+				if err != nil {
+					return err
+				}
+			}
+
+			startTime := time.Now()
+			err := next(req, res)
+
+			ump := usageMeterPending{
+				units: atomic.LoadInt64(&req.meteredUnits),
+			}
+			if um.BytesPerUnit > 0 {
+				ump.bytes = res.ContentLength
+				if req.ContentLength > 0 {
+					ump.bytes += req.ContentLength
+				}
+			}
+
+			if um.DurationPerUnit > 0 {
+				ump.duration = time.Since(startTime)
+			}
+
+			units := um.add(key, ump)
+			if quota > 0 {
+				um.warn(key, usage+units, quota)
+			}
+
+			return err
+		}
+	}
+}
+
+// checkQuota sets the quota headers of the res from the usage and the quota.
+// It returns an error with the status code of the res set to the 429 if the
+// quota has been used up.
+func (um *UsageMeter) checkQuota(res *Response, usage, quota int64) error {
+	remaining := quota - usage
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	res.Header.Set("X-Quota-Limit", strconv.FormatInt(quota, 10))
+	res.Header.Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+	if remaining == 0 {
+		res.Status = http.StatusTooManyRequests
+		return errors.New(http.StatusText(res.Status))
+	}
+
+	if t := um.threshold(usage, quota); t > 0 {
+		res.Header.Set(
+			"X-RateLimit-Warning",
+			fmt.Sprintf("%g%% of the quota used", t*100),
+		)
+	}
+
+	return nil
+}
+
+// threshold returns the highest one of the `um#WarningThresholds` crossed by
+// the usage of the quota. It returns zero if none has been crossed.
+func (um *UsageMeter) threshold(usage, quota int64) float64 {
+	t := 0.0
+	for _, wt := range um.WarningThresholds {
+		if wt > t && float64(usage) >= wt*float64(quota) {
+			t = wt
+		}
+	}
+
+	return t
+}
+
+// warn calls the `um#WarningHandler` if the usage of the key has crossed a
+// higher one of the `um#WarningThresholds` of the quota than the last time.
+func (um *UsageMeter) warn(key string, usage, quota int64) {
+	t := um.threshold(usage, quota)
+
+	um.mutex.Lock()
+	wt := um.warned[key]
+	if t != wt {
+		if t > 0 {
+			um.warned[key] = t
+		} else {
+			delete(um.warned, key)
+		}
+	}
+	um.mutex.Unlock()
+
+	if t > wt && um.WarningHandler != nil {
+		go um.WarningHandler(key, usage, quota, t)
+	}
+}
+
+// Add adds the units to the usage of the key.
+func (um *UsageMeter) Add(key string, units int64) {
+	if units > 0 {
+		um.add(key, usageMeterPending{
+			units: units,
+		})
+	}
+}
+
+// add adds the ump to the pending usage of the key. It returns the number of
+// the units by which the usage of the key has grown.
+func (um *UsageMeter) add(key string, ump usageMeterPending) int64 {
+	if ump == (usageMeterPending{}) {
+		return 0
+	}
+
+	um.mutex.Lock()
+	defer um.mutex.Unlock()
+
+	p := um.pending[key]
+	units := um.units(p)
+	p.units += ump.units
+	p.bytes += ump.bytes
+	p.duration += ump.duration
+	um.pending[key] = p
+
+	return um.units(p) - units
+}
+
+// units returns the number of the units of the ump.
+func (um *UsageMeter) units(ump usageMeterPending) int64 {
+	units := ump.units
+	if um.BytesPerUnit > 0 {
+		units += ump.bytes / um.BytesPerUnit
+	}
+
+	if um.DurationPerUnit > 0 {
+		units += int64(ump.duration / um.DurationPerUnit)
+	}
+
+	return units
+}
+
+// Usage returns the usage of the key, which is its total in the `Store` plus
+// its units that have not been snapshotted yet.
+func (um *UsageMeter) Usage(key string) (int64, error) {
+	total, _, err := um.total(key)
+	if err != nil {
+		return 0, err
+	}
+
+	um.mutex.Lock()
+	defer um.mutex.Unlock()
+	return total + um.units(um.pending[key]), nil
+}
+
+// UsageHandler returns a `Handler` that responds to the client with the usage
+// of its API key in JSON, such as `{"api_key":"foo","units":5}`. It responds
+// with the 401 if the client has no API key.
+func (um *UsageMeter) UsageHandler() Handler {
+	return func(req *Request, res *Response) error {
+		key := um.APIKey(req)
+		if key == "" {
+			res.Status = http.StatusUnauthorized
+			return errors.New(http.StatusText(res.Status))
+		}
+
+		units, err := um.Usage(key)
+		if err != nil {
+			return err
+		}
+
+		return res.WriteJSON(map[string]interface{}{
+			"api_key": key,
+			"units":   units,
+		})
+	}
+}
+
+// Snapshot adds the aggregated units to the totals in the `Store`. The bytes
+// and the time that do not make up a whole unit are kept for the next
+// snapshot.
+func (um *UsageMeter) Snapshot() error {
+	um.mutex.Lock()
+	pending := um.pending
+	um.pending = make(map[string]usageMeterPending, len(pending))
+	um.mutex.Unlock()
+
+	var firstErr error
+	for key, p := range pending {
+		units := um.units(p)
+
+		rest := usageMeterPending{}
+		if um.BytesPerUnit > 0 {
+			rest.bytes = p.bytes % um.BytesPerUnit
+		}
+
+		if um.DurationPerUnit > 0 {
+			rest.duration = p.duration % um.DurationPerUnit
+		}
+
+		if units <= 0 {
+			um.add(key, p)
+			continue
+		}
+
+		if err := um.addTotal(key, units); err != nil {
+			rest = p
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		um.add(key, rest)
+	}
+
+	return firstErr
+}
+
+// Start starts snapshotting in the background every `SnapshotInterval`. After
+// one call to it, subsequent calls have no effect until the `um#Stop()` is
+// called.
+func (um *UsageMeter) Start() {
+	um.mutex.Lock()
+	if um.stopChan != nil {
+		um.mutex.Unlock()
+		return
+	}
+
+	stopChan := make(chan struct{})
+	doneChan := make(chan struct{})
+	um.stopChan = stopChan
+	um.doneChan = doneChan
+	um.mutex.Unlock()
+
+	go func() {
+		defer close(doneChan)
+
+		t := time.NewTicker(um.SnapshotInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				um.snapshot()
+			case <-stopChan:
+				um.snapshot()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops snapshotting after taking a final snapshot.
+func (um *UsageMeter) Stop() {
+	um.mutex.Lock()
+	stopChan, doneChan := um.stopChan, um.doneChan
+	um.stopChan = nil
+	um.doneChan = nil
+	um.mutex.Unlock()
+
+	if stopChan != nil {
+		close(stopChan)
+		<-doneChan
+	}
+}
+
+// snapshot calls the `um#Snapshot()` and hands the error (if any) to the
+// `um#ErrorHandler`.
+func (um *UsageMeter) snapshot() {
+	if err := um.Snapshot(); err != nil && um.ErrorHandler != nil {
+		um.ErrorHandler(err)
+	}
+}
+
+// total returns the total of the key in the `Store` with its raw value.
+func (um *UsageMeter) total(key string) (int64, []byte, error) {
+	b, err := um.Store.Get(um.KeyPrefix + key)
+	if err != nil {
+		return 0, nil, err
+	} else if b == nil {
+		return 0, nil, nil
+	} else if len(b) != 8 {
+		return 0, nil, fmt.Errorf("invalid usage total of %q", key)
+	}
+
+	return int64(binary.BigEndian.Uint64(b)), b, nil
+}
+
+// addTotal adds the units to the total of the key in the `Store`. It retries
+// when the total is changed concurrently by another instance.
+func (um *UsageMeter) addTotal(key string, units int64) error {
+	for {
+		total, old, err := um.total(key)
+		if err != nil {
+			return err
+		}
+
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(total+units))
+
+		var ok bool
+		if old == nil {
+			ok, err = um.Store.SetIfAbsent(um.KeyPrefix+key, b, 0)
+		} else {
+			ok, err = um.Store.CompareAndSet(
+				um.KeyPrefix+key,
+				old,
+				b,
+				0,
+			)
+		}
+
+		if err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+	}
+}
+
+// Meter reports that the current request has consumed the units. It takes
+// effect only if the request is processed by a `UsageMeter#Gas()`.
+func (r *Request) Meter(units int64) {
+	atomic.AddInt64(&r.meteredUnits, units)
+}
@@ -0,0 +1,207 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageMeter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestUsageMeter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	um := NewUsageMeter(bs)
+	um.BytesPerUnit = 3
+
+	a := New()
+	a.Gases = []Gas{um.Gas()}
+	a.GET("/", func(req *Request, res *Response) error {
+		req.Meter(5)
+		return res.WriteString("Foobar")
+	})
+	a.GET("/usage", um.UsageHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "foo")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	units, err := um.Usage("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), units)
+
+	assert.NoError(t, um.Snapshot())
+
+	b, err := bs.Get("air:usage:foo")
+	assert.NoError(t, err)
+	assert.Len(t, b, 8)
+
+	um.Add("foo", 3)
+	units, err = um.Usage("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), units)
+
+	um2 := NewUsageMeter(bs)
+	um2.SnapshotInterval = 10 * time.Millisecond
+	um2.Start()
+	um2.Start()
+	um2.Add("foo", 1)
+	um2.Stop()
+
+	units, err = um.Usage("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), units)
+
+	req = httptest.NewRequest(http.MethodGet, "/usage", nil)
+	req.Header.Set("Authorization", "Bearer foo")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"api_key":"foo","units":11}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/usage", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestUsageMeterQuota(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestUsageMeterQuota")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	warned := make(chan float64, 2)
+
+	um := NewUsageMeter(bs)
+	um.Quota = func(string) int64 {
+		return 10
+	}
+	um.WarningHandler = func(key string, usage, quota int64, th float64) {
+		assert.Equal(t, "foo", key)
+		assert.Equal(t, int64(10), quota)
+		warned <- th
+	}
+
+	a := New()
+	a.Gases = []Gas{um.Gas()}
+	a.GET("/", func(req *Request, res *Response) error {
+		req.Meter(4)
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "foo")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("X-Quota-Limit"))
+	assert.Equal(t, "10", rec.Header().Get("X-Quota-Remaining"))
+	assert.Empty(t, rec.Header().Get("X-RateLimit-Warning"))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "foo")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "6", rec.Header().Get("X-Quota-Remaining"))
+
+	select {
+	case th := <-warned:
+		assert.Equal(t, 0.8, th)
+	case <-time.After(time.Second):
+		t.Fatal("warning handler not called")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "foo")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-Quota-Remaining"))
+	assert.Equal(
+		t,
+		"80% of the quota used",
+		rec.Header().Get("X-RateLimit-Warning"),
+	)
+
+	select {
+	case th := <-warned:
+		assert.Equal(t, 0.9, th)
+	case <-time.After(time.Second):
+		t.Fatal("warning handler not called")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "foo")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-Quota-Remaining"))
+}
+
+func TestUsageMeterFractionalUnits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestUsageMeterFractionalUnits")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	um := NewUsageMeter(bs)
+	um.BytesPerUnit = 4
+
+	a := New()
+	a.Gases = []Gas{um.Gas()}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("ab")
+	})
+
+	get := func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "foo")
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		get()
+	}
+
+	units, err := um.Usage("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), units)
+
+	// The remaining bytes are kept for the next snapshot.
+	assert.NoError(t, um.Snapshot())
+	get()
+
+	units, err = um.Usage("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), units)
+
+	assert.NoError(t, um.Snapshot())
+	assert.Empty(t, um.pending)
+}
@@ -0,0 +1,346 @@
+package air
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsGasConfig is a set of configurations for the `MetricsGas()`.
+type MetricsGasConfig struct {
+	// Path is the path of the metrics endpoint.
+	//
+	// If it is empty, the "/metrics" will be used.
+	Path string
+
+	// Buckets is the upper bounds in seconds of the buckets of the latency
+	// histogram. They must be sorted in increasing order.
+	//
+	// If it is nil, the [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5,
+	// 5, 10] will be used.
+	Buckets []float64
+
+	// Tenant returns the tenant of a request, such as the ID of the
+	// customer resolved from its host or its API key. The observations
+	// are additionally labeled by it as "tenant".
+	//
+	// If it is nil, the observations will not be labeled by tenant.
+	Tenant func(req *Request) string
+
+	// TenantLimit is the maximum number of the distinct tenants labeled.
+	// The tenants beyond it are labeled as the `TenantOther`.
+	//
+	// If it is not positive, the 100 will be used.
+	TenantLimit int
+
+	// CircuitBreakers is the `CircuitBreaker`s whose circuits are also
+	// exposed as the "circuit_breaker_state" and the
+	// "circuit_breaker_rejections_total" labeled by the route.
+	CircuitBreakers []*CircuitBreaker
+}
+
+// MetricsGas returns a `Gas` that observes the latency of every request it
+// processes into the histogram "http_request_duration_seconds" labeled by the
+// method, the route path and the status code (and the tenant if the
+// `mgc.Tenant` is not nil), and serves it in the Prometheus text format at the
+// `mgc.Path`.
+//
+// If a request carries a `TraceContext` (such as when the `TracingGas()` is
+// also used), its trace ID is attached to the observation as an exemplar, so
+// that a latency spike on a dashboard links to example traces. The exemplars
+// are only exposed to the scrapers that accept the OpenMetrics format
+// "application/openmetrics-text", since the classic text format does not
+// support them.
+//
+// It is meant to be used as a pregas, such as
+// `a.Pregases = append(a.Pregases, MetricsGas(mgc))`, so that the latency of
+// all the other gases is observed.
+func MetricsGas(mgc MetricsGasConfig) Gas {
+	if mgc.Path == "" {
+		mgc.Path = "/metrics"
+	}
+
+	if mgc.Buckets == nil {
+		mgc.Buckets = []float64{
+			0.005,
+			0.01,
+			0.025,
+			0.05,
+			0.1,
+			0.25,
+			0.5,
+			1,
+			2.5,
+			5,
+			10,
+		}
+	}
+
+	ms := &metricsStore{
+		buckets:    mgc.Buckets,
+		tenanted:   mgc.Tenant != nil,
+		histograms: map[metricsLabels]*metricsHistogram{},
+		breakers:   mgc.CircuitBreakers,
+	}
+
+	tl := newTenantLimiter(mgc.TenantLimit)
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			p, _ := splitPathQuery(req.Path)
+			if req.Method == http.MethodGet && p == mgc.Path {
+				return ms.serve(req, res)
+			}
+
+			started := time.Now()
+			err := next(req, res)
+
+			ml := metricsLabels{
+				method: req.Method,
+				code:   strconv.Itoa(res.Status),
+			}
+			if req.route != nil {
+				ml.route = req.route.Path
+			}
+
+			if mgc.Tenant != nil {
+				ml.tenant = tl.limited(mgc.Tenant(req))
+			}
+
+			traceID := ""
+			if tc := req.TraceContext(); tc != nil {
+				traceID = tc.TraceID
+			}
+
+			ms.observe(ml, time.Since(started).Seconds(), traceID)
+
+			return err
+		}
+	}
+}
+
+// metricsLabels is the labels of a `metricsHistogram`.
+type metricsLabels struct {
+	method string
+	route  string
+	code   string
+	tenant string
+}
+
+// metricsExemplar is an OpenMetrics exemplar of a bucket.
+type metricsExemplar struct {
+	traceID   string
+	value     float64
+	timestamp time.Time
+}
+
+// metricsHistogram is a latency histogram.
+type metricsHistogram struct {
+	counts    []uint64
+	exemplars []*metricsExemplar
+	count     uint64
+	sum       float64
+}
+
+// metricsStore is the store of the `metricsHistogram`s of a `MetricsGas()`.
+type metricsStore struct {
+	sync.Mutex
+
+	buckets    []float64
+	tenanted   bool
+	histograms map[metricsLabels]*metricsHistogram
+	breakers   []*CircuitBreaker
+}
+
+// observe observes the seconds into the histogram of the ml with the exemplar
+// of the traceID (if any).
+func (ms *metricsStore) observe(ml metricsLabels, s float64, traceID string) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	mh := ms.histograms[ml]
+	if mh == nil {
+		mh = &metricsHistogram{
+			counts:    make([]uint64, len(ms.buckets)+1),
+			exemplars: make([]*metricsExemplar, len(ms.buckets)+1),
+		}
+		ms.histograms[ml] = mh
+	}
+
+	i := sort.SearchFloat64s(ms.buckets, s)
+	mh.counts[i]++
+	mh.count++
+	mh.sum += s
+	if traceID != "" {
+		mh.exemplars[i] = &metricsExemplar{
+			traceID:   traceID,
+			value:     s,
+			timestamp: time.Now(),
+		}
+	}
+}
+
+// serve responds to the client with the exposition of the ms. The OpenMetrics
+// format is used if the client accepts it.
+func (ms *metricsStore) serve(req *Request, res *Response) error {
+	om := strings.Contains(
+		req.Header.Get("Accept"),
+		"application/openmetrics-text",
+	)
+
+	ct := "text/plain; version=0.0.4; charset=utf-8"
+	if om {
+		ct = "application/openmetrics-text; version=1.0.0; " +
+			"charset=utf-8"
+	}
+
+	res.Header.Set("Content-Type", ct)
+
+	return res.Write(bytes.NewReader(ms.expose(om)))
+}
+
+// expose returns the exposition of the ms in the OpenMetrics format if the om
+// is true, or in the Prometheus text format otherwise.
+func (ms *metricsStore) expose(om bool) []byte {
+	ms.Lock()
+	defer ms.Unlock()
+
+	mls := make([]metricsLabels, 0, len(ms.histograms))
+	for ml := range ms.histograms {
+		mls = append(mls, ml)
+	}
+
+	sort.Slice(mls, func(i, j int) bool {
+		if mls[i].method != mls[j].method {
+			return mls[i].method < mls[j].method
+		} else if mls[i].route != mls[j].route {
+			return mls[i].route < mls[j].route
+		} else if mls[i].code != mls[j].code {
+			return mls[i].code < mls[j].code
+		}
+
+		return mls[i].tenant < mls[j].tenant
+	})
+
+	const name = "http_request_duration_seconds"
+
+	buf := bytes.Buffer{}
+	buf.WriteString(
+		"# HELP " + name + " The latency of the HTTP requests.\n",
+	)
+	buf.WriteString("# TYPE " + name + " histogram\n")
+	for _, ml := range mls {
+		mh := ms.histograms[ml]
+		ls := fmt.Sprintf(
+			`method="%s",route="%s",code="%s"`,
+			escapeMetricsLabelValue(ml.method),
+			escapeMetricsLabelValue(ml.route),
+			escapeMetricsLabelValue(ml.code),
+		)
+		if ms.tenanted {
+			ls += fmt.Sprintf(
+				`,tenant="%s"`,
+				escapeMetricsLabelValue(ml.tenant),
+			)
+		}
+
+		cumulative := uint64(0)
+		for i, c := range mh.counts {
+			cumulative += c
+
+			le := "+Inf"
+			if i < len(ms.buckets) {
+				le = strconv.FormatFloat(
+					ms.buckets[i],
+					'g',
+					-1,
+					64,
+				)
+			}
+
+			fmt.Fprintf(
+				&buf,
+				"%s_bucket{%s,le=\"%s\"} %d",
+				name,
+				ls,
+				le,
+				cumulative,
+			)
+
+			if e := mh.exemplars[i]; om && e != nil {
+				fmt.Fprintf(
+					&buf,
+					" # {trace_id=\"%s\"} %s %.3f",
+					escapeMetricsLabelValue(e.traceID),
+					strconv.FormatFloat(
+						e.value,
+						'g',
+						-1,
+						64,
+					),
+					float64(e.timestamp.UnixNano())/1e9,
+				)
+			}
+
+			buf.WriteByte('\n')
+		}
+
+		fmt.Fprintf(
+			&buf,
+			"%s_sum{%s} %s\n",
+			name,
+			ls,
+			strconv.FormatFloat(mh.sum, 'g', -1, 64),
+		)
+		fmt.Fprintf(&buf, "%s_count{%s} %d\n", name, ls, mh.count)
+	}
+
+	// The circuit breakers share the metric families, so that each family
+	// is described only once.
+	if len(ms.breakers) > 0 {
+		const (
+			state      = "circuit_breaker_state"
+			rejections = "circuit_breaker_rejections"
+		)
+
+		rejectionsType := rejections + "_total"
+		if om {
+			rejectionsType = rejections
+		}
+
+		buf.WriteString("# HELP " + state + " The state of the " +
+			"circuits (0 for closed, 1 for open and 2 for " +
+			"half-open).\n")
+		buf.WriteString("# TYPE " + state + " gauge\n")
+		for _, cb := range ms.breakers {
+			cb.exposeStates(&buf)
+		}
+
+		buf.WriteString("# HELP " + rejectionsType + " The requests " +
+			"rejected by the open circuits.\n")
+		buf.WriteString("# TYPE " + rejectionsType + " counter\n")
+		for _, cb := range ms.breakers {
+			cb.exposeRejections(&buf)
+		}
+	}
+
+	if om {
+		buf.WriteString("# EOF\n")
+	}
+
+	return buf.Bytes()
+}
+
+// escapeMetricsLabelValue escapes the label value s for the exposition.
+func escapeMetricsLabelValue(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,122 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsGas(t *testing.T) {
+	a := New()
+	a.Pregases = []Gas{MetricsGas(MetricsGasConfig{
+		Buckets: []float64{0.5, 1},
+	})}
+	a.Gases = []Gas{TracingGas(TracingGasConfig{})}
+	a.GET("/users/:id", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(
+		"traceparent",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(
+		t,
+		"text/plain; version=0.0.4; charset=utf-8",
+		rec.Header().Get("Content-Type"),
+	)
+	assert.Contains(
+		t,
+		rec.Body.String(),
+		`http_request_duration_seconds_bucket{method="GET",`+
+			`route="/users/:id",code="200",le="0.5"} 1`+"\n",
+	)
+	assert.Contains(
+		t,
+		rec.Body.String(),
+		`http_request_duration_seconds_count{method="GET",`+
+			`route="/users/:id",code="200"} 1`+"\n",
+	)
+	assert.NotContains(t, rec.Body.String(), "trace_id")
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set(
+		"Accept",
+		"application/openmetrics-text; version=1.0.0",
+	)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(
+		t,
+		"application/openmetrics-text; version=1.0.0; charset=utf-8",
+		rec.Header().Get("Content-Type"),
+	)
+	assert.Regexp(
+		t,
+		`le="0\.5"\} 1 `+
+			`# \{trace_id="0af7651916cd43dd8448eb211c80319c"\} `+
+			`[0-9.e-]+ [0-9]+\.[0-9]{3}\n`,
+		rec.Body.String(),
+	)
+	assert.Contains(t, rec.Body.String(), "# EOF\n")
+}
+
+func TestMetricsGasTenant(t *testing.T) {
+	a := New()
+	a.Pregases = []Gas{MetricsGas(MetricsGasConfig{
+		Tenant: func(req *Request) string {
+			return req.Header.Get("X-Tenant")
+		},
+		TenantLimit: 2,
+	})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	for _, tenant := range []string{
+		"acme",
+		"globex",
+		"initech",
+		"umbrella",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	for tenant, count := range map[string]int{
+		"acme":   1,
+		"globex": 1,
+		"other":  2,
+	} {
+		assert.Contains(
+			t,
+			rec.Body.String(),
+			`http_request_duration_seconds_count{method="GET",`+
+				`route="/",code="200",tenant="`+tenant+`"} `+
+				strconv.Itoa(count)+"\n",
+		)
+	}
+
+	assert.NotContains(t, rec.Body.String(), "initech")
+}
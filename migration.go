@@ -0,0 +1,208 @@
+package air
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MigrationGasConfig is a set of configurations for the `MigrationGas()`.
+type MigrationGasConfig struct {
+	// Version returns the API version that a request is written for.
+	//
+	// If it is nil, the value of the "API-Version" header will be used.
+	Version func(req *Request) string
+
+	// Migrations is the `RequestMigration`s sorted from the oldest API
+	// version to the newest. A request written for the API version of one
+	// of them is migrated by it and all the ones after it in order.
+	Migrations []RequestMigration
+}
+
+// RequestMigration is a migration that rewrites the requests written for an
+// API version to the shape of the next API version.
+//
+// The fields are the members of the JSON request bodies, and the nested ones
+// are referenced by their dotted paths such as the "user.name".
+type RequestMigration struct {
+	// Version is the API version whose requests are migrated.
+	Version string
+
+	// QueryToBody is the names of the query params that are moved into
+	// the request bodies as the fields of the same names. The request
+	// bodies are created if they are absent.
+	QueryToBody []string
+
+	// RenamedFields is the new names of the fields keyed by their old
+	// names.
+	RenamedFields map[string]string
+
+	// DateFields is the layouts of the dates of the fields, such as the
+	// "01/02/2006". The dates are converted to the RFC 3339.
+	DateFields map[string]string
+}
+
+// MigrationGas returns a `Gas` that rewrites the legacy requests it processes
+// to the shape of the current API with the mgc, so that the handlers only deal
+// with the current API. It is usually used per route, such as
+// `a.POST("/users", h, MigrationGas(mgc))`.
+//
+// The requests with bodies other than JSON are left untouched, and the ones
+// with malformed JSON bodies are rejected with the 400.
+func MigrationGas(mgc MigrationGasConfig) Gas {
+	if mgc.Version == nil {
+		mgc.Version = func(req *Request) string {
+			return req.Header.Get("API-Version")
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			v := mgc.Version(req)
+			if v == "" {
+				return next(req, res)
+			}
+
+			for i, rm := range mgc.Migrations {
+				if rm.Version != v {
+					continue
+				}
+
+				err := migrateRequest(req, mgc.Migrations[i:])
+				if err != nil {
+					res.Status = http.StatusBadRequest
+					return err
+				}
+
+				break
+			}
+
+			return next(req, res)
+		}
+	}
+}
+
+// migrateRequest migrates the req by using the rms in order.
+func migrateRequest(req *Request, rms []RequestMigration) error {
+	p, q := splitPathQuery(req.Path)
+	qvs, err := url.ParseQuery(q)
+	if err != nil {
+		return errors.New("invalid query")
+	}
+
+	var body map[string]interface{}
+	hasBody := req.ContentLength != 0 && req.Body != nil
+	if hasBody {
+		mt, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if mt != "application/json" {
+			return nil
+		}
+
+		d := json.NewDecoder(req.Body)
+		d.UseNumber()
+		if err := d.Decode(&body); err != nil {
+			return errors.New("invalid json body")
+		}
+	}
+
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+
+	for _, rm := range rms {
+		for _, n := range rm.QueryToBody {
+			if vs, ok := qvs[n]; ok {
+				body[n] = vs[0]
+				delete(qvs, n)
+			}
+		}
+
+		for o, n := range rm.RenamedFields {
+			if v, ok := takeMigrationField(body, o); ok {
+				putMigrationField(body, n, v)
+			}
+		}
+
+		for f, layout := range rm.DateFields {
+			v, ok := takeMigrationField(body, f)
+			if !ok {
+				continue
+			}
+
+			if s, ok := v.(string); ok {
+				t, err := time.Parse(layout, s)
+				if err != nil {
+					return errors.New("invalid date: " + s)
+				}
+
+				v = t.Format(time.RFC3339)
+			}
+
+			putMigrationField(body, f, v)
+		}
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req.Path = p
+	if q := qvs.Encode(); q != "" {
+		req.Path += "?" + q
+	}
+
+	if hasBody || len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Length", strconv.Itoa(len(b)))
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		req.ContentLength = int64(len(b))
+	}
+
+	req.HTTPRequest()
+
+	return nil
+}
+
+// takeMigrationField takes the field at the dotted path out of the m.
+func takeMigrationField(
+	m map[string]interface{},
+	path string,
+) (interface{}, bool) {
+	ks := strings.Split(path, ".")
+	for _, k := range ks[:len(ks)-1] {
+		var ok bool
+		if m, ok = m[k].(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+
+	v, ok := m[ks[len(ks)-1]]
+	delete(m, ks[len(ks)-1])
+
+	return v, ok
+}
+
+// putMigrationField puts the v into the m as the field at the dotted path. The
+// missing parent objects are created.
+func putMigrationField(m map[string]interface{}, path string, v interface{}) {
+	ks := strings.Split(path, ".")
+	for _, k := range ks[:len(ks)-1] {
+		pm, ok := m[k].(map[string]interface{})
+		if !ok {
+			pm = map[string]interface{}{}
+			m[k] = pm
+		}
+
+		m = pm
+	}
+
+	m[ks[len(ks)-1]] = v
+}
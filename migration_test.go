@@ -0,0 +1,103 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationGas(t *testing.T) {
+	a := New()
+
+	mg := MigrationGas(MigrationGasConfig{
+		Migrations: []RequestMigration{
+			{
+				Version: "2019-01-01",
+				RenamedFields: map[string]string{
+					"fullname": "name",
+				},
+				DateFields: map[string]string{
+					"born": "01/02/2006",
+				},
+			},
+			{
+				Version:     "2020-01-01",
+				QueryToBody: []string{"team"},
+				RenamedFields: map[string]string{
+					"name": "profile.name",
+				},
+			},
+		},
+	})
+
+	var body, query string
+	a.POST("/users", func(req *Request, res *Response) error {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+
+		body = string(b)
+		if p := req.Param("team"); p != nil {
+			query = p.Value().String()
+		}
+
+		return res.WriteString("Foobar")
+	}, mg)
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/users?team=air&page=1",
+		strings.NewReader(`{"fullname":"Foo","born":"12/31/1999"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("API-Version", "2019-01-01")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(
+		t,
+		`{"born":"1999-12-31T00:00:00Z","profile":{"name":"Foo"},`+
+			`"team":"air"}`,
+		body,
+	)
+	assert.Empty(t, query)
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/users?team=air",
+		strings.NewReader(`{"name":"Foo"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"name":"Foo"}`, body)
+	assert.Equal(t, "air", query)
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/users",
+		strings.NewReader(`{"born":"1999-12-31"}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("API-Version", "2019-01-01")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/users",
+		strings.NewReader(`{`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("API-Version", "2020-01-01")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
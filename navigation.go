@@ -0,0 +1,160 @@
+package air
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Breadcrumb is an item of a breadcrumb trail.
+type Breadcrumb struct {
+	// Title is the `Route.Title` of the current breadcrumb.
+	Title string
+
+	// URL is the URL path of the current breadcrumb.
+	URL string
+
+	// Active indicates whether the current breadcrumb is the matched route
+	// of the request.
+	Active bool
+}
+
+// Route returns the route matched by the r. It returns nil if not matched.
+func (r *Request) Route() *Route {
+	return r.route
+}
+
+// Breadcrumbs returns the breadcrumb trail of the route matched by the r. It
+// consists of the GET routes whose paths are the leading elements of the path
+// of the matched route (such as "/", "/users" and "/users/:id" for the
+// "/users/:id/posts"), followed by the matched route itself. The route params
+// of the r are filled into their param names.
+//
+// Since the route paths of a `Group` share its prefix, the group hierarchy is
+// reflected as long as a GET route is registered for the prefix of each group.
+func (r *Request) Breadcrumbs() []*Breadcrumb {
+	if r.route == nil {
+		return nil
+	}
+
+	r.Air.router.Lock()
+	defer r.Air.router.Unlock()
+
+	var bcs []*Breadcrumb
+	for i, p := 0, r.route.Path; i < len(p); i++ {
+		if i > 0 && p[i] != '/' {
+			continue
+		}
+
+		pp := p[:i]
+		if i == 0 {
+			pp = "/"
+		}
+
+		for _, route := range r.Air.router.routes {
+			if route.Method == http.MethodGet && route.Path == pp {
+				if bc := r.breadcrumb(route); bc != nil {
+					bcs = append(bcs, bc)
+				}
+
+				break
+			}
+		}
+	}
+
+	if r.route.Path != "/" || len(bcs) == 0 {
+		if bc := r.breadcrumb(r.route); bc != nil {
+			bcs = append(bcs, bc)
+		}
+	}
+
+	if len(bcs) > 0 {
+		bcs[len(bcs)-1].Active = true
+	}
+
+	return bcs
+}
+
+// breadcrumb returns the `Breadcrumb` of the route with the route params of the
+// r filled into its param names. It returns nil if any of the route params is
+// missing.
+func (r *Request) breadcrumb(route *Route) *Breadcrumb {
+	var params []interface{}
+	for _, e := range strings.Split(route.Path, "/") {
+		if e == "" || (e[0] != ':' && e[0] != '*') {
+			continue
+		}
+
+		name := e[1:]
+		if e[0] == '*' && name == "" {
+			name = "*"
+		}
+
+		p := r.Param(name)
+		if p == nil {
+			return nil
+		}
+
+		params = append(params, p.Value().String())
+	}
+
+	bc := &Breadcrumb{
+		Title: route.Title,
+		URL:   route.url(params...),
+	}
+
+	if bc.Title == "" {
+		bc.Title = bc.URL[strings.LastIndexByte(bc.URL, '/')+1:]
+		if t, err := url.PathUnescape(bc.Title); err == nil {
+			bc.Title = t
+		}
+
+		if bc.Title == "" {
+			bc.Title = "/"
+		}
+	}
+
+	return bc
+}
+
+// RouteActive reports whether the first route named the name is the route
+// matched by the r or one of its ancestors, that is, the path of the matched
+// route is under the path of the named route. It is usually used to mark the
+// active navigation items, such as the `RouteActive("main.listUsers")` reports
+// true for both the "/users" and the "/users/:id".
+//
+// The root route "/" is only active if it is matched.
+func (r *Request) RouteActive(name string) bool {
+	if r.route == nil {
+		return false
+	} else if r.route.Name == name {
+		return true
+	}
+
+	r.Air.router.Lock()
+	defer r.Air.router.Unlock()
+
+	for _, route := range r.Air.router.routes {
+		if route.Name == name {
+			return route.Path != "/" &&
+				strings.HasPrefix(r.route.Path, route.Path+"/")
+		}
+	}
+
+	return false
+}
+
+// NavigationViewData is a `ViewDataProvider` that provides the "Route" (see
+// the `Request#Route()`), the "Breadcrumbs" (see the
+// `Request#Breadcrumbs()`) and the "RouteActive" (see the
+// `Request#RouteActive()`) of the req to the HTML templates, such as
+//
+//	{{range .Breadcrumbs}}<a href="{{.URL}}">{{.Title}}</a>{{end}}
+//	<a class="{{if call .RouteActive "main.listUsers"}}active{{end}}">
+func NavigationViewData(req *Request) map[string]interface{} {
+	return map[string]interface{}{
+		"Route":       req.Route(),
+		"Breadcrumbs": req.Breadcrumbs(),
+		"RouteActive": req.RouteActive,
+	}
+}
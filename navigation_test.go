@@ -0,0 +1,110 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestBreadcrumbs(t *testing.T) {
+	a := New()
+
+	var (
+		bcs    []*Breadcrumb
+		active map[string]bool
+	)
+
+	h := func(req *Request, res *Response) error {
+		bcs = req.Breadcrumbs()
+		active = map[string]bool{}
+		for _, name := range []string{
+			"home",
+			"users",
+			"user",
+			"posts",
+		} {
+			active[name] = req.RouteActive(name)
+		}
+
+		return nil
+	}
+
+	a.GET("/", h).Name = "home"
+	users := a.GET("/users", h)
+	users.Name = "users"
+	users.Title = "Users"
+	a.GET("/users/:id", h).Name = "user"
+	a.POST("/users/:id/posts", h).Name = "posts"
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Len(t, bcs, 1)
+	assert.Equal(t, &Breadcrumb{Title: "/", URL: "/", Active: true}, bcs[0])
+	assert.Equal(t, map[string]bool{
+		"home":  true,
+		"users": false,
+		"user":  false,
+		"posts": false,
+	}, active)
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/users/foo%20bar/posts",
+		nil,
+	)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, []*Breadcrumb{
+		{Title: "/", URL: "/"},
+		{Title: "Users", URL: "/users"},
+		{Title: "foo bar", URL: "/users/foo%20bar"},
+		{Title: "posts", URL: "/users/foo%20bar/posts", Active: true},
+	}, bcs)
+	assert.Equal(t, map[string]bool{
+		"home":  false,
+		"users": true,
+		"user":  true,
+		"posts": true,
+	}, active)
+
+	req = httptest.NewRequest(http.MethodGet, "/nonexistent", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestNavigationViewData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestNavigationViewData")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "index.html"),
+		[]byte(`{{range .Breadcrumbs}}{{.URL}} {{end}}`+
+			`{{if call .RouteActive "users"}}active{{end}}`),
+		0644,
+	))
+
+	a := New()
+	a.TemplateRoot = dir
+	a.ViewDataProviders = []ViewDataProvider{NavigationViewData}
+
+	h := func(req *Request, res *Response) error {
+		return res.Render(nil, "index.html")
+	}
+
+	a.GET("/users", h).Name = "users"
+	a.GET("/users/:id", h)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/users /users/1 active", rec.Body.String())
+}
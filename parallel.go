@@ -0,0 +1,154 @@
+package air
+
+import (
+	"context"
+	"sync"
+)
+
+// Parallel is a group of tasks that run concurrently on behalf of a request,
+// such as the fetches from several upstreams whose results are merged into one
+// response. It is like the `errgroup.Group`, but bounded by the request.
+//
+// All the tasks share a context derived from the `Request#Context`, so they
+// inherit its deadline and are canceled when the request is canceled, when the
+// client's connection closes or when any of the tasks fails.
+//
+// It is created by the `Request#Parallel()`.
+type Parallel struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	sem     chan struct{}
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	err     error
+	results map[string]interface{}
+}
+
+// Parallel returns a new instance of the `Parallel` bound to the r that runs at
+// most the limit tasks at a time. There is no limit if the limit is less than
+// or equal to zero.
+//
+// The `Parallel#Wait()` must be called before the r is finished.
+func (r *Request) Parallel(limit int) *Parallel {
+	p := &Parallel{
+		results: map[string]interface{}{},
+	}
+
+	p.ctx, p.cancel = context.WithCancel(r.Context)
+	if limit > 0 {
+		p.sem = make(chan struct{}, limit)
+	}
+
+	return p
+}
+
+// Context returns the context shared by the tasks of the p.
+func (p *Parallel) Context() context.Context {
+	return p.ctx
+}
+
+// Go runs the f in a new goroutine with the context of the p. The first
+// non-nil error returned by the tasks of the p cancels the context and is
+// returned by the `p#Wait()`.
+//
+// It blocks while the limit of the p is reached. The f is not called if the
+// context of the p is done before it is started.
+func (p *Parallel) Go(f func(ctx context.Context) error) {
+	p.wg.Add(1)
+	if p.sem != nil {
+		select {
+		case p.sem <- struct{}{}:
+		case <-p.ctx.Done():
+			p.fail(p.ctx.Err())
+			p.wg.Done()
+			return
+		}
+	}
+
+	go func() {
+		defer func() {
+			if p.sem != nil {
+				<-p.sem
+			}
+
+			p.wg.Done()
+		}()
+
+		if err := p.ctx.Err(); err != nil {
+			p.fail(err)
+		} else if err := f(p.ctx); err != nil {
+			p.fail(err)
+		}
+	}()
+}
+
+// GoResult is like the `p#Go()`, but the result of the f is also collected as
+// the name once the f succeeds. See the `p#Result()` and the `p#Results()`.
+func (p *Parallel) GoResult(
+	name string,
+	f func(ctx context.Context) (interface{}, error),
+) {
+	p.Go(func(ctx context.Context) error {
+		v, err := f(ctx)
+		if err != nil {
+			return err
+		}
+
+		p.mutex.Lock()
+		p.results[name] = v
+		p.mutex.Unlock()
+
+		return nil
+	})
+}
+
+// Wait waits for all the tasks of the p to finish, then cancels the context of
+// the p and returns the first non-nil error returned by them (if any).
+func (p *Parallel) Wait() error {
+	p.wg.Wait()
+	p.cancel()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.err
+}
+
+// Result returns the result collected as the name. It returns nil if not
+// found.
+//
+// It should only be called after the `p#Wait()`.
+func (p *Parallel) Result(name string) interface{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.results[name]
+}
+
+// Results returns the results collected by the `p#GoResult()` keyed by their
+// names, which can be responded as is, such as `res.WriteJSON(p.Results())`.
+//
+// It should only be called after the `p#Wait()`.
+func (p *Parallel) Results() map[string]interface{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	rs := make(map[string]interface{}, len(p.results))
+	for n, v := range p.results {
+		rs[n] = v
+	}
+
+	return rs
+}
+
+// fail records the err if it is the first error of the p, and cancels the
+// context of the p.
+func (p *Parallel) fail(err error) {
+	p.mutex.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+
+	p.mutex.Unlock()
+	p.cancel()
+}
@@ -0,0 +1,130 @@
+package air
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestParallel(t *testing.T) {
+	a := New()
+
+	var running, maxRunning int32
+	a.GET("/", func(req *Request, res *Response) error {
+		p := req.Parallel(2)
+		for _, n := range []string{"foo", "bar", "baz"} {
+			n := n
+			p.GoResult(n, func(
+				ctx context.Context,
+			) (interface{}, error) {
+				r := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if r <= m || atomic.CompareAndSwapInt32(
+						&maxRunning,
+						m,
+						r,
+					) {
+						break
+					}
+				}
+
+				time.Sleep(10 * time.Millisecond)
+
+				return n + "!", nil
+			})
+		}
+
+		if err := p.Wait(); err != nil {
+			return err
+		}
+
+		assert.Equal(t, "foo!", p.Result("foo"))
+		assert.Nil(t, p.Result("qux"))
+
+		return res.WriteJSON(p.Results())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(
+		t,
+		`{"bar":"bar!","baz":"baz!","foo":"foo!"}`,
+		rec.Body.String(),
+	)
+	assert.Equal(t, int32(2), maxRunning)
+
+	a = New()
+	a.ErrorHandler = func(err error, req *Request, res *Response) {
+		res.Status = http.StatusInternalServerError
+		res.WriteString(err.Error())
+	}
+
+	a.GET("/", func(req *Request, res *Response) error {
+		p := req.Parallel(0)
+		p.Go(func(ctx context.Context) error {
+			return errors.New("upstream down")
+		})
+
+		p.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		err := p.Wait()
+		assert.Error(t, p.Context().Err())
+
+		return err
+	})
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "upstream down", rec.Body.String())
+
+	a = New()
+	a.ErrorHandler = func(err error, req *Request, res *Response) {
+		res.Status = http.StatusInternalServerError
+		res.WriteString(err.Error())
+	}
+
+	a.GET("/", func(req *Request, res *Response) error {
+		ctx, cancel := req.WithTimeout(10 * time.Millisecond)
+		defer cancel()
+
+		req.Context = ctx
+
+		p := req.Parallel(1)
+		p.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		called := false
+		p.Go(func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+
+		err := p.Wait()
+		assert.False(t, called)
+
+		return err
+	})
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), rec.Body.String())
+}
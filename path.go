@@ -0,0 +1,186 @@
+package air
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// PathGasConfig is a set of configurations for the `AddTrailingSlashGas()`, the
+// `RemoveTrailingSlashGas()` and the `LowercasePathGas()`.
+type PathGasConfig struct {
+	// Rewrite indicates whether to rewrite the request path internally
+	// instead of redirecting the client.
+	Rewrite bool
+
+	// RedirectCode is the status code of the redirections.
+	//
+	// If it is zero, the 301 will be used for the GET and the HEAD, and the
+	// 308 will be used for the others so that the method and the body are
+	// preserved.
+	RedirectCode int
+
+	// SafeMethodsOnly indicates whether only the requests with the safe
+	// methods (the GET, the HEAD, the OPTIONS and the TRACE) are processed.
+	SafeMethodsOnly bool
+
+	// Skipper reports whether the req should be skipped.
+	//
+	// If it is nil, no request will be skipped.
+	Skipper func(req *Request) bool
+}
+
+// AddTrailingSlashGas returns a `Gas` that adds a trailing slash to the path of
+// every request it processes with the pgc. It is meant to be used as a pregas.
+//
+// Since the registered route paths never end with "/", the
+// `Air#TrailingSlashMode` should be "rewrite" so that the paths resolve.
+func AddTrailingSlashGas(pgc PathGasConfig) Gas {
+	return pathGas(pgc, func(p string) string {
+		if strings.HasSuffix(p, "/") {
+			return p
+		}
+
+		return p + "/"
+	})
+}
+
+// RemoveTrailingSlashGas returns a `Gas` that removes the trailing slashes from
+// the path of every request it processes with the pgc. It is meant to be used
+// as a pregas.
+func RemoveTrailingSlashGas(pgc PathGasConfig) Gas {
+	return pathGas(pgc, func(p string) string {
+		if len(p) < 2 {
+			return p
+		}
+
+		return "/" + strings.TrimLeft(strings.TrimRight(p, "/"), "/")
+	})
+}
+
+// LowercasePathGas returns a `Gas` that lowercases the path of every request it
+// processes with the pgc. It is meant to be used as a pregas.
+func LowercasePathGas(pgc PathGasConfig) Gas {
+	return pathGas(pgc, strings.ToLower)
+}
+
+// pathGas returns a `Gas` that redirects or rewrites every request it processes
+// with the pgc to the path transformed by the f. The query is preserved, and
+// the leading slashes of the transformed path are collapsed to avoid
+// redirecting to a protocol-relative URL.
+func pathGas(pgc PathGasConfig, f func(string) string) Gas {
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			if pgc.Skipper != nil && pgc.Skipper(req) {
+				return next(req, res)
+			}
+
+			switch req.Method {
+			case http.MethodGet,
+				http.MethodHead,
+				http.MethodOptions,
+				http.MethodTrace:
+			default:
+				if pgc.SafeMethodsOnly {
+					return next(req, res)
+				}
+			}
+
+			p, q := splitPathQuery(req.Path)
+
+			np := f(p)
+			if np == p {
+				return next(req, res)
+			}
+
+			if strings.HasPrefix(np, "//") {
+				np = "/" + strings.TrimLeft(np, "/")
+			}
+
+			if q != "" {
+				np += "?" + q
+			}
+
+			if pgc.Rewrite {
+				req.Path = np
+				return next(req, res)
+			}
+
+			if pgc.RedirectCode == 0 {
+				return permanentRedirectHandler(np)(req, res)
+			}
+
+			res.Status = pgc.RedirectCode
+
+			return res.Redirect(np)
+		}
+	}
+}
+
+// normalizeRequestPath normalizes the path of the req before routing with the
+// `Air#PathNormalizationEnabled` and the `Air#EncodedSlashMode`. It returns an
+// error if the path contains encoded slashes that should be rejected.
+func normalizeRequestPath(req *Request) error {
+	req.rawPath = req.Path
+
+	p, q := splitPathQuery(req.Path)
+	if !strings.HasPrefix(p, "/") { // Such as "*" or an absolute-form
+		return nil
+	}
+
+	np := p
+	if strings.Contains(np, "%2F") || strings.Contains(np, "%2f") {
+		switch req.Air.EncodedSlashMode {
+		case "reject":
+			return errors.New("encoded slash in path")
+		case "decode":
+			np = strings.ReplaceAll(np, "%2F", "/")
+			np = strings.ReplaceAll(np, "%2f", "/")
+		}
+	}
+
+	if req.Air.PathNormalizationEnabled {
+		np = removeDotSegments(np)
+	}
+
+	if np != p {
+		if q != "" {
+			np += "?" + q
+		}
+
+		req.Path = np
+	}
+
+	return nil
+}
+
+// removeDotSegments resolves the "." and ".." segments (including their
+// percent-encoded forms) of the path p and collapses its duplicate slashes. See
+// RFC 3986, section 5.2.4.
+func removeDotSegments(p string) string {
+	segments := strings.Split(p[1:], "/")
+	ss := make([]string, 0, len(segments))
+	trailingSlash := false
+	for i, s := range segments {
+		switch strings.ToLower(s) {
+		case "", ".", "%2e":
+		case "..", ".%2e", "%2e.", "%2e%2e":
+			if len(ss) > 0 {
+				ss = ss[:len(ss)-1]
+			}
+		default:
+			ss = append(ss, s)
+			trailingSlash = false
+			continue
+		}
+
+		trailingSlash = i == len(segments)-1
+	}
+
+	np := "/" + strings.Join(ss, "/")
+	if trailingSlash && len(ss) > 0 {
+		np += "/"
+	}
+
+	return np
+}
@@ -0,0 +1,211 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveTrailingSlashGas(t *testing.T) {
+	a := New()
+	a.Pregases = []Gas{RemoveTrailingSlashGas(PathGasConfig{})}
+	a.GET("/foo", func(req *Request, res *Response) error {
+		return res.WriteString(req.Path)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/foo/?bar=baz", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/foo?bar=baz", rec.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodPost, "/foo/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "//example.com/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/example.com", rec.Header().Get("Location"))
+
+	a.Pregases = []Gas{RemoveTrailingSlashGas(PathGasConfig{
+		Rewrite: true,
+	})}
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/foo", rec.Body.String())
+}
+
+func TestAddTrailingSlashGas(t *testing.T) {
+	a := New()
+	a.Pregases = []Gas{AddTrailingSlashGas(PathGasConfig{
+		RedirectCode:    http.StatusFound,
+		SafeMethodsOnly: true,
+		Skipper: func(req *Request) bool {
+			return strings.HasPrefix(req.Path, "/api")
+		},
+	})}
+	a.TrailingSlashMode = "rewrite"
+	a.GET("/foo", func(req *Request, res *Response) error {
+		return res.WriteString(req.Path)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/foo/", rec.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/foo", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestLowercasePathGas(t *testing.T) {
+	a := New()
+	a.Pregases = []Gas{LowercasePathGas(PathGasConfig{})}
+	a.GET("/foo", func(req *Request, res *Response) error {
+		return res.WriteString(req.Path)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/FOO?Bar=Baz", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/foo?Bar=Baz", rec.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/foo", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestNormalizeRequestPath(t *testing.T) {
+	a := New()
+	a.GET("/foo/bar", func(req *Request, res *Response) error {
+		return res.WriteString(req.Path + " " + req.RawPath())
+	})
+	a.GET("/foo/:bar", func(req *Request, res *Response) error {
+		return res.WriteString(req.Param("bar").Value().String())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/foo/./baz/../bar", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	a.PathNormalizationEnabled = true
+
+	for _, p := range []string{
+		"/foo/./baz/../bar?q",
+		"/foo//baz/%2e%2E/bar?q",
+		"/../../foo/bar?q",
+	} {
+		req = httptest.NewRequest(http.MethodGet, p, nil)
+		rec = httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, p)
+		assert.Equal(t, "/foo/bar?q "+p, rec.Body.String())
+	}
+
+	assert.Equal(t, "/", removeDotSegments("/a/.."))
+	assert.Equal(t, "/a/", removeDotSegments("/a/b/../"))
+	assert.Equal(t, "/a/", removeDotSegments("/a/."))
+	assert.Equal(t, "/a/b", removeDotSegments("//a///b"))
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/a%2Fb", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "a/b", rec.Body.String())
+
+	a.EncodedSlashMode = "reject"
+
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	a.EncodedSlashMode = "decode"
+
+	req = httptest.NewRequest(http.MethodGet, "/foo%2Fbaz%2F..%2Fbar", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(
+		t,
+		"/foo/bar /foo%2Fbaz%2F..%2Fbar",
+		rec.Body.String(),
+	)
+}
+
+func TestFILESTraversal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestFILESTraversal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "public")
+	assert.NoError(t, os.Mkdir(root, 0755))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(root, "foo.txt"),
+		[]byte("Foobar"),
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "secret.txt"),
+		[]byte("Secret"),
+		0644,
+	))
+
+	a := New()
+	a.FILES("/static", root)
+
+	for _, enabled := range []bool{false, true} {
+		a.PathNormalizationEnabled = enabled
+		a.EncodedSlashMode = "decode"
+
+		req := httptest.NewRequest(
+			http.MethodGet,
+			"/static/foo.txt",
+			nil,
+		)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Foobar", rec.Body.String())
+
+		for _, p := range []string{
+			"/static/../secret.txt",
+			"/static/%2e%2e/secret.txt",
+			"/static/..%2Fsecret.txt",
+			"/static/%2E%2E%2Fsecret.txt",
+			"/static/foo/../../secret.txt",
+		} {
+			req := httptest.NewRequest(http.MethodGet, p, nil)
+			rec := httptest.NewRecorder()
+			a.server.ServeHTTP(rec, req)
+			assert.NotEqual(t, "Secret", rec.Body.String(), p)
+		}
+	}
+}
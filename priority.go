@@ -0,0 +1,88 @@
+package air
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Priority is the priority of a response signaled by the client with the
+// "Priority" header. See https://www.rfc-editor.org/rfc/rfc9218.
+type Priority struct {
+	// Urgency is the urgency of the response from 0 to 7, where the lower
+	// is the more urgent. The default value is 3.
+	Urgency int
+
+	// Incremental indicates whether the response can be processed
+	// incrementally by the client, such as a progressive image.
+	Incremental bool
+}
+
+// DefaultPriority is the `Priority` of the responses whose clients do not
+// signal one.
+var DefaultPriority = Priority{
+	Urgency: 3,
+}
+
+// ParsePriority parses the s as the value of a "Priority" header, such as
+// "u=1, i". The missing or invalid parameters take the values of the
+// `DefaultPriority`, and the unknown ones are ignored.
+func ParsePriority(s string) Priority {
+	p := DefaultPriority
+	for _, m := range strings.Split(s, ",") {
+		if i := strings.IndexByte(m, ';'); i >= 0 {
+			m = m[:i]
+		}
+
+		k, v := strings.TrimSpace(m), ""
+		if i := strings.IndexByte(k, '='); i >= 0 {
+			k, v = k[:i], k[i+1:]
+		}
+
+		switch k {
+		case "u":
+			if u, err := strconv.Atoi(v); err == nil &&
+				u >= 0 &&
+				u <= 7 {
+				p.Urgency = u
+			}
+		case "i":
+			switch v {
+			case "", "?1":
+				p.Incremental = true
+			case "?0":
+				p.Incremental = false
+			}
+		}
+	}
+
+	return p
+}
+
+// String returns the serialized form of the p as the value of a "Priority"
+// header, such as "u=1, i".
+func (p Priority) String() string {
+	s := "u=" + strconv.Itoa(p.Urgency)
+	if p.Incremental {
+		s += ", i"
+	}
+
+	return s
+}
+
+// priorityKey is the key of the `Priority` in a `context.Context`.
+type priorityKey struct{}
+
+// ContextWithPriority returns a copy of the ctx which carries the p. It can be
+// used to override the `Priority` of a request before it is scheduled, such as
+// `req.Context = ContextWithPriority(req.Context, p)`.
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the `Priority` carried by the ctx. It returns
+// false if not found.
+func PriorityFromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	return p, ok
+}
@@ -0,0 +1,51 @@
+package air
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePriority(t *testing.T) {
+	assert.Equal(t, DefaultPriority, ParsePriority(""))
+	assert.Equal(t, Priority{Urgency: 1}, ParsePriority("u=1"))
+	assert.Equal(
+		t,
+		Priority{Urgency: 0, Incremental: true},
+		ParsePriority("u=0, i"),
+	)
+	assert.Equal(
+		t,
+		Priority{Urgency: 5, Incremental: true},
+		ParsePriority("i=?1;foo=bar, u=5"),
+	)
+	assert.Equal(t, Priority{Urgency: 3}, ParsePriority("u=8, i=?0"))
+	assert.Equal(t, Priority{Urgency: 3}, ParsePriority("u=foo, x=1"))
+
+	assert.Equal(t, "u=3", DefaultPriority.String())
+	assert.Equal(t, "u=1, i", Priority{1, true}.String())
+}
+
+func TestRequestPriority(t *testing.T) {
+	a := New()
+
+	hr := httptest.NewRequest(http.MethodGet, "/", nil)
+	hr.Header.Set("Priority", "u=1, i")
+	req := &Request{
+		Air:     a,
+		Header:  hr.Header,
+		Context: context.Background(),
+	}
+
+	assert.Equal(t, Priority{1, true}, req.Priority())
+
+	req.Context = ContextWithPriority(req.Context, Priority{Urgency: 7})
+	assert.Equal(t, Priority{Urgency: 7}, req.Priority())
+
+	p, ok := PriorityFromContext(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, Priority{}, p)
+}
@@ -1,24 +1,41 @@
 package air
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"golang.org/x/text/number"
 )
 
-// renderer is a renderer for rendering HTML templates.
+// Renderer is used to render HTML templates. It can be implemented to plug
+// third-party template engines (such as pongo2, jet or quicktemplate) into the
+// `Air#Renderer`.
+//
+// The names of the form "<template>#<fragment>" are used to render fragments,
+// see the `Response#RenderFragment()`.
+type Renderer interface {
+	// Render renders the data into the w for the HTML template name. The
+	// req is the request being responded and may be nil.
+	Render(w io.Writer, name string, data interface{}, req *Request) error
+}
+
+// renderer is a renderer for rendering HTML templates based on the
+// html/template.
 type renderer struct {
 	a        *Air
 	template *template.Template
 	watcher  *fsnotify.Watcher
 	once     *sync.Once
+	mutex    sync.Mutex
 }
 
 // newRenderer returns a new instance of the `renderer` with the a.
@@ -47,7 +64,7 @@ func newRenderer(a *Air) *renderer {
 						"event": e.Op.String(),
 					},
 				)
-				r.once = &sync.Once{}
+				r.reset()
 			case err := <-r.watcher.Errors:
 				a.ERROR(
 					"air: renderer watcher error",
@@ -62,14 +79,18 @@ func newRenderer(a *Air) *renderer {
 	return r
 }
 
-// render renders the v into the w for the HTML template name.
-func (r *renderer) render(
+// Render implements the `Renderer`.
+func (r *renderer) Render(
 	w io.Writer,
 	name string,
 	v interface{},
-	locstr func(string) string,
+	req *Request,
 ) error {
-	r.once.Do(func() {
+	r.mutex.Lock()
+	once := r.once
+	r.mutex.Unlock()
+
+	once.Do(func() {
 		tr, err := filepath.Abs(r.a.TemplateRoot)
 		if err != nil {
 			r.a.ERROR(
@@ -83,13 +104,43 @@ func (r *renderer) render(
 			return
 		}
 
-		r.template = template.
+		tmpl := template.
 			New("template").
 			Delims(r.a.TemplateLeftDelim, r.a.TemplateRightDelim).
 			Funcs(template.FuncMap{
 				"locstr": func(key string) string {
 					return key
 				},
+				"locstrf": func(
+					key string,
+					args ...interface{},
+				) string {
+					return fmt.Sprintf(key, args...)
+				},
+				"loctimefmt": func(
+					t time.Time,
+					layout string,
+				) string {
+					l := baseLocation(r.a)
+					return t.In(l).Format(layout)
+				},
+				"locnum": func(n interface{}) string {
+					return basePrinter(r.a).Sprint(
+						number.Decimal(n),
+					)
+				},
+				"loccurrency": func(
+					amount float64,
+					code string,
+				) string {
+					return formatCurrency(
+						basePrinter(r.a),
+						amount,
+						code,
+					)
+				},
+				"partial": r.partial,
+				"url":     r.a.URL,
 			}).
 			Funcs(r.a.TemplateFuncMap)
 		if err := filepath.Walk(
@@ -113,7 +164,7 @@ func (r *renderer) render(
 							return err
 						}
 
-						if _, err := r.template.New(
+						if _, err := tmpl.New(
 							filepath.ToSlash(
 								fn[len(tr)+1:],
 							),
@@ -133,27 +184,95 @@ func (r *renderer) render(
 				},
 			)
 		}
+
+		r.mutex.Lock()
+		r.template = tmpl
+		r.mutex.Unlock()
 	})
 
-	t := r.template.Lookup(name)
+	tmpl := r.loadedTemplate()
+	if tmpl == nil {
+		return fmt.Errorf("html/template: %q is undefined", name)
+	}
+
+	t := tmpl.Lookup(name)
+	if i := strings.IndexByte(name, '#'); t == nil && i >= 0 {
+		t = tmpl.Lookup(name[i+1:])
+	}
+
 	if t == nil {
 		return fmt.Errorf("html/template: %q is undefined", name)
 	}
 
-	if r.a.I18nEnabled {
+	if r.a.I18nEnabled && req != nil {
 		t, err := t.Clone()
 		if err != nil {
 			return err
 		}
 
 		return t.Funcs(template.FuncMap{
-			"locstr": locstr,
+			"locstr":      req.LocalizedString,
+			"locstrf":     req.LocalizedStringf,
+			"loctimefmt":  req.FormatTime,
+			"locnum":      req.FormatNumber,
+			"loccurrency": req.FormatCurrency,
+			"partial": func(
+				name string,
+				data interface{},
+			) (template.HTML, error) {
+				return executePartial(t, name, data)
+			},
 		}).Execute(w, v)
 	}
 
 	return t.Execute(w, v)
 }
 
+// reset resets the r so that the HTML templates will be re-parsed before the
+// next rendering.
+func (r *renderer) reset() {
+	r.mutex.Lock()
+	r.once = &sync.Once{}
+	r.mutex.Unlock()
+}
+
+// loadedTemplate returns the HTML templates most recently parsed by the r. It
+// returns nil if they have never been parsed.
+func (r *renderer) loadedTemplate() *template.Template {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.template
+}
+
+// partial executes the HTML template name in the r with the data and returns
+// the result as a `template.HTML`.
+func (r *renderer) partial(
+	name string,
+	data interface{},
+) (template.HTML, error) {
+	return executePartial(r.loadedTemplate(), name, data)
+}
+
+// executePartial executes the HTML template name associated with the t with the
+// data and returns the result as a `template.HTML`.
+func executePartial(
+	t *template.Template,
+	name string,
+	data interface{},
+) (template.HTML, error) {
+	pt := t.Lookup(name)
+	if pt == nil {
+		return "", fmt.Errorf("html/template: %q is undefined", name)
+	}
+
+	buf := bytes.Buffer{}
+	if err := pt.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
+
 // strlen returns the number of characters in the s.
 func strlen(s string) int {
 	return len([]rune(s))
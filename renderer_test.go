@@ -0,0 +1,256 @@
+package air
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRenderer struct{}
+
+func (testRenderer) Render(
+	w io.Writer,
+	name string,
+	data interface{},
+	req *Request,
+) error {
+	m := data.(map[string]interface{})
+	_, err := fmt.Fprintf(
+		w,
+		"%s:%v:%s",
+		name,
+		m["Name"],
+		m["InheritedHTML"],
+	)
+
+	return err
+}
+
+func TestRenderer(t *testing.T) {
+	a := New()
+	assert.Equal(t, a.renderer, a.Renderer)
+
+	a.Renderer = testRenderer{}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.Render(
+			map[string]interface{}{
+				"Name": "Air",
+			},
+			"index.html",
+			"layout.html",
+		)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "layout.html:Air:index.html:Air:", rec.Body.String())
+}
+
+func TestResponseRenderFragment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestResponseRenderFragment")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "users"), 0755))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "users", "list.html"),
+		[]byte(`<ul>{{block "users/list.html#row" .}}`+
+			`<li>{{.Name}}</li>{{end}}</ul>`),
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "layout.html"),
+		[]byte(`<body>{{.InheritedHTML}}</body>`),
+		0644,
+	))
+
+	a := New()
+	a.TemplateRoot = dir
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.RenderFragment(
+			map[string]interface{}{
+				"Name": "Air",
+			},
+			"users/list.html#row",
+			"users/list.html",
+			"layout.html",
+		)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<body><ul><li>Air</li></ul></body>", rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Vary"), "HX-Request")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<li>Air</li>", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Turbo-Frame", "users")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<li>Air</li>", rec.Body.String())
+}
+
+func TestResponseRenderStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestResponseRenderStream")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "index.html"),
+		[]byte(`<div id="comments">Loading</div>`),
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "comments.html"),
+		[]byte(`<p>{{.}}</p>`),
+		0644,
+	))
+
+	a := New()
+	a.TemplateRoot = dir
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.RenderStream(nil, []*StreamSection{
+			{
+				ID:       "comments",
+				Template: "comments.html",
+				Load: func() (interface{}, error) {
+					return "Foobar", nil
+				},
+			},
+			{
+				ID:       "broken",
+				Template: "comments.html",
+				Load: func() (interface{}, error) {
+					return nil, errors.New("Foobar")
+				},
+			},
+		}, "index.html")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, rec.Flushed)
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.True(t, strings.HasPrefix(
+		rec.Body.String(),
+		`<div id="comments">Loading</div>`+
+			`<template data-air-stream="comments">`+
+			`<p>Foobar</p></template>`,
+	))
+	assert.NotContains(t, rec.Body.String(), "broken")
+
+	loading := make(chan struct{})
+	loaded := make(chan struct{})
+	a.GET("/canceled", func(req *Request, res *Response) error {
+		return res.RenderStream(nil, []*StreamSection{
+			{
+				ID:       "comments",
+				Template: "comments.html",
+				Load: func() (interface{}, error) {
+					close(loading)
+					<-loaded
+					return "Foobar", nil
+				},
+			},
+		}, "index.html")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req = httptest.NewRequest(http.MethodGet, "/canceled", nil)
+	req = req.WithContext(ctx)
+	rec = httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		a.server.ServeHTTP(rec, req)
+		close(served)
+	}()
+
+	<-loading
+	cancel()
+
+	select {
+	case <-served:
+		t.Fatal("returned before the sections are done")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(loaded)
+	<-served
+	assert.NotContains(t, rec.Body.String(), "Foobar")
+}
+
+func TestViewDataGas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestViewDataGas")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "index.html"),
+		[]byte(`{{.User}} {{.Nav}} {{.Title}}`),
+		0644,
+	))
+
+	a := New()
+	a.TemplateRoot = dir
+	a.ViewDataProviders = []ViewDataProvider{
+		func(req *Request) map[string]interface{} {
+			return map[string]interface{}{
+				"User":  "Foo",
+				"Nav":   "main",
+				"Title": "Default",
+			}
+		},
+	}
+
+	h := func(req *Request, res *Response) error {
+		return res.Render(map[string]interface{}{
+			"Title": "Index",
+		}, "index.html")
+	}
+
+	a.GET("/", h)
+	a.Group("/admin", ViewDataGas(
+		func(req *Request) map[string]interface{} {
+			return map[string]interface{}{
+				"Nav":   "admin",
+				"Title": "Admin",
+			}
+		},
+	)).GET("", h)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Foo main Index", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Foo admin Index", rec.Body.String())
+}
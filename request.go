@@ -1,15 +1,23 @@
 package air
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Request is an HTTP request.
@@ -83,9 +91,17 @@ type Request struct {
 	params               []*RequestParam
 	routeParamNames      []string
 	routeParamValues     []string
+	route                *Route
 	parseRouteParamsOnce *sync.Once
 	parseOtherParamsOnce *sync.Once
+	otherParamsErr       error
 	localizedString      func(string) string
+	locale               string
+	viewDataProviders    []ViewDataProvider
+	debugTrace           *debugTrace
+	rawPath              string
+	meteredUnits         int64
+	location             *time.Location
 }
 
 // HTTPRequest returns the underlying `http.Request` of the r.
@@ -123,7 +139,10 @@ func (r *Request) SetHTTPRequest(hr *http.Request) {
 	}
 
 	r.Authority = hr.Host
-	r.Path = hr.RequestURI
+	r.Path = hr.URL.EscapedPath()
+	if hr.URL.RawQuery != "" {
+		r.Path += "?" + hr.URL.RawQuery
+	}
 	r.Header = hr.Header
 	r.Body = hr.Body
 	r.ContentLength = hr.ContentLength
@@ -131,13 +150,36 @@ func (r *Request) SetHTTPRequest(hr *http.Request) {
 	r.hr = hr
 }
 
+// RawPath returns the path (with the query) of the r as it was received, before
+// being normalized with the `Air#PathNormalizationEnabled` and the
+// `Air#EncodedSlashMode`. It can be used by the routes that need to see the
+// dot-segments or the encoded slashes.
+func (r *Request) RawPath() string {
+	if r.rawPath == "" {
+		return r.Path
+	}
+
+	return r.rawPath
+}
+
 // RemoteAddress returns the last network address that sent the r.
 func (r *Request) RemoteAddress() string {
 	return r.hr.RemoteAddr
 }
 
 // ClientAddress returns the original network address that sent the r.
+//
+// If neither the `Air#TrustedProxies` nor the `Air#TrustedProxyHops` is set,
+// it returns the first address of the "Forwarded" or the "X-Forwarded-For"
+// header (if any), which can be forged by clients. Otherwise, it walks the
+// addresses of the "Forwarded", the "X-Forwarded-For" or the "X-Real-IP"
+// header from the right, and returns the first one that was not added by a
+// trusted proxy.
 func (r *Request) ClientAddress() string {
+	if len(r.Air.TrustedProxies) > 0 || r.Air.TrustedProxyHops > 0 {
+		return r.trustedClientAddress()
+	}
+
 	ca := r.RemoteAddress()
 	if f := r.Header.Get("Forwarded"); f != "" { // See RFC 7239
 		for _, p := range strings.Split(strings.Split(f, ",")[0], ";") {
@@ -157,6 +199,120 @@ func (r *Request) ClientAddress() string {
 	return ca
 }
 
+// trustedProxies is the parsed `Air#TrustedProxies` of an `Air`.
+type trustedProxies struct {
+	sync.RWMutex
+
+	a    *Air
+	ns   []string
+	ipns []*net.IPNet
+}
+
+// newTrustedProxies returns a new instance of the `trustedProxies` with the a.
+func newTrustedProxies(a *Air) *trustedProxies {
+	return &trustedProxies{
+		a: a,
+	}
+}
+
+// networks returns the IP networks of the `Air#TrustedProxies`. They are parsed
+// again only when the `Air#TrustedProxies` changes, and the invalid ones are
+// logged once for each change.
+func (tp *trustedProxies) networks() []*net.IPNet {
+	tp.RLock()
+	if stringSlicesEqual(tp.ns, tp.a.TrustedProxies) {
+		ipns := tp.ipns
+		tp.RUnlock()
+		return ipns
+	}
+
+	tp.RUnlock()
+
+	tp.Lock()
+	defer tp.Unlock()
+
+	if stringSlicesEqual(tp.ns, tp.a.TrustedProxies) {
+		return tp.ipns
+	}
+
+	tp.ns = append([]string(nil), tp.a.TrustedProxies...)
+	tp.ipns = nil
+	if len(tp.ns) > 0 {
+		var err error
+		if tp.ipns, err = parseInternalNetworks(tp.ns); err != nil {
+			tp.a.ERROR(
+				"air: failed to parse trusted proxies",
+				map[string]interface{}{
+					"error": err.Error(),
+				},
+			)
+		}
+	}
+
+	return tp.ipns
+}
+
+// trustedClientAddress returns the original network address that sent the r by
+// walking the forwarding chain from the right and skipping the trusted proxies.
+func (r *Request) trustedClientAddress() string {
+	ipns := r.Air.trustedProxies.networks()
+
+	var hops []string
+	if fs := r.Header["Forwarded"]; len(fs) > 0 { // See RFC 7239
+		for _, e := range strings.Split(strings.Join(fs, ","), ",") {
+			for _, p := range strings.Split(e, ";") {
+				if p = strings.TrimSpace(p); len(p) > 4 &&
+					strings.EqualFold(p[:4], "for=") {
+					hops = append(
+						hops,
+						strings.Trim(p[4:], `"`),
+					)
+				}
+			}
+		}
+	} else if xffs := r.Header["X-Forwarded-For"]; len(xffs) > 0 {
+		xff := strings.Join(xffs, ",")
+		for _, a := range strings.Split(xff, ",") {
+			hops = append(hops, strings.TrimSpace(a))
+		}
+	} else if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		hops = append(hops, strings.TrimSpace(xri))
+	}
+
+	hops = append(hops, r.RemoteAddress())
+	for i := len(hops) - 1; i > 0; i-- {
+		if len(hops)-1-i < r.Air.TrustedProxyHops {
+			continue
+		}
+
+		ip := addressIP(hops[i])
+		trusted := false
+		for _, ipn := range ipns {
+			if ip != nil && ipn.Contains(ip) {
+				trusted = true
+				break
+			}
+		}
+
+		if !trusted {
+			return hops[i]
+		}
+	}
+
+	return hops[0]
+}
+
+// addressIP returns the IP of the network address s, such as "192.0.2.1",
+// "192.0.2.1:80", "2001:db8::1" or "[2001:db8::1]:80". It returns nil if the s
+// is not a valid one.
+func addressIP(s string) net.IP {
+	if h, _, err := net.SplitHostPort(s); err == nil {
+		s = h
+	}
+
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+}
+
 // Cookie returns the matched `http.Cookie` for the name. It returns nil if not
 // found.
 func (r *Request) Cookie(name string) *http.Cookie {
@@ -243,7 +399,22 @@ RouteParamLoop:
 // parseOtherParams parses the other params sent with the r into the `r.params`.
 func (r *Request) parseOtherParams() {
 	if r.hr.Form == nil || r.hr.MultipartForm == nil {
-		r.hr.ParseMultipartForm(32 << 20)
+		if r.Air.MultipartMaxBytes > 0 {
+			r.hr.Body = http.MaxBytesReader(
+				nil,
+				r.hr.Body,
+				r.Air.MultipartMaxBytes,
+			)
+		}
+
+		err := r.hr.ParseMultipartForm(32 << 20)
+		if err != nil && r.hr.MultipartForm == nil &&
+			strings.Contains(err.Error(), "too large") {
+			r.otherParamsErr = WrapHTTPError(
+				http.StatusRequestEntityTooLarge,
+				err,
+			)
+		}
 	}
 
 	r.growParams(len(r.hr.Form))
@@ -305,6 +476,10 @@ FormLoop:
 
 	MultipartFormFileLoop:
 		for n, vs := range mf.File {
+			vs = multipartFilesWithin(
+				vs,
+				r.Air.MultipartMaxFileBytes,
+			)
 			if len(vs) == 0 {
 				continue
 			}
@@ -343,7 +518,14 @@ func (r *Request) growParams(n int) {
 	r.params = ps
 }
 
-// Bind binds the r into the v.
+// Bind binds the r into the v. The "shape" struct tags of the fields of the v
+// are applied after the binding, see the `RegisterTransformer()`.
+//
+// The `[]byte` fields with the "encoding" struct tags are decoded from the
+// strings in the params and the JSON request bodies. The encodings are the
+// "base64", the "hex" and the "datauri", and a "max" caps the decoded bytes
+// (the 413 is responded if it is exceeded), such as
+// `encoding:"base64,max=1048576"`.
 func (r *Request) Bind(v interface{}) error {
 	return r.Air.binder.bind(v, r)
 }
@@ -363,6 +545,215 @@ func (r *Request) LocalizedString(key string) string {
 	return r.localizedString(key)
 }
 
+// LocalizedStringf returns the localized string for the key formatted with the
+// args by using the `fmt.Sprintf()`.
+//
+// If the key has plural forms, that is, it is a table with the CLDR plural
+// forms as its keys (such as the "one" and the "other") in the locale files,
+// the first of the args must be an integer count that selects the form.
+//
+// It only localizes if the `I18nEnabled` is true.
+func (r *Request) LocalizedStringf(key string, args ...interface{}) string {
+	otherKey := key + ".other"
+	if len(args) > 0 && r.LocalizedString(otherKey) != otherKey {
+		count := 0
+		switch rv := reflect.ValueOf(args[0]); rv.Kind() {
+		case reflect.Int,
+			reflect.Int8,
+			reflect.Int16,
+			reflect.Int32,
+			reflect.Int64:
+			count = int(rv.Int())
+		case reflect.Uint,
+			reflect.Uint8,
+			reflect.Uint16,
+			reflect.Uint32,
+			reflect.Uint64:
+			count = int(rv.Uint())
+		}
+
+		fk := key + "." + pluralForm(r.locale, count)
+		if s := r.LocalizedString(fk); s != fk {
+			return fmt.Sprintf(s, args...)
+		}
+
+		return fmt.Sprintf(r.LocalizedString(otherKey), args...)
+	}
+
+	return fmt.Sprintf(r.LocalizedString(key), args...)
+}
+
+// Locale returns the locale negotiated for the r by using the query param
+// named the `Air#LocaleQueryName`, the cookie named the `Air#LocaleCookieName`
+// and the "Accept-Language" header in order, such as "en-US". It returns the
+// `Air#LocaleBase` if none of them matches a locale file.
+//
+// It only works if the `I18nEnabled` is true.
+func (r *Request) Locale() string {
+	if !r.Air.I18nEnabled {
+		return r.Air.LocaleBase
+	}
+
+	if r.localizedString == nil {
+		r.Air.i18n.localize(r)
+	}
+
+	return r.locale
+}
+
+// FormFile returns the first multipart form file for the name sent with the r.
+// It returns the `http.ErrMissingFile` if not found, or an `HTTPError` with the
+// 413 status code if the multipart body exceeds the `Air#MultipartMaxBytes` or
+// the file exceeds the `Air#MultipartMaxFileBytes`.
+//
+// The whole multipart body is parsed the first time it is called, use the
+// `r#MultipartReader()` to stream large uploads instead.
+func (r *Request) FormFile(name string) (*multipart.FileHeader, error) {
+	r.parseOtherParamsOnce.Do(r.parseOtherParams)
+	if r.otherParamsErr != nil {
+		return nil, r.otherParamsErr
+	}
+
+	if mf := r.hr.MultipartForm; mf != nil {
+		if fhs := mf.File[name]; len(fhs) > 0 {
+			if fhs[0].Size > r.Air.MultipartMaxFileBytes &&
+				r.Air.MultipartMaxFileBytes > 0 {
+				return nil, NewHTTPError(
+					http.StatusRequestEntityTooLarge,
+					"multipart file too large",
+				)
+			}
+
+			return fhs[0], nil
+		}
+	}
+
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile saves the fh to the dst. The directories of the dst will be
+// created if they do not exist.
+func (r *Request) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return saveFile(src, dst)
+}
+
+// MultipartReader returns a `MultipartReader` that streams the multipart body
+// of the r part by part without buffering the whole body.
+//
+// It cannot be used with the params parsed from the multipart body, such as
+// the `r#FormFile()`, since they consume the same body.
+func (r *Request) MultipartReader() (*MultipartReader, error) {
+	mt, ps, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	} else if !strings.HasPrefix(mt, "multipart/") {
+		return nil, errors.New("request content type is not multipart")
+	} else if ps["boundary"] == "" {
+		return nil, errors.New("multipart boundary cannot be empty")
+	}
+
+	return &MultipartReader{
+		mr: multipart.NewReader(r.Body, ps["boundary"]),
+	}, nil
+}
+
+// Deadline implements the `context.Context` by using the `r.Context`.
+func (r *Request) Deadline() (time.Time, bool) {
+	return r.Context.Deadline()
+}
+
+// Done implements the `context.Context` by using the `r.Context`.
+func (r *Request) Done() <-chan struct{} {
+	return r.Context.Done()
+}
+
+// Err implements the `context.Context` by using the `r.Context`.
+func (r *Request) Err() error {
+	return r.Context.Err()
+}
+
+// Value implements the `context.Context` by using the `r.Context`. It returns
+// the value for the key set by the `r#SetValue()`.
+func (r *Request) Value(key interface{}) interface{} {
+	return r.Context.Value(key)
+}
+
+// SetValue sets the value for the key into the `r.Context`. It is where gases
+// stash per-request values, such as the authenticated user.
+//
+// The key should be of a custom type to avoid collisions, as recommended by the
+// `context.WithValue()`.
+func (r *Request) SetValue(key, value interface{}) {
+	r.Context = context.WithValue(r.Context, key, value)
+}
+
+// StringValue returns the `string` value for the key set by the
+// `r#SetValue()`. It returns "" if not found or not a `string`.
+func (r *Request) StringValue(key interface{}) string {
+	v, _ := r.Value(key).(string)
+	return v
+}
+
+// IntValue returns the `int` value for the key set by the `r#SetValue()`. It
+// returns 0 if not found or not an `int`.
+func (r *Request) IntValue(key interface{}) int {
+	v, _ := r.Value(key).(int)
+	return v
+}
+
+// BoolValue returns the `bool` value for the key set by the `r#SetValue()`. It
+// returns false if not found or not a `bool`.
+func (r *Request) BoolValue(key interface{}) bool {
+	v, _ := r.Value(key).(bool)
+	return v
+}
+
+// WithTimeout returns a copy of the `r.Context` which is canceled after the
+// timeout, when the client's connection closes or when the returned cancel
+// function is called. It can be passed to calls that should not outlive the r,
+// such as database queries.
+func (r *Request) WithTimeout(
+	timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context, timeout)
+}
+
+// FragmentRequested reports whether the r asks for an HTML fragment rather than
+// a full page, which means that it is sent by the htmx (with the "HX-Request"
+// header but not boosted) or by a Turbo Frame (with the "Turbo-Frame" header).
+func (r *Request) FragmentRequested() bool {
+	return (r.Header.Get("HX-Request") == "true" &&
+		r.Header.Get("HX-Boosted") != "true") ||
+		r.Header.Get("Turbo-Frame") != ""
+}
+
+// TraceContext returns the `TraceContext` of the r. It returns nil if the
+// `TracingGas()` is not used.
+func (r *Request) TraceContext() *TraceContext {
+	return TraceContextFromContext(r.Context)
+}
+
+// Priority returns the `Priority` of the r. It is the one carried by the
+// `r.Context` (see the `ContextWithPriority()`) if any, or the one parsed from
+// the "Priority" header.
+//
+// It is used by the `ThrottleGas()` to send the more urgent responses first,
+// and can be used as the `ConcurrencyGasConfig.Priority` when the clients are
+// trusted.
+func (r *Request) Priority() Priority {
+	if p, ok := PriorityFromContext(r.Context); ok {
+		return p
+	}
+
+	return ParsePriority(r.Header.Get("Priority"))
+}
+
 // RequestParam is an HTTP request param.
 type RequestParam struct {
 	// Name is the name of the current request param.
@@ -609,3 +1000,135 @@ func (rpv *RequestParamValue) File() (*multipart.FileHeader, error) {
 
 	return rpv.f, nil
 }
+
+// MultipartReader is a streaming reader of the multipart body of a request.
+type MultipartReader struct {
+	// MaxPartBytes is the maximum number of bytes of each part. Reading a
+	// part beyond it fails.
+	//
+	// If it is less than or equal to zero, there is no limit.
+	MaxPartBytes int64
+
+	// MaxTotalBytes is the maximum number of bytes of all the parts.
+	// Reading parts beyond it fails.
+	//
+	// If it is less than or equal to zero, there is no limit.
+	MaxTotalBytes int64
+
+	mr         *multipart.Reader
+	totalBytes int64
+}
+
+// NextPart returns the next part of the mr. It returns the `io.EOF` if there
+// are no more parts.
+func (mr *MultipartReader) NextPart() (*MultipartPart, error) {
+	p, err := mr.mr.NextPart()
+	if err != nil {
+		return nil, err
+	}
+
+	mp := &MultipartPart{
+		Part: p,
+		mr:   mr,
+	}
+
+	mp.br = bufio.NewReaderSize(readerFunc(mp.read), 512)
+	b, err := mp.br.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	mp.ContentType = http.DetectContentType(b)
+
+	return mp, nil
+}
+
+// MultipartPart is a part of the multipart body of a request.
+type MultipartPart struct {
+	*multipart.Part
+
+	// ContentType is the MIME type sniffed from the first 512 bytes of the
+	// content of the current part. It does not trust the "Content-Type"
+	// header sent by the client.
+	ContentType string
+
+	mr        *MultipartReader
+	br        *bufio.Reader
+	partBytes int64
+}
+
+// Read implements the `io.Reader`.
+func (mp *MultipartPart) Read(b []byte) (int, error) {
+	return mp.br.Read(b)
+}
+
+// SaveTo saves the content of the mp to the dst. The directories of the dst
+// will be created if they do not exist.
+func (mp *MultipartPart) SaveTo(dst string) error {
+	return saveFile(mp, dst)
+}
+
+// read reads the content of the mp into the b while enforcing the size limits
+// of the `mp.mr`.
+func (mp *MultipartPart) read(b []byte) (int, error) {
+	n, err := mp.Part.Read(b)
+	mp.partBytes += int64(n)
+	mp.mr.totalBytes += int64(n)
+	if mp.mr.MaxPartBytes > 0 && mp.partBytes > mp.mr.MaxPartBytes {
+		return n, errors.New("multipart part too large")
+	} else if mp.mr.MaxTotalBytes > 0 &&
+		mp.mr.totalBytes > mp.mr.MaxTotalBytes {
+		return n, errors.New("multipart body too large")
+	}
+
+	return n, err
+}
+
+// saveFile saves the content read from the r to the dst. The directories of the
+// dst will be created if they do not exist.
+func saveFile(r io.Reader, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// readerFunc is an adapter to allow the use of an ordinary function as an
+// `io.Reader`.
+type readerFunc func([]byte) (int, error)
+
+// Read implements the `io.Reader`.
+func (rf readerFunc) Read(b []byte) (int, error) {
+	return rf(b)
+}
+
+// multipartFilesWithin returns the fhs whose sizes are within the max. All the
+// fhs are returned if the max is less than or equal to zero.
+func multipartFilesWithin(
+	fhs []*multipart.FileHeader,
+	max int64,
+) []*multipart.FileHeader {
+	if max <= 0 {
+		return fhs
+	}
+
+	wfhs := make([]*multipart.FileHeader, 0, len(fhs))
+	for _, fh := range fhs {
+		if fh.Size <= max {
+			wfhs = append(wfhs, fh)
+		}
+	}
+
+	return wfhs
+}
@@ -0,0 +1,242 @@
+package air
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMultipartBody(t *testing.T, files map[string]string) (
+	*bytes.Buffer,
+	string,
+) {
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	for name, content := range files {
+		fw, err := mw.CreateFormFile(name, name+".txt")
+		assert.NoError(t, err)
+		fw.Write([]byte(content))
+	}
+
+	assert.NoError(t, mw.Close())
+
+	return buf, mw.FormDataContentType()
+}
+
+func TestRequestFormFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestRequestFormFile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := New()
+	a.POST("/", func(req *Request, res *Response) error {
+		if _, err := req.FormFile("bar"); err != http.ErrMissingFile {
+			return err
+		}
+
+		fh, err := req.FormFile("foo")
+		if err != nil {
+			return err
+		}
+
+		return req.SaveUploadedFile(fh, filepath.Join(dir, "a", "foo"))
+	})
+
+	body, ct := newMultipartBody(t, map[string]string{"foo": "Foobar"})
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", ct)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a", "foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Foobar", string(b))
+
+	a.MultipartMaxFileBytes = 5
+
+	body, ct = newMultipartBody(t, map[string]string{"foo": "Foobar"})
+	req = httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", ct)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	a.MultipartMaxFileBytes = 0
+	a.MultipartMaxBytes = 64
+
+	body, ct = newMultipartBody(t, map[string]string{
+		"foo": strings.Repeat("a", 128),
+	})
+	req = httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", ct)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestRequestMultipartReader(t *testing.T) {
+	a := New()
+	a.POST("/", func(req *Request, res *Response) error {
+		mr, err := req.MultipartReader()
+		if err != nil {
+			return err
+		}
+
+		mr.MaxPartBytes = 8
+
+		mp, err := mr.NextPart()
+		if err != nil {
+			return err
+		}
+
+		b, err := ioutil.ReadAll(mp)
+		if err != nil {
+			return err
+		}
+
+		if _, err := mr.NextPart(); err != io.EOF {
+			return err
+		}
+
+		return res.WriteString(mp.ContentType + ":" + string(b))
+	})
+
+	body, ct := newMultipartBody(t, map[string]string{"foo": "Foobar"})
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", ct)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=utf-8:Foobar", rec.Body.String())
+
+	body, ct = newMultipartBody(t, map[string]string{"foo": "Foobarbaz"})
+	req = httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", ct)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+type testRequestValueKey string
+
+func TestRequestValue(t *testing.T) {
+	a := New()
+	a.Gases = []Gas{func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			req.SetValue(testRequestValueKey("user"), "Foobar")
+			req.SetValue(testRequestValueKey("id"), 1)
+			req.SetValue(testRequestValueKey("admin"), true)
+			return next(req, res)
+		}
+	}}
+	a.GET("/", func(req *Request, res *Response) error {
+		var ctx context.Context = req
+		assert.Equal(
+			t,
+			"Foobar",
+			ctx.Value(testRequestValueKey("user")),
+		)
+		assert.Equal(
+			t,
+			"Foobar",
+			req.StringValue(testRequestValueKey("user")),
+		)
+		assert.Equal(t, 1, req.IntValue(testRequestValueKey("id")))
+		assert.True(t, req.BoolValue(testRequestValueKey("admin")))
+		assert.Empty(t, req.StringValue(testRequestValueKey("id")))
+		assert.Nil(t, req.Err())
+
+		_, ok := req.Deadline()
+		assert.False(t, ok)
+
+		tctx, cancel := req.WithTimeout(time.Millisecond)
+		defer cancel()
+
+		<-tctx.Done()
+		assert.Equal(t, context.DeadlineExceeded, tctx.Err())
+		assert.Nil(t, req.Err())
+
+		return res.WriteString(
+			req.StringValue(testRequestValueKey("user")),
+		)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Foobar", rec.Body.String())
+}
+
+func TestRequestClientAddress(t *testing.T) {
+	a := New()
+
+	hr := httptest.NewRequest(http.MethodGet, "/", nil)
+	hr.RemoteAddr = "10.0.0.1:1234"
+	hr.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.1, 10.0.0.2")
+
+	req := &Request{
+		Air: a,
+	}
+	req.SetHTTPRequest(hr)
+	assert.Equal(t, "203.0.113.9", req.ClientAddress())
+
+	a.TrustedProxies = []string{"private"}
+	assert.Equal(t, "198.51.100.1", req.ClientAddress())
+
+	a.TrustedProxies = []string{"private", "198.51.100.1"}
+	assert.Equal(t, "203.0.113.9", req.ClientAddress())
+
+	a.TrustedProxies = nil
+	a.TrustedProxyHops = 1
+	assert.Equal(t, "10.0.0.2", req.ClientAddress())
+
+	a.TrustedProxyHops = 10
+	assert.Equal(t, "203.0.113.9", req.ClientAddress())
+
+	a.TrustedProxyHops = 0
+	a.TrustedProxies = []string{"loopback"}
+	assert.Equal(t, "10.0.0.1:1234", req.ClientAddress())
+
+	a.TrustedProxies = []string{"private"}
+	hr.Header.Del("X-Forwarded-For")
+	hr.Header.Set(
+		"Forwarded",
+		`for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`,
+	)
+	assert.Equal(t, "[2001:db8::1]:4711", req.ClientAddress())
+
+	hr.Header.Del("Forwarded")
+	hr.Header.Set("X-Real-IP", "192.0.2.60")
+	assert.Equal(t, "192.0.2.60", req.ClientAddress())
+
+	// The invalid trusted proxies are logged once for each change.
+	buf := bytes.Buffer{}
+	a.LoggerOutput = &buf
+	a.TrustedProxies = []string{"foobar"}
+	assert.Equal(t, "10.0.0.1:1234", req.ClientAddress())
+	assert.Equal(t, "10.0.0.1:1234", req.ClientAddress())
+	assert.Equal(t, 1, strings.Count(buf.String(), "trusted proxies"))
+
+	a.TrustedProxies[0] = "private"
+	assert.Equal(t, "192.0.2.60", req.ClientAddress())
+	assert.Equal(t, 1, strings.Count(buf.String(), "trusted proxies"))
+}
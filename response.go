@@ -1,11 +1,11 @@
 package air
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"crypto/tls"
 	"encoding/base64"
-	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"html/template"
@@ -133,7 +133,7 @@ func (r *Response) Write(content io.ReadSeeker) error {
 		defer r.Air.contentTypeSnifferBufferPool.Put(b)
 
 		n, err := io.ReadFull(content, b)
-		if err != nil && err != io.EOF {
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 			return err
 		} else if _, err := content.Seek(0, io.SeekStart); err != nil {
 			return err
@@ -206,19 +206,10 @@ func (r *Response) WriteString(s string) error {
 	return r.Write(strings.NewReader(s))
 }
 
-// WriteJSON responds to the client with the "application/json" content v.
+// WriteJSON responds to the client with the "application/json" content v
+// encoded by the `r.Air.JSONSerializer`.
 func (r *Response) WriteJSON(v interface{}) error {
-	var (
-		b   []byte
-		err error
-	)
-
-	if r.Air.DebugMode {
-		b, err = json.MarshalIndent(v, "", "\t")
-	} else {
-		b, err = json.Marshal(v)
-	}
-
+	b, err := r.Air.JSONSerializer.Marshal(v)
 	if err != nil {
 		return err
 	}
@@ -351,10 +342,25 @@ func (r *Response) WriteHTML(h string) error {
 	return r.Write(strings.NewReader(h))
 }
 
-// Render renders one or more HTML templates with the m and responds to the
-// client with the "text/html" content. The results rendered by the former can
-// be inherited by accessing the `m["InheritedHTML"]`.
+// Render renders one or more HTML templates with the m by using the
+// `r.Air.Renderer` and responds to the client with the "text/html" content. The
+// results rendered by the former can be inherited by accessing the
+// `m["InheritedHTML"]`.
+//
+// This is how layouts are composed. For example, the
+// `r.Render(m, "index.html", "layouts/base.html")` renders the "index.html"
+// first and then the "layouts/base.html" which places the result of the former
+// by using the `{{.InheritedHTML}}`. And since all the HTML templates are
+// parsed into the same set, partials can be included by using the
+// `{{template "partials/nav.html" .}}` (or the `{{partial .Name .}}` if the
+// name is only known at runtime).
+//
+// The data provided by the `r.Air.ViewDataProviders` and the `ViewDataGas()`s
+// are merged into the m without overriding its existing keys, so that layouts
+// do not require every handler to pass the same keys.
 func (r *Response) Render(m map[string]interface{}, templates ...string) error {
+	m = r.req.viewData(m)
+
 	buf := bytes.Buffer{}
 	for _, t := range templates {
 		if m != nil {
@@ -362,8 +368,7 @@ func (r *Response) Render(m map[string]interface{}, templates ...string) error {
 		}
 
 		buf.Reset()
-		err := r.Air.renderer.render(&buf, t, m, r.req.LocalizedString)
-		if err != nil {
+		if err := r.Air.Renderer.Render(&buf, t, m, r.req); err != nil {
 			return err
 		}
 	}
@@ -371,6 +376,193 @@ func (r *Response) Render(m map[string]interface{}, templates ...string) error {
 	return r.WriteHTML(buf.String())
 }
 
+// RenderFragment renders the HTML template fragment with the m and responds to
+// the client with the "text/html" content if the request is a fragment request
+// (see the `Request#FragmentRequested()`) or the templates is empty. Otherwise,
+// it renders the templates just like the `r#Render()` does. This is how a
+// single handler serves both the full page and the fragment for the htmx or
+// the Turbo Frames.
+//
+// The fragment is of the form "<template>#<fragment>", such as
+// "users/list.html#row". Since all the `{{define}}`s share the same namespace,
+// the built-in renderer looks up the whole fragment name first, such as
+// `{{define "users/list.html#row"}}`, and then the part after the "#".
+func (r *Response) RenderFragment(
+	m map[string]interface{},
+	fragment string,
+	templates ...string,
+) error {
+	if len(templates) > 0 {
+		r.Header.Add("Vary", "HX-Request, HX-Boosted, Turbo-Frame")
+	}
+
+	if len(templates) == 0 || r.req.FragmentRequested() {
+		templates = []string{fragment}
+	}
+
+	return r.Render(m, templates...)
+}
+
+// StreamSection is a section of an HTML page rendered by the
+// `Response#RenderStream()`.
+type StreamSection struct {
+	// ID is the ID of the placeholder element in the page that will be
+	// replaced by the current section.
+	ID string
+
+	// Template is the HTML template of the current section.
+	Template string
+
+	// Load loads the data of the `Template`. It is usually slow, such as
+	// querying a database.
+	Load func() (interface{}, error)
+}
+
+// RenderStream renders one or more HTML templates with the m just like the
+// `r#Render()` does, flushes the result to the client immediately, and then
+// streams each of the sections as soon as its data is loaded. Each streamed
+// section replaces the element with its ID in the page, which usually shows a
+// loading state, such as `<div id="comments">Loading...</div>`. This improves
+// the perceived latency of data-heavy pages.
+//
+// The sections are loaded concurrently and streamed in the order of
+// completion. A section that fails to load or render leaves its placeholder
+// untouched, and the first such error is returned after all the sections are
+// done. It always returns after all the sections are done, even if the client
+// goes away, since the `StreamSection#Load` cannot be canceled.
+//
+// It falls back to the blocking rendering, which writes everything at once, if
+// the underlying `http.ResponseWriter` cannot be flushed. The minifier is not
+// applied to the streamed responses.
+func (r *Response) RenderStream(
+	m map[string]interface{},
+	sections []*StreamSection,
+	templates ...string,
+) error {
+	m = r.req.viewData(m)
+
+	buf := bytes.Buffer{}
+	for _, t := range templates {
+		if m != nil {
+			m["InheritedHTML"] = template.HTML(buf.String())
+		}
+
+		buf.Reset()
+		if err := r.Air.Renderer.Render(&buf, t, m, r.req); err != nil {
+			return err
+		}
+	}
+
+	f, streaming := r.hrw.(http.Flusher)
+	if _, ok := r.ohrw.(http.Flusher); !ok {
+		streaming = false
+	}
+
+	if streaming {
+		r.Header.Set("Content-Type", "text/html; charset=utf-8")
+		r.Header.Del("Content-Length")
+		if _, err := r.Body.Write(buf.Bytes()); err != nil {
+			return err
+		}
+
+		f.Flush()
+		buf.Reset()
+	}
+
+	type result struct {
+		ss  *StreamSection
+		buf bytes.Buffer
+		err error
+	}
+
+	// The sections cannot be canceled, so they are waited for before it
+	// returns, since they use the r and its request.
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	resultChan := make(chan *result, len(sections))
+	for _, ss := range sections {
+		wg.Add(1)
+		go func(ss *StreamSection) {
+			defer wg.Done()
+
+			res := &result{
+				ss: ss,
+			}
+
+			var data interface{}
+			if data, res.err = ss.Load(); res.err == nil {
+				res.err = r.req.Context.Err()
+			}
+
+			if res.err == nil {
+				res.err = r.Air.Renderer.Render(
+					&res.buf,
+					ss.Template,
+					data,
+					r.req,
+				)
+			}
+
+			resultChan <- res
+		}(ss)
+	}
+
+	var err error
+	for range sections {
+		var res *result
+		select {
+		case res = <-resultChan:
+		case <-r.req.Context.Done():
+			return r.req.Context.Err()
+		}
+
+		if res.err != nil {
+			if err == nil {
+				err = res.err
+			}
+
+			continue
+		}
+
+		writeStreamSection(&buf, res.ss.ID, res.buf.String())
+		if !streaming {
+			continue
+		}
+
+		if _, err := r.Body.Write(buf.Bytes()); err != nil {
+			return err
+		}
+
+		f.Flush()
+		buf.Reset()
+	}
+
+	if !streaming {
+		if werr := r.WriteHTML(buf.String()); err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+// writeStreamSection writes the HTML h of the section with the id into the buf
+// as a chunk that replaces the placeholder element with the id.
+func writeStreamSection(buf *bytes.Buffer, id, h string) {
+	buf.WriteString(`<template data-air-stream="`)
+	buf.WriteString(template.HTMLEscapeString(id))
+	buf.WriteString(`">`)
+	buf.WriteString(h)
+	buf.WriteString(`</template><script>(function(){var t=document.` +
+		`querySelector('template[data-air-stream="`)
+	buf.WriteString(template.JSEscapeString(id))
+	buf.WriteString(`"]'),e=document.getElementById("`)
+	buf.WriteString(template.JSEscapeString(id))
+	buf.WriteString(`");if(e){e.replaceWith(t.content)}t.remove()})()` +
+		`</script>`)
+}
+
 // WriteFile responds to the client with a file content with the filename.
 func (r *Response) WriteFile(filename string) error {
 	filename, err := filepath.Abs(filename)
@@ -591,7 +783,8 @@ func (r *Response) Push(target string, pos *http.PushOptions) error {
 }
 
 // ProxyPass passes the request to the target and responds to the client by
-// using the reverse proxy technique.
+// using the reverse proxy technique. The `TraceContext` of the request (if any)
+// is propagated to the target.
 //
 // The target must be based on the HTTP protocol (such as HTTP(S), WebSocket and
 // gRPC). So, the scheme of the target must be "http", "https", "ws", "wss",
@@ -613,6 +806,12 @@ func (r *Response) ProxyPass(target string) error {
 
 	if u.Scheme != "ws" && u.Scheme != "wss" {
 		rp := httputil.NewSingleHostReverseProxy(u)
+		director := rp.Director
+		rp.Director = func(hr *http.Request) {
+			director(hr)
+			InjectTraceContext(r.req.Context, hr.Header)
+		}
+
 		rp.Transport = r.Air.reverseProxyTransport
 		rp.ErrorLog = r.Air.errorLogger
 		rp.BufferPool = r.Air.reverseProxyBufferPool
@@ -644,6 +843,7 @@ func (r *Response) ProxyPass(target string) error {
 	oreqh.Del("Sec-WebSocket-Extensions")
 	oreqh.Del("Sec-WebSocket-Accept")
 	oreqh.Del("Sec-WebSocket-Version")
+	InjectTraceContext(r.req.Context, oreqh)
 
 	dc, res, err := websocket.DefaultDialer.Dial(u.String(), oreqh)
 	if err != nil {
@@ -701,6 +901,74 @@ func (r *Response) ProxyPass(target string) error {
 	return err
 }
 
+// Flush sends the message body written so far to the client. The header is
+// sent first if it has not been sent. It returns the `http.ErrNotSupported` if
+// the underlying `http.ResponseWriter` cannot be flushed.
+func (r *Response) Flush() error {
+	f, ok := r.hrw.(http.Flusher)
+	if !ok {
+		return http.ErrNotSupported
+	} else if _, ok := r.ohrw.(http.Flusher); !ok {
+		return http.ErrNotSupported
+	}
+
+	if !r.Written {
+		r.hrw.WriteHeader(r.Status)
+	}
+
+	f.Flush()
+
+	return nil
+}
+
+// Stream calls the step repeatedly with the `r#Body` and flushes what it writes
+// to the client after each call, until it returns false or the request is
+// canceled. It is suited for incremental responses, such as long pollings,
+// chunked downloads and progress reports.
+func (r *Response) Stream(step func(w io.Writer) bool) error {
+	for {
+		select {
+		case <-r.req.Context.Done():
+			return r.req.Context.Err()
+		default:
+		}
+
+		more := step(r.Body)
+		if err := r.Flush(); err != nil &&
+			err != http.ErrNotSupported {
+			return err
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// Hijack takes over the underlying connection of the r. The r cannot be used
+// after that. It returns the `http.ErrNotSupported` if the underlying
+// connection cannot be hijacked, such as an HTTP/2 connection.
+func (r *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ohrw.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The hijacked connections are no longer HTTP/1.x ones.
+	if sc, ok := conn.(*strictConn); ok {
+		sc.passThrough()
+	}
+
+	r.Written = true
+
+	return conn, brw, nil
+}
+
 // Defer pushes the f onto the stack of functions that will be called after
 // responding. Nil functions will be silently dropped.
 func (r *Response) Defer(f func()) {
@@ -801,6 +1069,10 @@ func (rw *responseWriter) WriteHeader(status int) {
 		h.Del("Content-Length")
 	}
 
+	if dt := rw.r.req.debugTrace; dt != nil {
+		h.Set("X-Air-Trace", dt.String())
+	}
+
 	if !rw.r.Air.DebugMode &&
 		rw.r.Air.HTTPSEnforced &&
 		rw.r.Air.server.server.TLSConfig != nil &&
@@ -819,8 +1091,10 @@ func (rw *responseWriter) WriteHeader(status int) {
 func (rw *responseWriter) Flush() {
 	if rw.gw != nil {
 		rw.gw.Flush()
-	} else {
-		rw.w.(http.Flusher).Flush()
+	}
+
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
 	}
 }
 
@@ -834,6 +1108,251 @@ func (rw *responseWriter) Push(target string, pos *http.PushOptions) error {
 	return p.Push(target, pos)
 }
 
+// lazyBodyWriter is an `http.ResponseWriter` that holds the status code and
+// the message body back until either the response ends, in which case the
+// "Content-Length" header can be set automatically, or the message body grows
+// larger than the max or is flushed, in which case it switches to streaming.
+type lazyBodyWriter struct {
+	w         http.ResponseWriter
+	r         *http.Request
+	max       int
+	status    int
+	body      bytes.Buffer
+	streaming bool
+}
+
+// newLazyBodyWriter returns a new instance of the `lazyBodyWriter` that holds
+// back at most the max bytes of the message body of the response to the r.
+func newLazyBodyWriter(
+	w http.ResponseWriter,
+	r *http.Request,
+	max int,
+) *lazyBodyWriter {
+	return &lazyBodyWriter{
+		w:   w,
+		r:   r,
+		max: max,
+	}
+}
+
+// Header implements the `http.ResponseWriter`.
+func (lbw *lazyBodyWriter) Header() http.Header {
+	return lbw.w.Header()
+}
+
+// Write implements the `http.ResponseWriter`.
+func (lbw *lazyBodyWriter) Write(b []byte) (int, error) {
+	if !lbw.streaming && lbw.body.Len()+len(b) > lbw.max {
+		if err := lbw.stream(); err != nil {
+			return 0, err
+		}
+	}
+
+	if lbw.streaming {
+		return lbw.w.Write(b)
+	}
+
+	if lbw.status == 0 {
+		lbw.status = http.StatusOK
+	}
+
+	return lbw.body.Write(b)
+}
+
+// WriteHeader implements the `http.ResponseWriter`. The informational status
+// codes are written immediately.
+func (lbw *lazyBodyWriter) WriteHeader(status int) {
+	if lbw.streaming {
+		lbw.w.WriteHeader(status)
+	} else if status < http.StatusOK {
+		lbw.w.WriteHeader(status)
+	} else if lbw.status == 0 {
+		lbw.status = status
+	}
+}
+
+// Flush implements the `http.Flusher`.
+func (lbw *lazyBodyWriter) Flush() {
+	if !lbw.streaming && lbw.stream() != nil {
+		return
+	}
+
+	if f, ok := lbw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Push implements the `http.Pusher`.
+func (lbw *lazyBodyWriter) Push(target string, pos *http.PushOptions) error {
+	p, ok := lbw.w.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+
+	return p.Push(target, pos)
+}
+
+// stream switches the lbw to streaming by writing the held status code and
+// message body to the underlying `http.ResponseWriter`.
+func (lbw *lazyBodyWriter) stream() error {
+	lbw.streaming = true
+	if lbw.status == 0 {
+		return nil
+	}
+
+	lbw.w.WriteHeader(lbw.status)
+	_, err := lbw.w.Write(lbw.body.Bytes())
+	lbw.body.Reset()
+
+	return err
+}
+
+// end ends the response by writing the held status code and message body to
+// the underlying `http.ResponseWriter` with the "Content-Length" header set if
+// it is absent.
+func (lbw *lazyBodyWriter) end() {
+	if lbw.streaming || lbw.status == 0 {
+		return
+	}
+
+	h := lbw.w.Header()
+	if h.Get("Content-Length") == "" &&
+		h.Get("Transfer-Encoding") == "" &&
+		lbw.r.Method != http.MethodHead &&
+		lbw.status != http.StatusNoContent &&
+		lbw.status != http.StatusNotModified {
+		h.Set("Content-Length", strconv.Itoa(lbw.body.Len()))
+	}
+
+	lbw.stream()
+}
+
+// responseBuffer is an `http.ResponseWriter` that buffers the status code and
+// the message body written by a `Response` until it is flushed. It sits between
+// the `responseWriter` and the underlying `http.ResponseWriter`, which means
+// that the buffered message body has been gzipped if the gzip is enabled.
+//
+// The buffered message body is flushed, and the rest of it is streamed, as soon
+// as it is explicitly flushed (such as by the `Response#Flush()`), its
+// "Content-Type" is the "text/event-stream", or it would exceed the maxBytes
+// (if the maxBytes is greater than zero). The gases built on it must leave the
+// streamed message bodies as is.
+type responseBuffer struct {
+	rw        *responseWriter
+	w         http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	maxBytes  int
+	streaming bool
+}
+
+// newResponseBuffer returns a new instance of the `responseBuffer` that starts
+// buffering for the r. It returns nil if the r is not tied with its
+// `responseWriter` or has been written.
+func newResponseBuffer(r *Response) *responseBuffer {
+	rw, ok := r.hrw.(*responseWriter)
+	if !ok || r.Written {
+		return nil
+	}
+
+	rb := &responseBuffer{
+		rw:     rw,
+		w:      rw.w,
+		status: http.StatusOK,
+	}
+	rw.w = rb
+
+	return rb
+}
+
+// Header implements the `http.ResponseWriter`.
+func (rb *responseBuffer) Header() http.Header {
+	return rb.w.Header()
+}
+
+// Write implements the `http.ResponseWriter`.
+func (rb *responseBuffer) Write(b []byte) (int, error) {
+	if !rb.streaming && (rb.maxBytes > 0 &&
+		rb.body.Len()+len(b) > rb.maxBytes ||
+		strings.HasPrefix(
+			rb.Header().Get("Content-Type"),
+			"text/event-stream",
+		)) {
+		if err := rb.stream(); err != nil {
+			return 0, err
+		}
+	}
+
+	if rb.streaming {
+		return rb.w.Write(b)
+	}
+
+	return rb.body.Write(b)
+}
+
+// WriteHeader implements the `http.ResponseWriter`.
+func (rb *responseBuffer) WriteHeader(status int) {
+	rb.status = status
+}
+
+// Flush implements the `http.Flusher`. It stops buffering, so that the message
+// body written so far reaches the client.
+func (rb *responseBuffer) Flush() {
+	if !rb.streaming && rb.stream() != nil {
+		return
+	}
+
+	if f, ok := rb.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream stops buffering and writes the buffered status code and message body
+// to the underlying `http.ResponseWriter`, so that the rest of the message body
+// is written directly.
+func (rb *responseBuffer) stream() error {
+	rb.streaming = true
+	rb.w.WriteHeader(rb.status)
+	_, err := rb.w.Write(rb.body.Bytes())
+	rb.body.Reset()
+
+	return err
+}
+
+// Push implements the `http.Pusher`.
+func (rb *responseBuffer) Push(target string, pos *http.PushOptions) error {
+	p, ok := rb.w.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+
+	return p.Push(target, pos)
+}
+
+// complete completes the buffered message body by closing the gzip writer (if
+// any), so that nothing will be written to the rb afterwards.
+func (rb *responseBuffer) complete() {
+	if rb.rw.gw != nil {
+		rb.rw.gw.Close()
+	}
+}
+
+// flush stops buffering and writes the buffered status code and message body
+// to the underlying `http.ResponseWriter`. Nothing is written if the
+// `Response` has not been written.
+func (rb *responseBuffer) flush() error {
+	rb.complete()
+	rb.rw.w = rb.w
+	if !rb.rw.r.Written || rb.streaming {
+		return nil
+	}
+
+	rb.w.WriteHeader(rb.status)
+	_, err := rb.w.Write(rb.body.Bytes())
+
+	return err
+}
+
 // newReverseProxyTransport returns a new instance of the `http.Transport` with
 // reverse proxy support.
 func newReverseProxyTransport() *http.Transport {
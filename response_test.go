@@ -0,0 +1,133 @@
+package air
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseFlush(t *testing.T) {
+	a := New()
+	a.GET("/", func(req *Request, res *Response) error {
+		res.Status = http.StatusAccepted
+		if err := res.Flush(); err != nil {
+			return err
+		}
+
+		assert.True(t, res.Written)
+
+		return res.WriteString("Foobar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "Foobar", rec.Body.String())
+}
+
+func TestResponseStream(t *testing.T) {
+	a := New()
+	a.GET("/", func(req *Request, res *Response) error {
+		i := 0
+		return res.Stream(func(w io.Writer) bool {
+			i++
+			fmt.Fprintf(w, "%d\n", i)
+			return i < 3
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "1\n2\n3\n", rec.Body.String())
+}
+
+func TestResponseHijack(t *testing.T) {
+	a := New()
+	a.GET("/", func(req *Request, res *Response) error {
+		conn, brw, err := res.Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 200 OK\r\n" +
+			"Content-Length: 6\r\n" +
+			"Connection: close\r\n\r\n" +
+			"Foobar")
+
+		return brw.Flush()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	s := httptest.NewServer(a)
+	defer s.Close()
+
+	hres, err := http.Get(s.URL)
+	assert.NoError(t, err)
+	defer hres.Body.Close()
+
+	b, err := ioutil.ReadAll(hres.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, hres.StatusCode)
+	assert.Equal(t, "Foobar", string(b))
+}
+
+func TestResponseLazyBody(t *testing.T) {
+	a := New()
+	a.GzipEnabled = true
+	a.ResponseLazyBodyMaxBytes = 64
+	a.GET("/small", func(req *Request, res *Response) error {
+		return res.WriteJSON(map[string]string{"foo": "bar"})
+	})
+	a.GET("/large", func(req *Request, res *Response) error {
+		res.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		for i := 0; i < 16; i++ {
+			fmt.Fprintf(res.Body, "%08d\n", i)
+		}
+
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(
+		t,
+		strconv.Itoa(rec.Body.Len()),
+		rec.Header().Get("Content-Length"),
+	)
+
+	req = httptest.NewRequest(http.MethodGet, "/large", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Equal(t, 16*9, rec.Body.Len())
+
+	a.ResponseLazyBodyMaxBytes = 0
+
+	req = httptest.NewRequest(http.MethodGet, "/small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+}
@@ -1,8 +1,13 @@
 package air
 
 import (
+	"fmt"
+	"mime"
+	"net/http"
 	"net/url"
 	ppath "path"
+	"reflect"
+	"runtime"
 	"strings"
 	"sync"
 )
@@ -13,7 +18,8 @@ type router struct {
 
 	a                    *Air
 	routeTree            *routeNode
-	registeredRoutes     map[string]bool
+	registeredRoutes     map[string]*Route
+	routes               []*Route
 	maxRouteParams       int
 	routeParamValuesPool *sync.Pool
 }
@@ -24,8 +30,9 @@ func newRouter(a *Air) *router {
 		a: a,
 		routeTree: &routeNode{
 			handlers: map[string]Handler{},
+			routes:   map[string]*Route{},
 		},
-		registeredRoutes: map[string]bool{},
+		registeredRoutes: map[string]*Route{},
 	}
 	r.routeParamValuesPool = &sync.Pool{
 		New: func() interface{} {
@@ -38,7 +45,12 @@ func newRouter(a *Air) *router {
 
 // register registers a new route for the method and the path with the matching
 // h in the r with the optional route-level gases.
-func (r *router) register(method, path string, h Handler, gases ...Gas) {
+func (r *router) register(
+	method string,
+	path string,
+	h Handler,
+	gases ...Gas,
+) *Route {
 	r.Lock()
 	defer r.Unlock()
 
@@ -49,6 +61,7 @@ func (r *router) register(method, path string, h Handler, gases ...Gas) {
 	}
 
 	path = ppath.Clean(path)
+	routePath := path
 	path = url.PathEscape(path)
 	path = strings.Replace(path, "%2F", "/", -1)
 	path = strings.Replace(path, "%2A", "*", -1)
@@ -65,7 +78,10 @@ func (r *router) register(method, path string, h Handler, gases ...Gas) {
 	} else if strings.Contains(path, "*") {
 		if strings.Count(path, "*") > 1 {
 			panic("air: only one * is allowed in route path")
-		} else if path[len(path)-1] != '*' {
+		} else if strings.Contains(
+			path[strings.IndexByte(path, '*'):],
+			"/",
+		) {
 			panic("air: * can only appear at end of route path")
 		} else if strings.Contains(
 			path[strings.LastIndex(path, "/"):],
@@ -76,6 +92,12 @@ func (r *router) register(method, path string, h Handler, gases ...Gas) {
 		}
 	}
 
+	anyName := "*"
+	if i := strings.IndexByte(path, '*'); i >= 0 && i < len(path)-1 {
+		anyName = path[i+1:]
+		path = path[:i+1]
+	}
+
 	routeName := method + path
 	for i, l := len(method), len(routeName); i < l; i++ {
 		if routeName[i] == ':' {
@@ -93,21 +115,52 @@ func (r *router) register(method, path string, h Handler, gases ...Gas) {
 		}
 	}
 
-	if r.registeredRoutes[routeName] {
-		panic("air: route already exists")
-	} else {
-		r.registeredRoutes[routeName] = true
+	route := &Route{
+		Method: method,
+		Path:   routePath,
+		Name: runtime.FuncForPC(
+			reflect.ValueOf(h).Pointer(),
+		).Name(),
 	}
-
-	rh := func(req *Request, res *Response) error {
-		h := h
+	route.handler = func(req *Request, res *Response) error {
+		h := req.debugTrace.wrap(h, h)
 		for i := len(gases) - 1; i >= 0; i-- {
-			h = gases[i](h)
+			h = req.debugTrace.wrap(gases[i], gases[i](h))
 		}
 
 		return h(req, res)
 	}
 
+	// Routes for the same method and path are dispatched by their matchers,
+	// which requires all the former ones to have matchers.
+	if pr := r.registeredRoutes[routeName]; pr != nil {
+		for _, v := range pr.variants {
+			if len(v.matchers) == 0 || v.Path != routePath {
+				panic("air: route already exists")
+			}
+		}
+
+		pr.variants = append(pr.variants, route)
+		r.routes = append(r.routes, route)
+
+		return route
+	}
+
+	route.variants = []*Route{route}
+	r.registeredRoutes[routeName] = route
+	r.routes = append(r.routes, route)
+
+	rh := func(req *Request, res *Response) error {
+		for _, v := range route.variants {
+			if v.match(req) {
+				req.route = v
+				return v.handler(req, res)
+			}
+		}
+
+		return r.a.NotFoundHandler(req, res)
+	}
+
 	paramNames := []string{}
 	for i, l := 0, len(path); i < l; i++ {
 		if path[i] == ':' {
@@ -117,6 +170,7 @@ func (r *router) register(method, path string, h Handler, gases ...Gas) {
 				method,
 				path[:i],
 				nil,
+				nil,
 				routeNodeTypeStatic,
 				nil,
 			)
@@ -141,16 +195,18 @@ func (r *router) register(method, path string, h Handler, gases ...Gas) {
 					method,
 					path,
 					rh,
+					route,
 					routeNodeTypeParam,
 					paramNames,
 				)
-				return
+				return route
 			}
 
 			r.insert(
 				method,
 				path[:i],
 				nil,
+				nil,
 				routeNodeTypeParam,
 				paramNames,
 			)
@@ -159,22 +215,33 @@ func (r *router) register(method, path string, h Handler, gases ...Gas) {
 				method,
 				path[:i],
 				nil,
+				nil,
 				routeNodeTypeStatic,
 				nil,
 			)
-			paramNames = append(paramNames, "*")
+			paramNames = append(paramNames, anyName)
 			r.insert(
 				method,
 				path[:i+1],
 				rh,
+				route,
 				routeNodeTypeAny,
 				paramNames,
 			)
-			return
+			return route
 		}
 	}
 
-	r.insert(method, path, rh, routeNodeTypeStatic, paramNames)
+	r.insert(
+		method,
+		path,
+		rh,
+		route,
+		routeNodeTypeStatic,
+		paramNames,
+	)
+
+	return route
 }
 
 // insert inserts a new route into the `r.routeTree`.
@@ -182,6 +249,7 @@ func (r *router) insert(
 	method string,
 	path string,
 	h Handler,
+	route *Route,
 	nt routeNodeType,
 	paramNames []string,
 ) {
@@ -219,6 +287,7 @@ func (r *router) insert(
 			cn.paramNames = paramNames
 			if h != nil {
 				cn.handlers[method] = h
+				cn.routes[method] = route
 			}
 		} else if ll < pl { // Split node
 			nn = &routeNode{
@@ -228,6 +297,7 @@ func (r *router) insert(
 				children:   cn.children,
 				paramNames: cn.paramNames,
 				handlers:   cn.handlers,
+				routes:     cn.routes,
 			}
 
 			// Reset current node.
@@ -237,12 +307,14 @@ func (r *router) insert(
 			cn.children = []*routeNode{nn}
 			cn.paramNames = nil
 			cn.handlers = map[string]Handler{}
+			cn.routes = map[string]*Route{}
 
 			if ll == sl { // At current node
 				cn.nType = nt
 				cn.paramNames = paramNames
 				if h != nil {
 					cn.handlers[method] = h
+					cn.routes[method] = route
 				}
 			} else { // Create child node
 				nn = &routeNode{
@@ -251,9 +323,11 @@ func (r *router) insert(
 					prefix:     s[ll:],
 					paramNames: paramNames,
 					handlers:   map[string]Handler{},
+					routes:     map[string]*Route{},
 				}
 				if h != nil {
 					nn.handlers[method] = h
+					nn.routes[method] = route
 				}
 
 				cn.children = append(cn.children, nn)
@@ -272,10 +346,12 @@ func (r *router) insert(
 				nType:      nt,
 				prefix:     s,
 				handlers:   map[string]Handler{},
+				routes:     map[string]*Route{},
 				paramNames: paramNames,
 			}
 			if h != nil {
 				nn.handlers[method] = h
+				nn.routes[method] = route
 			}
 
 			cn.children = append(cn.children, nn)
@@ -286,6 +362,7 @@ func (r *router) insert(
 
 			if h != nil {
 				cn.handlers[method] = h
+				cn.routes[method] = route
 			}
 		}
 
@@ -295,19 +372,71 @@ func (r *router) insert(
 
 // route returns a handler registered for the req.
 func (r *router) route(req *Request) Handler {
+	p, q := splitPathQuery(req.Path)
+	cn := r.match(req, p)
+	if (cn == nil || len(cn.handlers) == 0) &&
+		r.a.TrailingSlashMode != "" &&
+		len(p) > 1 && p[len(p)-1] == '/' {
+		// Trimmed path. The leading slashes are collapsed to avoid
+		// redirecting to a protocol-relative URL.
+		tp := "/" + strings.Trim(p, "/")
+
+		if tn := r.match(req, tp); tn != nil && len(tn.handlers) != 0 {
+			if r.a.TrailingSlashMode == "redirect" {
+				if q != "" {
+					tp += "?" + q
+				}
+
+				return permanentRedirectHandler(tp)
+			}
+
+			cn = tn
+		}
+	}
+
+	if cn == nil {
+		return r.a.NotFoundHandler
+	} else if h := cn.handlers[req.Method]; h != nil {
+		req.routeParamNames = cn.paramNames
+		req.route = cn.routes[req.Method]
+		return h
+	} else if len(cn.handlers) != 0 {
+		return r.a.MethodNotAllowedHandler
+	}
+
+	return r.a.NotFoundHandler
+}
+
+// permanentRedirectHandler returns a `Handler` that permanently redirects to
+// the url. The 301 status code is used for the GET and the HEAD, and the 308 is
+// used for the others so that the method and the body are preserved.
+func permanentRedirectHandler(url string) Handler {
+	return func(req *Request, res *Response) error {
+		res.Status = http.StatusPermanentRedirect
+		if req.Method == http.MethodGet ||
+			req.Method == http.MethodHead {
+			res.Status = http.StatusMovedPermanently
+		}
+
+		return res.Redirect(url)
+	}
+}
+
+// match returns the node matching the path s in the `r.routeTree` and fills the
+// route param values of the req. It returns nil if not found.
+func (r *router) match(req *Request, s string) *routeNode {
 	var (
-		s, _ = splitPathQuery(req.Path) // Search
-		cn   = r.routeTree              // Current node
-		nn   *routeNode                 // Next node
-		nnt  routeNodeType              // Next node type
-		sn   *routeNode                 // Saved node
-		ss   string                     // Saved search
-		sl   int                        // Search length
-		pl   int                        // Prefix length
-		ll   int                        // LCP length
-		ml   int                        // Minimum length of sl and pl
-		i    int                        // Index
-		pi   int                        // Param index
+		cn  = r.routeTree // Current node
+		nn  *routeNode    // Next node
+		nnt routeNodeType // Next node type
+		sn  *routeNode    // Saved node
+		ss  string        // Saved search
+		sl  int           // Search length
+		pl  int           // Prefix length
+		ll  int           // LCP length
+		ml  int           // Minimum length of sl and pl
+		i   int           // Index
+		pi  int           // Param index
 	)
 
 	// Search order: static route > param route > any route.
@@ -424,17 +553,10 @@ func (r *router) route(req *Request) Handler {
 			}
 		}
 
-		return r.a.NotFoundHandler
+		return nil
 	}
 
-	if h := cn.handlers[req.Method]; h != nil {
-		req.routeParamNames = cn.paramNames
-		return h
-	} else if len(cn.handlers) != 0 {
-		return r.a.MethodNotAllowedHandler
-	}
-
-	return r.a.NotFoundHandler
+	return cn
 }
 
 // routeNode is the node of the route radix tree.
@@ -445,6 +567,7 @@ type routeNode struct {
 	children   []*routeNode
 	paramNames []string
 	handlers   map[string]Handler
+	routes     map[string]*Route
 }
 
 // child returns a child node of the rn by the l and the t.
@@ -480,6 +603,157 @@ func (rn *routeNode) childByType(t routeNodeType) *routeNode {
 	return nil
 }
 
+// Route is a route registered in the router.
+type Route struct {
+	// Method is the HTTP method of the current route.
+	Method string
+
+	// Path is the path pattern of the current route, such as "/users/:id"
+	// or "/files/*filepath".
+	Path string
+
+	// Name is the name of the current route used by the `Air#URL()`. It
+	// can be changed after the registration, such as
+	// `a.GET("/users/:id", h).Name = "user"`, but only before the server
+	// starts, since its changes are not synchronized with the `Air#URL()`
+	// called by the requests.
+	//
+	// The default value is the name of the handler function of the current
+	// route, such as "main.getUser".
+	Name string
+
+	// Title is the human-readable title of the current route used by the
+	// `Request#Breadcrumbs()`. Just like the `Name`, it can be changed
+	// after the registration, but only before the server starts.
+	//
+	// If it is empty, the last element of the URL path of the current
+	// route will be used.
+	Title string
+
+	handler  Handler
+	matchers []func(*Request) bool
+	variants []*Route
+}
+
+// Match adds the f as a matcher of the r, and returns the r. A route with
+// matchers only handles the requests that all of its matchers report true
+// for. This is how multiple routes are registered for the same method and path
+// to serve different variants of the content, such as
+//
+//	a.GET("/export", exportCSV).MatchHeader("Accept", "text/csv")
+//	a.GET("/export", exportJSON)
+//
+// The routes for the same method and path are tried in the order of their
+// registration. Only the last one of them can have no matchers, which acts as
+// the fallback. The not found handler is used if none of them matches.
+func (r *Route) Match(f func(req *Request) bool) *Route {
+	r.matchers = append(r.matchers, f)
+	return r
+}
+
+// MatchHeader adds a matcher to the r that requires the header name of the
+// requests to contain the value as one of its comma-separated elements, and
+// returns the r. The parameters of the elements, such as the ";q=0.9", are
+// ignored. Any value of the header is accepted if the value is empty.
+func (r *Route) MatchHeader(name, value string) *Route {
+	return r.Match(func(req *Request) bool {
+		hv := req.Header.Get(name)
+		if value == "" || hv == "" {
+			return hv != ""
+		}
+
+		for _, e := range strings.Split(hv, ",") {
+			if i := strings.IndexByte(e, ';'); i >= 0 {
+				e = e[:i]
+			}
+
+			if strings.EqualFold(strings.TrimSpace(e), value) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// MatchQuery adds a matcher to the r that requires the query param name of the
+// requests to be the value, and returns the r. Any value of the query param is
+// accepted if the value is empty.
+func (r *Route) MatchQuery(name, value string) *Route {
+	return r.Match(func(req *Request) bool {
+		_, q := splitPathQuery(req.Path)
+		qvs, _ := url.ParseQuery(q)
+		vs, ok := qvs[name]
+		if value == "" {
+			return ok
+		}
+
+		for _, v := range vs {
+			if v == value {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// MatchContentType adds a matcher to the r that requires the media type of the
+// "Content-Type" of the requests to be the mt (such as "application/json"),
+// and returns the r.
+func (r *Route) MatchContentType(mt string) *Route {
+	return r.Match(func(req *Request) bool {
+		rmt, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		return strings.EqualFold(rmt, mt)
+	})
+}
+
+// match reports whether all the matchers of the r report true for the req.
+func (r *Route) match(req *Request) bool {
+	for _, m := range r.matchers {
+		if !m(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// url returns the URL path of the r with the params filled into its param names
+// and wildcard in order. The param names without matching params are left as
+// is.
+func (r *Route) url(params ...interface{}) string {
+	b := strings.Builder{}
+	for i, p := 0, r.Path; i < len(p); i++ {
+		if (p[i] != ':' && p[i] != '*') || len(params) == 0 {
+			b.WriteByte(p[i])
+			continue
+		}
+
+		v := fmt.Sprint(params[0])
+		params = params[1:]
+
+		if p[i] == '*' {
+			vs := strings.Split(v, "/")
+			for j := range vs {
+				vs[j] = url.PathEscape(vs[j])
+			}
+
+			b.WriteString(strings.Join(vs, "/"))
+
+			break
+		}
+
+		for ; i < len(p) && p[i] != '/'; i++ {
+		}
+
+		b.WriteString(url.PathEscape(v))
+		i--
+	}
+
+	return b.String()
+}
+
 // routeNodeType is the type of the `routeNode`.
 type routeNodeType uint8
 
@@ -621,3 +621,148 @@ func fakeRRCycle(
 
 	return req, res, rec
 }
+
+func TestRouterNamedWildcard(t *testing.T) {
+	a := New()
+	a.GET("/files/*filepath", func(req *Request, res *Response) error {
+		return res.WriteString(req.Param("filepath").Value().String())
+	})
+
+	assert.Panics(t, func() {
+		a.GET("/files/*name", func(req *Request, res *Response) error {
+			return nil
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/files/foo/bar.txt", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "foo/bar.txt", rec.Body.String())
+}
+
+func TestRouterTrailingSlashMode(t *testing.T) {
+	a := New()
+	a.GET("/foo", func(req *Request, res *Response) error {
+		return res.WriteString("foo")
+	})
+	a.POST("/bar/", func(req *Request, res *Response) error {
+		return res.WriteString("bar")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	a.TrailingSlashMode = "redirect"
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/?a=b", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/foo?a=b", rec.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodPost, "//bar//", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+	assert.Equal(t, "/bar", rec.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/baz/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	a.TrailingSlashMode = "rewrite"
+
+	req = httptest.NewRequest(http.MethodGet, "/foo/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "foo", rec.Body.String())
+}
+
+func testRouterGetUser(req *Request, res *Response) error {
+	return nil
+}
+
+func TestAirURL(t *testing.T) {
+	a := New()
+	a.GET("/users/:id", testRouterGetUser)
+	a.GET("/users/:id/files/*", testRouterGetUser).Name = "user_file"
+	a.GET("/assets/*filepath", testRouterGetUser).Name = "asset"
+
+	assert.Equal(
+		t,
+		"/users/1",
+		a.URL("github.com/aofei/air.testRouterGetUser", 1),
+	)
+	assert.Equal(
+		t,
+		"/users/a%20b/files/c/d",
+		a.URL("user_file", "a b", "c/d"),
+	)
+	assert.Equal(t, "/users/1/files/*", a.URL("user_file", 1))
+	assert.Equal(t, "/assets/app.js", a.URL("asset", "app.js"))
+	assert.Empty(t, a.URL("foobar"))
+
+	rs := a.Routes()
+	assert.Len(t, rs, 3)
+	assert.Equal(t, http.MethodGet, rs[2].Method)
+	assert.Equal(t, "/assets/*filepath", rs[2].Path)
+	assert.Equal(t, "asset", rs[2].Name)
+}
+
+func TestRouteMatchers(t *testing.T) {
+	a := New()
+	a.GET("/export", func(req *Request, res *Response) error {
+		return res.WriteString("csv " + req.Route().Path)
+	}).MatchHeader("Accept", "text/csv")
+	a.GET("/export", func(req *Request, res *Response) error {
+		return res.WriteString("xml")
+	}).MatchQuery("format", "xml")
+	a.GET("/export", func(req *Request, res *Response) error {
+		return res.WriteString("json")
+	})
+
+	a.POST("/import", func(req *Request, res *Response) error {
+		return res.WriteString("json")
+	}).MatchContentType("application/json")
+
+	assert.PanicsWithValue(t, "air: route already exists", func() {
+		a.GET("/export", func(req *Request, res *Response) error {
+			return nil
+		})
+	})
+
+	for accept, body := range map[string]string{
+		"text/csv;q=0.9, */*": "csv /export",
+		"application/json":    "json",
+		"":                    "json",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/export", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, body, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/export?format=xml", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, "xml", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/import", nil)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/import", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,293 @@
+package air
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScaffoldOptions is a set of options for the `Scaffold()`.
+type ScaffoldOptions struct {
+	// ModulePath is the module path of the generated project, such as
+	// "example.com/foobar".
+	//
+	// If it is empty, the base name of the dir of the `Scaffold()` will be
+	// used.
+	ModulePath string
+
+	// AppName is the `Air#AppName` of the generated project.
+	//
+	// If it is empty, the last element of the `ModulePath` will be used.
+	AppName string
+
+	// Overwrite indicates whether the existing files in the dir of the
+	// `Scaffold()` can be overwritten.
+	Overwrite bool
+}
+
+// Scaffold generates a runnable starter project into the dir with the so. The
+// dir will be created if it does not exist.
+//
+// The generated project consists of
+//
+//	go.mod               the module of the project
+//	main.go              the entry point that serves the app
+//	app.go               the app with its routes and gases
+//	handlers.go          the handlers of the named routes
+//	main_test.go         the tests of the handlers
+//	config.toml          the configuration loaded by the `Air#LoadConfig()`
+//	gases.toml           the gas pipeline loaded by the `Air#LoadGases()`
+//	templates/           the HTML templates with a layout
+//
+// and wires the recommended gas stack of this framework, which is the
+// `TracingGas()` (whose trace IDs are the request IDs), the `LoggerGas()` and
+// the `ETagGas()` loaded from the "gases.toml" plus the `HealthGas()`. The
+// registered gases can be added to the "gases.toml" as the project grows. Run
+// the `go mod tidy` in the dir to resolve the dependencies of the project.
+//
+// The recovering, the secure headers, the CORS, the sessions and the CSRF
+// protection are not wired, since no gas of them is provided by this framework.
+// Pick the ones that suit the project from the https://github.com/air-gases, or
+// wrap the existing HTTP middleware by using the `WrapHTTPMiddleware()`.
+//
+// It returns an error without writing anything if any of the files already
+// exists, unless the `so.Overwrite` is true.
+func Scaffold(dir string, so ScaffoldOptions) error {
+	if so.ModulePath == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+
+		so.ModulePath = filepath.Base(abs)
+	}
+
+	if so.AppName == "" {
+		so.AppName = path.Base(so.ModulePath)
+	}
+
+	r := strings.NewReplacer(
+		"__MODULE_PATH__", so.ModulePath,
+		`"__APP_NAME__"`, strconv.Quote(so.AppName),
+	)
+
+	names := make([]string, 0, len(scaffoldFiles))
+	for name := range scaffoldFiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if !so.Overwrite {
+		for _, name := range names {
+			fn := filepath.Join(dir, filepath.FromSlash(name))
+			if _, err := os.Stat(fn); err == nil {
+				return fmt.Errorf("file %q already exists", fn)
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	for _, name := range names {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			return err
+		}
+
+		err := ioutil.WriteFile(
+			fn,
+			[]byte(r.Replace(scaffoldFiles[name])),
+			0644,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scaffoldFiles is the files generated by the `Scaffold()`.
+var scaffoldFiles = map[string]string{
+	"go.mod": `module __MODULE_PATH__
+
+go 1.16
+`,
+	"main.go": `package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	configFile := flag.String(
+		"config",
+		"config.toml",
+		"the configuration file",
+	)
+	flag.Parse()
+
+	a, err := newApp(*configFile)
+	if err != nil {
+		panic(err)
+	}
+
+	shutdownChan := make(chan os.Signal, 1)
+	signal.Notify(shutdownChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		err := a.Serve()
+		if err != nil && err != http.ErrServerClosed {
+			a.ERROR("server error", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	<-shutdownChan
+	a.Shutdown(10 * time.Second)
+}
+`,
+	"app.go": `package main
+
+import "github.com/aofei/air"
+
+// newApp returns a new app configured by the configFile.
+func newApp(configFile string) (*air.Air, error) {
+	a := air.New()
+	if err := a.LoadConfig(configFile); err != nil {
+		return nil, err
+	}
+
+	if err := a.LoadGases("gases.toml"); err != nil {
+		return nil, err
+	}
+
+	a.Pregases = append(a.Pregases, air.HealthGas(air.HealthGasConfig{}))
+
+	// The route names are used by the a.URL() and the "url" template
+	// function to build the URLs.
+	a.GET("/", getIndex).Name = "index"
+	a.GET("/hello/:name", getHello).Name = "hello"
+
+	return a, nil
+}
+`,
+	"handlers.go": `package main
+
+import "github.com/aofei/air"
+
+// getIndex handles the requests to the home page.
+func getIndex(req *air.Request, res *air.Response) error {
+	return res.Render(map[string]interface{}{
+		"Title": req.Air.AppName,
+	}, "index.html", "layouts/base.html")
+}
+
+// getHello handles the requests to greet someone.
+func getHello(req *air.Request, res *air.Response) error {
+	name := req.Param("name").Value().String()
+	return res.Render(map[string]interface{}{
+		"Title": "Hello, " + name,
+		"Name":  name,
+	}, "hello.html", "layouts/base.html")
+}
+`,
+	"main_test.go": `package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestApp(t *testing.T) {
+	a, err := newApp("config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := httptest.NewServer(a)
+	defer hs.Close()
+
+	for path, want := range map[string]string{
+		"/":          "/hello/air",
+		"/hello/air": "Hello, air!",
+		"/healthz":   "",
+	} {
+		res, err := http.Get(hs.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: got status %d", path, res.StatusCode)
+		} else if !strings.Contains(string(b), want) {
+			t.Errorf("GET %s: got %q, want %q", path, b, want)
+		}
+	}
+}
+`,
+	"config.toml": `app_name = "__APP_NAME__"
+address = "localhost:8080"
+debug_mode = false
+template_root = "templates"
+gzip_enabled = true
+`,
+	"gases.toml": `[[pregases]]
+name = "tracing"
+
+[[gases]]
+name = "logger"
+
+[gases.settings]
+slow_threshold = "500ms"
+tags = [
+	"client_address",
+	"method",
+	"route",
+	"status",
+	"latency",
+	"trace_id",
+]
+
+[[gases]]
+name = "etag"
+`,
+	"templates/layouts/base.html": `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>{{.Title}}</title>
+</head>
+<body>
+	<nav><a href="{{url "index"}}">Home</a></nav>
+	<main>{{.InheritedHTML}}</main>
+</body>
+</html>
+`,
+	"templates/index.html": `<h1>{{.Title}}</h1>
+<p><a href="{{url "hello" "air"}}">Say hello</a></p>
+`,
+	"templates/hello.html": `<h1>Hello, {{.Name}}!</h1>
+`,
+}
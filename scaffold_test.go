@@ -0,0 +1,109 @@
+package air
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaffold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestScaffold")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	project := filepath.Join(dir, "foobar")
+	assert.NoError(t, Scaffold(project, ScaffoldOptions{}))
+
+	b, err := ioutil.ReadFile(filepath.Join(project, "go.mod"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "module foobar\n"))
+
+	fset := token.NewFileSet()
+	for name := range scaffoldFiles {
+		fn := filepath.Join(project, filepath.FromSlash(name))
+		assert.FileExists(t, fn)
+		if filepath.Ext(fn) == ".go" {
+			_, err := parser.ParseFile(fset, fn, nil, 0)
+			assert.NoError(t, err, name)
+		}
+	}
+
+	a := New()
+	assert.NoError(t, a.LoadConfig(filepath.Join(project, "config.toml")))
+	assert.Equal(t, "foobar", a.AppName)
+	assert.NoError(t, a.LoadGases(filepath.Join(project, "gases.toml")))
+	assert.Len(t, a.Pregases, 1)
+	assert.Len(t, a.Gases, 2)
+
+	assert.Error(t, Scaffold(project, ScaffoldOptions{}))
+	assert.NoError(t, Scaffold(project, ScaffoldOptions{
+		ModulePath: "example.com/foo",
+		AppName:    `"bar"`,
+		Overwrite:  true,
+	}))
+
+	b, err = ioutil.ReadFile(filepath.Join(project, "go.mod"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "module example.com/foo\n"))
+
+	a = New()
+	assert.NoError(t, a.LoadConfig(filepath.Join(project, "config.toml")))
+	assert.Equal(t, `"bar"`, a.AppName)
+}
+
+func TestScaffoldProjectTests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping building the scaffolded project in short mode")
+	}
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	root, err := os.Getwd()
+	assert.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "air.TestScaffoldProjectTests")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, Scaffold(dir, ScaffoldOptions{
+		ModulePath: "example.com/foobar",
+	}))
+
+	// The project is built against the framework being tested.
+	f, err := os.OpenFile(
+		filepath.Join(dir, "go.mod"),
+		os.O_APPEND|os.O_WRONLY,
+		0644,
+	)
+	assert.NoError(t, err)
+	_, err = f.WriteString(
+		"\nrequire github.com/aofei/air v0.0.0\n\n" +
+			"replace github.com/aofei/air => " + root + "\n",
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	b, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "go.sum"),
+		b,
+		0644,
+	))
+
+	cmd := exec.Command(goBin, "test", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
@@ -3,6 +3,7 @@ package air
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -51,6 +52,13 @@ func (s *server) serve() error {
 		s.a.DEBUG("air: serving in debug mode")
 	}
 
+	if s.a.StrictParsingEnabled && !s.plaintext() {
+		s.a.WARN(
+			"air: strict parsing is not supported over tls, the " +
+				"connections will not be inspected",
+		)
+	}
+
 	host := s.server.Addr
 	if strings.Contains(host, ":") {
 		var err error
@@ -65,9 +73,15 @@ func (s *server) serve() error {
 			rw http.ResponseWriter,
 			r *http.Request,
 		) {
-			host, _, err := net.SplitHostPort(r.Host)
+			host, err := asciiHost(splitHost(r.Host))
 			if err != nil {
-				host = r.Host
+				http.Error(
+					rw,
+					http.StatusText(http.StatusBadRequest),
+					http.StatusBadRequest,
+				)
+
+				return
 			}
 
 			http.Redirect(
@@ -90,8 +104,20 @@ func (s *server) serve() error {
 			s.a.TLSCertFile,
 			s.a.TLSKeyFile,
 		)
-	} else if s.a.DebugMode || !s.a.ACMEEnabled {
-		return s.server.ListenAndServe()
+	} else if s.plaintext() {
+		if !s.a.StrictParsingEnabled {
+			return s.server.ListenAndServe()
+		}
+
+		l, err := net.Listen("tcp", s.server.Addr)
+		if err != nil {
+			return err
+		}
+
+		return s.server.Serve(&strictListener{
+			Listener: l,
+			a:        s.a,
+		})
 	}
 
 	acm := autocert.Manager{
@@ -99,7 +125,7 @@ func (s *server) serve() error {
 		Cache:  autocert.DirCache(s.a.ACMECertRoot),
 		HostPolicy: func(_ context.Context, h string) error {
 			if len(s.a.HostWhitelist) == 0 ||
-				stringSliceContainsCIly(s.a.HostWhitelist, h) {
+				hostWhitelisted(s.a.HostWhitelist, h) {
 				return nil
 			}
 
@@ -121,6 +147,13 @@ func (s *server) serve() error {
 	return s.server.ListenAndServeTLS("", "")
 }
 
+// plaintext reports whether the s serves over the plaintext connections, which
+// means that neither the TLS nor the ACME is used.
+func (s *server) plaintext() bool {
+	return (s.a.TLSCertFile == "" || s.a.TLSKeyFile == "") &&
+		(s.a.DebugMode || !s.a.ACMEEnabled)
+}
+
 // close closes the s immediately.
 func (s *server) close() error {
 	return s.server.Close()
@@ -145,22 +178,30 @@ func (s *server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// Check host.
 
 	if !s.a.DebugMode && len(s.a.HostWhitelist) > 0 {
-		host, _, err := net.SplitHostPort(r.Host)
-		if err != nil {
-			host = r.Host
-		}
+		host := splitHost(r.Host)
+		if _, err := asciiHost(host); err != nil {
+			http.Error(
+				rw,
+				http.StatusText(http.StatusBadRequest),
+				http.StatusBadRequest,
+			)
 
-		// See RFC 3986, section 3.2.2.
-		if !stringSliceContainsCIly(s.a.HostWhitelist, host) {
+			return
+		} else if !hostWhitelisted(s.a.HostWhitelist, host) {
 			scheme := "http"
 			if r.TLS != nil {
 				scheme = "https"
 			}
 
+			wh, err := asciiHost(s.a.HostWhitelist[0])
+			if err != nil {
+				wh = s.a.HostWhitelist[0]
+			}
+
 			http.Redirect(
 				rw,
 				r,
-				scheme+"://"+s.a.HostWhitelist[0]+r.RequestURI,
+				scheme+"://"+wh+r.RequestURI,
 				http.StatusMovedPermanently,
 			)
 
@@ -188,6 +229,13 @@ func (s *server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		req:  req,
 		ohrw: rw,
 	}
+
+	var lbw *lazyBodyWriter
+	if s.a.ResponseLazyBodyMaxBytes > 0 {
+		lbw = newLazyBodyWriter(rw, r, s.a.ResponseLazyBodyMaxBytes)
+		rw = lbw
+	}
+
 	res.SetHTTPResponseWriter(&responseWriter{
 		r: res,
 		w: rw,
@@ -195,14 +243,25 @@ func (s *server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	req.res = res
 
+	if s.a.DebugMode && s.a.DebugTraceEnabled {
+		req.debugTrace = &debugTrace{}
+	}
+
 	// Chain gases stack.
 
 	h := func(req *Request, res *Response) error {
 		rh := s.a.router.route(req)
 		h := func(req *Request, res *Response) error {
 			err := rh(req, res)
+			if err != nil && s.a.ErrorMapper != nil {
+				err = s.a.ErrorMapper(err)
+			}
+
+			var he *HTTPError
 			if res.Written {
 				return err
+			} else if errors.As(err, &he) {
+				res.Status = he.status(s.a)
 			} else if err == nil {
 				res.Status = http.StatusNoContent
 				r.Header.Del("Content-Type")
@@ -216,7 +275,7 @@ func (s *server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		}
 
 		for i := len(s.a.Gases) - 1; i >= 0; i-- {
-			h = s.a.Gases[i](h)
+			h = req.debugTrace.wrap(s.a.Gases[i], s.a.Gases[i](h))
 		}
 
 		return h(req, res)
@@ -225,12 +284,15 @@ func (s *server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// Chain pregases stack.
 
 	for i := len(s.a.Pregases) - 1; i >= 0; i-- {
-		h = s.a.Pregases[i](h)
+		h = req.debugTrace.wrap(s.a.Pregases[i], s.a.Pregases[i](h))
 	}
 
 	// Execute chain.
 
-	if err := h(req, res); err != nil {
+	if err := normalizeRequestPath(req); err != nil {
+		res.Status = http.StatusBadRequest
+		s.a.ErrorHandler(err, req, res)
+	} else if err := h(req, res); err != nil {
 		s.a.ErrorHandler(err, req, res)
 	}
 
@@ -240,6 +302,12 @@ func (s *server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		res.deferredFuncs[i]()
 	}
 
+	// End lazy message body.
+
+	if lbw != nil {
+		lbw.end()
+	}
+
 	// Put route param values back to the pool.
 
 	if req.routeParamValues != nil {
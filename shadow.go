@@ -0,0 +1,463 @@
+package air
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShadowGasConfig is a set of configurations for the `ShadowGas()`.
+type ShadowGasConfig struct {
+	// Target is the base URL of the shadow deployment that the requests
+	// are mirrored to, such as the "http://shadow.internal:8080".
+	//
+	// It must be a valid absolute URL.
+	Target string
+
+	// Client is the client used to mirror the requests.
+	//
+	// If it is nil, a client with a 10-second timeout will be used.
+	Client *http.Client
+
+	// Methods is the methods of the requests to be mirrored. Only the
+	// idempotent ones should be mirrored unless the shadow deployment
+	// does not share its state with the primary one.
+	//
+	// If it is empty, the GET and the HEAD will be used.
+	Methods []string
+
+	// SampleRate is the rate at which the requests are mirrored. It is a
+	// float between 0 and 1. For example, 0.01 means that only about 1%
+	// of the requests will be mirrored.
+	//
+	// If it is not between 0 and 1 (exclusive), all the requests will be
+	// mirrored.
+	SampleRate float64
+
+	// MaxBodyBytes is the maximum number of bytes of the message bodies of
+	// a request and its responses to be mirrored and compared.
+	//
+	// If it is less than or equal to zero, 1 MiB will be used.
+	MaxBodyBytes int
+
+	// MaxConcurrency is the maximum number of the shadow requests in
+	// flight. The requests beyond it are not mirrored, so that a slow
+	// shadow deployment cannot pile up the goroutines of the primary one.
+	//
+	// If it is less than or equal to zero, 100 will be used.
+	MaxConcurrency int
+
+	// HeaderAllowlist is the names of the response headers to be compared,
+	// such as the "Content-Type". The other response headers are ignored.
+	HeaderAllowlist []string
+
+	// IgnoredFields is the dotted paths (such as the "meta.request_id") of
+	// the fields of the JSON response bodies that are ignored, such as the
+	// IDs and the timestamps that always differ.
+	IgnoredFields []string
+
+	// MismatchHandler is called with the mismatching responses.
+	//
+	// If it is nil, the mismatches will be logged as warnings.
+	MismatchHandler func(sm *ShadowMismatch)
+}
+
+// ShadowMismatch is a mismatch between the primary response and the shadow
+// response to a request detected by the `ShadowGas()`.
+type ShadowMismatch struct {
+	// Method is the method of the request.
+	Method string
+
+	// Path is the path (with the query) of the request.
+	Path string
+
+	// Differences is the descriptions of the differences, such as the
+	// `status: 200 != 500` and the `body.user.name: "foo" != "bar"`.
+	Differences []string
+}
+
+// shadowResponse is a response compared by the `ShadowGas()`.
+type shadowResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// ShadowGas returns a `Gas` that mirrors the requests it processes to the
+// shadow deployment of the `sgc.Target` with the sgc, and compares the primary
+// responses with the shadow responses (the status codes, the headers of the
+// `sgc.HeaderAllowlist` and the message bodies, which are normalized if they
+// are JSON), so that refactors can be validated against the production
+// traffic.
+//
+// The shadow requests are sent after the primary responses, in the
+// background, so they never affect the primary responses. The requests and
+// the responses whose message bodies exceed the `sgc.MaxBodyBytes` are not
+// compared, and the requests are not mirrored while the
+// `sgc.MaxConcurrency` shadow requests are in flight.
+func ShadowGas(sgc ShadowGasConfig) Gas {
+	target, err := url.Parse(sgc.Target)
+	if err != nil {
+		panic(fmt.Errorf("air: %v", err))
+	} else if !target.IsAbs() {
+		panic("air: shadow gas target must be an absolute url")
+	}
+
+	if sgc.Client == nil {
+		sgc.Client = &http.Client{
+			Timeout: 10 * time.Second,
+		}
+	}
+
+	if len(sgc.Methods) == 0 {
+		sgc.Methods = []string{http.MethodGet, http.MethodHead}
+	}
+
+	if sgc.MaxBodyBytes <= 0 {
+		sgc.MaxBodyBytes = 1 << 20
+	}
+
+	if sgc.MaxConcurrency <= 0 {
+		sgc.MaxConcurrency = 100
+	}
+
+	inflight := make(chan struct{}, sgc.MaxConcurrency)
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			if !stringSliceContains(sgc.Methods, req.Method) ||
+				(sgc.SampleRate > 0 && sgc.SampleRate < 1 &&
+					rand.Float64() >= sgc.SampleRate) {
+				return next(req, res)
+			}
+
+			select {
+			case inflight <- struct{}{}:
+			default:
+				return next(req, res)
+			}
+
+			mirrored := false
+			defer func() {
+				if !mirrored {
+					<-inflight
+				}
+			}()
+
+			var body []byte
+			if req.Body != nil && req.ContentLength != 0 {
+				b, err := ioutil.ReadAll(io.LimitReader(
+					req.Body,
+					int64(sgc.MaxBodyBytes)+1,
+				))
+				if err != nil {
+					return err
+				}
+
+				req.Body = ioutil.NopCloser(io.MultiReader(
+					bytes.NewReader(b),
+					req.Body,
+				))
+				if len(b) > sgc.MaxBodyBytes {
+					return next(req, res)
+				}
+
+				body = b
+			}
+
+			method, path := req.Method, req.Path
+			header := req.Header.Clone()
+
+			rb := newResponseBuffer(res)
+			if rb == nil {
+				return next(req, res)
+			}
+
+			err := next(req, res)
+			rb.complete()
+
+			var primary *shadowResponse
+			if res.Written && !rb.streaming &&
+				rb.body.Len() <= sgc.MaxBodyBytes {
+				primary = &shadowResponse{
+					status: rb.status,
+					header: rb.Header().Clone(),
+					body: append(
+						[]byte(nil),
+						rb.body.Bytes()...,
+					),
+				}
+			}
+
+			if ferr := rb.flush(); err == nil {
+				err = ferr
+			}
+
+			if primary != nil {
+				if res.Gzipped {
+					primary.body, _ = gunzip(primary.body)
+				}
+
+				mirrored = true
+				go func() {
+					defer func() {
+						<-inflight
+					}()
+
+					sgc.compare(
+						req.Air,
+						target,
+						method,
+						path,
+						header,
+						body,
+						primary,
+					)
+				}()
+			}
+
+			return err
+		}
+	}
+}
+
+// compare mirrors the request to the target and compares the shadow response
+// with the primary response.
+func (sgc ShadowGasConfig) compare(
+	a *Air,
+	target *url.URL,
+	method string,
+	path string,
+	header http.Header,
+	body []byte,
+	primary *shadowResponse,
+) {
+	shadow, err := sgc.mirror(target, method, path, header, body)
+	if err != nil {
+		a.WARN(
+			"air: failed to mirror request",
+			map[string]interface{}{
+				"method": method,
+				"path":   path,
+				"error":  err.Error(),
+			},
+		)
+
+		return
+	} else if shadow == nil {
+		return
+	}
+
+	ds := sgc.diff(primary, shadow)
+	if len(ds) == 0 {
+		return
+	}
+
+	sm := &ShadowMismatch{
+		Method:      method,
+		Path:        path,
+		Differences: ds,
+	}
+	if sgc.MismatchHandler != nil {
+		sgc.MismatchHandler(sm)
+		return
+	}
+
+	a.WARN(
+		"air: shadow response mismatch",
+		map[string]interface{}{
+			"method":      sm.Method,
+			"path":        sm.Path,
+			"differences": sm.Differences,
+		},
+	)
+}
+
+// mirror sends the request to the target. It returns nil if the message body
+// of the shadow response exceeds the `sgc.MaxBodyBytes`.
+func (sgc ShadowGasConfig) mirror(
+	target *url.URL,
+	method string,
+	path string,
+	header http.Header,
+	body []byte,
+) (*shadowResponse, error) {
+	u, err := target.Parse(strings.TrimSuffix(target.Path, "/") + path)
+	if err != nil {
+		return nil, err
+	}
+
+	hr, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	hr.Header = header
+	hr.Header.Del("Accept-Encoding")
+	hr.Header.Del("Content-Length")
+
+	hres, err := sgc.Client.Do(hr)
+	if err != nil {
+		return nil, err
+	}
+	defer hres.Body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(
+		hres.Body,
+		int64(sgc.MaxBodyBytes)+1,
+	))
+	if err != nil {
+		return nil, err
+	} else if len(b) > sgc.MaxBodyBytes {
+		return nil, nil
+	}
+
+	return &shadowResponse{
+		status: hres.StatusCode,
+		header: hres.Header,
+		body:   b,
+	}, nil
+}
+
+// diff returns the descriptions of the differences between the primary and
+// the shadow.
+func (sgc ShadowGasConfig) diff(primary, shadow *shadowResponse) []string {
+	var ds []string
+	if primary.status != shadow.status {
+		ds = append(ds, fmt.Sprintf(
+			"status: %d != %d",
+			primary.status,
+			shadow.status,
+		))
+	}
+
+	for _, h := range sgc.HeaderAllowlist {
+		pv, sv := primary.header.Get(h), shadow.header.Get(h)
+		if pv != sv {
+			ds = append(ds, fmt.Sprintf(
+				"header %s: %q != %q",
+				h,
+				pv,
+				sv,
+			))
+		}
+	}
+
+	pj, perr := decodeShadowJSON(primary.body)
+	sj, serr := decodeShadowJSON(shadow.body)
+	if perr == nil && serr == nil {
+		return sgc.diffJSON("", pj, sj, ds)
+	} else if !bytes.Equal(primary.body, shadow.body) {
+		ds = append(ds, "body: not equal")
+	}
+
+	return ds
+}
+
+// diffJSON appends the descriptions of the differences between the JSON
+// values x and y at the dotted path to the ds.
+func (sgc ShadowGasConfig) diffJSON(
+	path string,
+	x interface{},
+	y interface{},
+	ds []string,
+) []string {
+	if stringSliceContains(sgc.IgnoredFields, path) {
+		return ds
+	}
+
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+
+		return path + "." + k
+	}
+
+	switch xv := x.(type) {
+	case map[string]interface{}:
+		yv, ok := y.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		ks := make([]string, 0, len(xv)+len(yv))
+		for k := range xv {
+			ks = append(ks, k)
+		}
+
+		for k := range yv {
+			if _, ok := xv[k]; !ok {
+				ks = append(ks, k)
+			}
+		}
+
+		sort.Strings(ks)
+		for _, k := range ks {
+			ds = sgc.diffJSON(join(k), xv[k], yv[k], ds)
+		}
+
+		return ds
+	case []interface{}:
+		yv, ok := y.([]interface{})
+		if !ok || len(xv) != len(yv) {
+			break
+		}
+
+		for i := range xv {
+			k := strconv.Itoa(i)
+			ds = sgc.diffJSON(join(k), xv[i], yv[i], ds)
+		}
+
+		return ds
+	}
+
+	if reflect.DeepEqual(x, y) {
+		return ds
+	}
+
+	xb, _ := json.Marshal(x)
+	yb, _ := json.Marshal(y)
+	if path == "" {
+		return append(ds, fmt.Sprintf("body: %s != %s", xb, yb))
+	}
+
+	return append(ds, fmt.Sprintf("body.%s: %s != %s", path, xb, yb))
+}
+
+// decodeShadowJSON decodes the JSON b with the numbers kept as is.
+func decodeShadowJSON(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	} else if d.More() {
+		return nil, errors.New("invalid json")
+	}
+
+	return v, nil
+}
+
+// gunzip returns the gunzipped b.
+func gunzip(b []byte) ([]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(gr)
+}
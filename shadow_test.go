@@ -0,0 +1,165 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadowGas(t *testing.T) {
+	assert.Panics(t, func() {
+		ShadowGas(ShadowGasConfig{})
+	})
+
+	shadow := httptest.NewServer(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		r *http.Request,
+	) {
+		h := rw.Header()
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		switch r.URL.Path {
+		case "/same":
+			rw.Write([]byte(`{"b":[1,2],"a":"x","id":"s"}`))
+		case "/different":
+			h.Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusAccepted)
+			rw.Write([]byte(`{"a":"y","b":[1,3],"c":true}`))
+		}
+	}))
+	defer shadow.Close()
+
+	sms := make(chan *ShadowMismatch, 1)
+
+	a := New()
+	a.Gases = []Gas{ShadowGas(ShadowGasConfig{
+		Target:          shadow.URL,
+		HeaderAllowlist: []string{"Content-Type"},
+		IgnoredFields:   []string{"id"},
+		MismatchHandler: func(sm *ShadowMismatch) {
+			sms <- sm
+		},
+	})}
+
+	a.GET("/:name", func(req *Request, res *Response) error {
+		return res.WriteJSON(map[string]interface{}{
+			"a":  "x",
+			"b":  []int{1, 2},
+			"id": "p",
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/same", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"a":"x","b":[1,2],"id":"p"}`, rec.Body.String())
+
+	select {
+	case sm := <-sms:
+		t.Errorf("unexpected mismatch: %v", sm.Differences)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/different?q=1", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	select {
+	case sm := <-sms:
+		assert.Equal(t, http.MethodGet, sm.Method)
+		assert.Equal(t, "/different?q=1", sm.Path)
+		assert.Equal(t, []string{
+			"status: 200 != 202",
+			`header Content-Type: ` +
+				`"application/json; charset=utf-8" != ` +
+				`"application/json"`,
+			`body.a: "x" != "y"`,
+			"body.b.1: 2 != 3",
+			"body.c: null != true",
+		}, sm.Differences)
+	case <-time.After(time.Second):
+		t.Error("mismatch not reported")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/different", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	select {
+	case sm := <-sms:
+		t.Errorf("unexpected mismatch: %v", sm.Differences)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestShadowGasMaxConcurrency(t *testing.T) {
+	hits := make(chan struct{}, 3)
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		r *http.Request,
+	) {
+		hits <- struct{}{}
+		<-release
+	}))
+	defer shadow.Close()
+
+	mirrored := make(chan struct{}, 3)
+
+	a := New()
+	a.Gases = []Gas{ShadowGas(ShadowGasConfig{
+		Target:         shadow.URL,
+		MaxConcurrency: 1,
+		MismatchHandler: func(*ShadowMismatch) {
+			mirrored <- struct{}{}
+		},
+	})}
+
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	get := func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		a.server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	wait := func(c chan struct{}) {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatal("request not mirrored")
+		}
+	}
+
+	get()
+	wait(hits)
+
+	// The requests are not mirrored while the shadow ones are in flight.
+	get()
+	close(release)
+	wait(mirrored)
+
+	select {
+	case <-hits:
+		t.Error("unexpected shadow request")
+	default:
+	}
+
+	// The requests are mirrored again once the shadow ones are done.
+	for deadline := time.Now().Add(time.Second); len(hits) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("request not mirrored")
+		}
+
+		get()
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,287 @@
+package air
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Transformer transforms the value v of a field with the param of its rule,
+// such as the "1:100" of the "clamp=1:100", and returns the new value. The new
+// value must be convertible to the type of the field.
+//
+// See the `RegisterTransformer()`.
+type Transformer func(v interface{}, param string) (interface{}, error)
+
+// transformerRegistry is the registry of all registered transformers.
+var transformerRegistry = struct {
+	sync.RWMutex
+
+	m map[string]Transformer
+}{
+	m: map[string]Transformer{
+		"trim":    transformString(strings.TrimSpace),
+		"lower":   transformString(strings.ToLower),
+		"upper":   transformString(strings.ToUpper),
+		"clamp":   transformClamp,
+		"default": transformDefault,
+	},
+}
+
+// RegisterTransformer registers the t for the name into the transformer
+// registry. The registered transformers can be referenced by their names in
+// the "shape" struct tags, which are applied in order to the fields of the
+// values whenever they are bound by the `Request#Bind()`, such as
+// `shape:"trim,lower"` and `shape:"default=20,clamp=1:100"`.
+//
+// The built-in transformers are the "trim", the "lower" and the "upper" for the
+// strings, the "clamp=<min>:<max>" for the numbers and the "default=<value>"
+// that sets the zero values to the value.
+//
+// It is usually called in the `init()` of the package that provides the t.
+//
+// It panics if the name is empty, the t is nil or the name has already been
+// registered.
+func RegisterTransformer(name string, t Transformer) {
+	if name == "" {
+		panic("air: transformer name cannot be empty")
+	} else if t == nil {
+		panic("air: transformer cannot be nil")
+	}
+
+	transformerRegistry.Lock()
+	defer transformerRegistry.Unlock()
+
+	if _, ok := transformerRegistry.m[name]; ok {
+		panic("air: transformer already registered")
+	}
+
+	transformerRegistry.m[name] = t
+}
+
+// shape applies the "shape" struct tags of the fields of the struct rv, and of
+// its nested structs, to the fields.
+func shape(rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i, t := 0, rv.Type(); i < t.NumField(); i++ {
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+
+		sf := t.Field(i)
+		if tag := sf.Tag.Get("shape"); tag != "" {
+			if err := shapeField(fv, tag); err != nil {
+				return fmt.Errorf("%s: %v", sf.Name, err)
+			}
+		}
+
+		if err := shape(fv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// shapeField applies the rules in the tag to the field value fv.
+func shapeField(fv reflect.Value, tag string) error {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+
+		fv = fv.Elem()
+	}
+
+	for _, rp := range strings.Split(tag, ",") {
+		name, param := rp, ""
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, param = name[:i], name[i+1:]
+		}
+
+		transformerRegistry.RLock()
+		t := transformerRegistry.m[name]
+		transformerRegistry.RUnlock()
+		if t == nil {
+			return fmt.Errorf("unknown transformer %q", name)
+		}
+
+		v, err := t(fv.Interface(), param)
+		if err != nil {
+			return err
+		}
+
+		nv := reflect.ValueOf(v)
+		if !nv.IsValid() {
+			nv = reflect.Zero(fv.Type())
+		} else if !nv.Type().ConvertibleTo(fv.Type()) {
+			return fmt.Errorf(
+				"transformer %q returned unconvertible %s",
+				name,
+				nv.Type(),
+			)
+		}
+
+		fv.Set(nv.Convert(fv.Type()))
+	}
+
+	return nil
+}
+
+// transformString returns a `Transformer` that transforms the strings by using
+// the f.
+func transformString(f func(string) string) Transformer {
+	return func(v interface{}, _ string) (interface{}, error) {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.String {
+			return nil, errors.New("transformer requires a string")
+		}
+
+		nv := reflect.New(rv.Type()).Elem()
+		nv.SetString(f(rv.String()))
+
+		return nv.Interface(), nil
+	}
+}
+
+// transformClamp is the `Transformer` that clamps the numbers into the range
+// "<min>:<max>" of the param. Either of the bounds can be omitted.
+func transformClamp(v interface{}, param string) (interface{}, error) {
+	i := strings.IndexByte(param, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid clamp range %q", param)
+	}
+
+	rv := reflect.ValueOf(v)
+	bound := func(b string) (reflect.Value, error) {
+		bv := reflect.New(rv.Type()).Elem()
+		if err := setNumber(bv, b); err != nil {
+			return bv, fmt.Errorf("invalid clamp range %q", param)
+		}
+
+		return bv, nil
+	}
+
+	if min := param[:i]; min != "" {
+		bv, err := bound(min)
+		if err != nil {
+			return nil, err
+		} else if lessNumber(rv, bv) {
+			rv = bv
+		}
+	}
+
+	if max := param[i+1:]; max != "" {
+		bv, err := bound(max)
+		if err != nil {
+			return nil, err
+		} else if lessNumber(bv, rv) {
+			rv = bv
+		}
+	}
+
+	return rv.Interface(), nil
+}
+
+// transformDefault is the `Transformer` that sets the zero values to the
+// param.
+func transformDefault(v interface{}, param string) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsZero() {
+		return v, nil
+	}
+
+	nv := reflect.New(rv.Type()).Elem()
+	switch nv.Kind() {
+	case reflect.String:
+		nv.SetString(param)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q", param)
+		}
+
+		nv.SetBool(b)
+	default:
+		if err := setNumber(nv, param); err != nil {
+			return nil, fmt.Errorf("invalid default %q", param)
+		}
+	}
+
+	return nv.Interface(), nil
+}
+
+// setNumber parses the s into the number rv.
+func setNumber(rv reflect.Value, s string) error {
+	switch rv.Kind() {
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		i64, err := strconv.ParseInt(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		rv.SetInt(i64)
+	case reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64:
+		ui64, err := strconv.ParseUint(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		rv.SetUint(ui64)
+	case reflect.Float32, reflect.Float64:
+		f64, err := strconv.ParseFloat(s, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		rv.SetFloat(f64)
+	default:
+		return fmt.Errorf("unsupported type %s", rv.Type())
+	}
+
+	return nil
+}
+
+// lessNumber reports whether the number x is less than the number y of the
+// same type.
+func lessNumber(x, y reflect.Value) bool {
+	switch x.Kind() {
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		return x.Int() < y.Int()
+	case reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64:
+		return x.Uint() < y.Uint()
+	}
+
+	return x.Float() < y.Float()
+}
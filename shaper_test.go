@@ -0,0 +1,98 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShape(t *testing.T) {
+	RegisterTransformer(
+		"test_reverse",
+		func(v interface{}, _ string) (interface{}, error) {
+			rs := []rune(v.(string))
+			for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+				rs[i], rs[j] = rs[j], rs[i]
+			}
+
+			return string(rs), nil
+		},
+	)
+	defer func() {
+		transformerRegistry.Lock()
+		delete(transformerRegistry.m, "test_reverse")
+		transformerRegistry.Unlock()
+	}()
+
+	assert.Panics(t, func() {
+		RegisterTransformer("trim", transformDefault)
+	})
+
+	type page struct {
+		Limit  int     `json:"limit" shape:"default=20,clamp=1:100"`
+		Offset uint    `json:"offset" shape:"clamp=:1000"`
+		Ratio  float64 `json:"ratio" shape:"default=0.5"`
+	}
+
+	var v struct {
+		Email string `json:"email" shape:"trim,lower"`
+		Code  string `json:"code" shape:"upper,test_reverse"`
+		Page  page   `json:"page"`
+		Tags  *int   `json:"tags" shape:"default=1"`
+	}
+
+	a := New()
+	a.POST("/", func(req *Request, res *Response) error {
+		return req.Bind(&v)
+	})
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		strings.NewReader(`{
+	"email": "  Foo@Example.COM ",
+	"code": "abc",
+	"page": {"offset": 5000}
+}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "foo@example.com", v.Email)
+	assert.Equal(t, "CBA", v.Code)
+	assert.Equal(t, 20, v.Page.Limit)
+	assert.Equal(t, uint(1000), v.Page.Offset)
+	assert.Equal(t, 0.5, v.Page.Ratio)
+	assert.Nil(t, v.Tags)
+
+	var w struct {
+		Limit int `shape:"clamp=1:100"`
+	}
+
+	a.GET("/", func(req *Request, res *Response) error {
+		return req.Bind(&w)
+	})
+
+	req = httptest.NewRequest(http.MethodGet, "/?Limit=-5", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 1, w.Limit)
+
+	var x struct {
+		Limit int `shape:"unknown"`
+	}
+
+	assert.Error(t, shape(reflect.ValueOf(&x)))
+
+	var y struct {
+		Limit int `shape:"trim"`
+	}
+
+	assert.Error(t, shape(reflect.ValueOf(&y)))
+}
@@ -0,0 +1,132 @@
+package air
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// SignatureGasConfig is a set of configurations for the `SignatureGas()`.
+type SignatureGasConfig struct {
+	// Algorithm is the algorithm used to sign the message bodies. It is
+	// either the "hmac-sha256" or the "ed25519".
+	//
+	// If it is empty, the "hmac-sha256" will be used.
+	Algorithm string
+
+	// KeyID is the ID of the `Key`. It is sent along with the signature so
+	// that the consumers know which key to verify with.
+	KeyID string
+
+	// Key is the key used to sign the message bodies. It is the secret for
+	// the "hmac-sha256", or the `ed25519.PrivateKey` for the "ed25519".
+	Key []byte
+
+	// KeySelector returns the key ID and the key used to sign the message
+	// body of the response of the req. It can be used to select keys per
+	// route. An empty key means that the response will not be signed.
+	//
+	// If it is not nil, it will be used instead of the `KeyID` and the
+	// `Key`.
+	KeySelector func(req *Request) (keyID string, key []byte)
+
+	// HeaderName is the name of the header that carries the signature.
+	//
+	// If it is empty, the "Signature" will be used.
+	HeaderName string
+}
+
+// SignatureGas returns a `Gas` that signs the message body of every response it
+// processes with the sgc and sends the detached signature in a header of the
+// form `keyid="<id>",algorithm="<algorithm>",signature="<base64>"`. It can be
+// used by downstream consumers and CDNs to verify the payload integrity, such
+// as for firmware or config distribution endpoints.
+//
+// The signature covers the bytes sent to the client, that is, after the gzip if
+// it is enabled. The responses are fully buffered before being sent, except the
+// ones that are flushed or are event streams, which are streamed unsigned.
+//
+// It panics if the `sgc.Algorithm` is unsupported.
+func SignatureGas(sgc SignatureGasConfig) Gas {
+	if sgc.Algorithm == "" {
+		sgc.Algorithm = "hmac-sha256"
+	}
+
+	switch sgc.Algorithm {
+	case "hmac-sha256", "ed25519":
+	default:
+		panic(fmt.Errorf(
+			"air: unsupported signature algorithm %q",
+			sgc.Algorithm,
+		))
+	}
+
+	if sgc.HeaderName == "" {
+		sgc.HeaderName = "Signature"
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			keyID, key := sgc.KeyID, sgc.Key
+			if sgc.KeySelector != nil {
+				keyID, key = sgc.KeySelector(req)
+			}
+
+			if len(key) == 0 {
+				return next(req, res)
+			}
+
+			rb := newResponseBuffer(res)
+			if rb == nil {
+				return next(req, res)
+			}
+
+			err := next(req, res)
+			if !res.Written || rb.streaming {
+				rb.flush()
+				return err
+			}
+
+			rb.complete()
+
+			sig, serr := sign(sgc.Algorithm, key, rb.body.Bytes())
+			if serr != nil {
+				rb.flush()
+				return serr
+			}
+
+			rb.Header().Set(sgc.HeaderName, fmt.Sprintf(
+				"keyid=%q,algorithm=%q,signature=%q",
+				keyID,
+				sgc.Algorithm,
+				base64.StdEncoding.EncodeToString(sig),
+			))
+
+			if ferr := rb.flush(); err == nil {
+				err = ferr
+			}
+
+			return err
+		}
+	}
+}
+
+// sign signs the b with the key by using the algorithm.
+func sign(algorithm string, key, b []byte) ([]byte, error) {
+	switch algorithm {
+	case "ed25519":
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid ed25519 private key")
+		}
+
+		return ed25519.Sign(ed25519.PrivateKey(key), b), nil
+	}
+
+	m := hmac.New(sha256.New, key)
+	m.Write(b)
+
+	return m.Sum(nil), nil
+}
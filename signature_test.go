@@ -0,0 +1,117 @@
+package air
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureGas(t *testing.T) {
+	a := New()
+	a.Gases = []Gas{SignatureGas(SignatureGasConfig{
+		KeyID: "foo",
+		Key:   []byte("secret"),
+	})}
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+	a.GET("/error", func(req *Request, res *Response) error {
+		return errors.New("Foobar")
+	})
+
+	m := hmac.New(sha256.New, []byte("secret"))
+	m.Write([]byte("Foobar"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Foobar", rec.Body.String())
+	assert.Equal(t, fmt.Sprintf(
+		"keyid=%q,algorithm=%q,signature=%q",
+		"foo",
+		"hmac-sha256",
+		base64.StdEncoding.EncodeToString(m.Sum(nil)),
+	), rec.Header().Get("Signature"))
+
+	a.GzipEnabled = true
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	gr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "Foobar", string(b))
+
+	m.Reset()
+	m.Write(rec.Body.Bytes())
+	assert.Contains(
+		t,
+		rec.Header().Get("Signature"),
+		base64.StdEncoding.EncodeToString(m.Sum(nil)),
+	)
+
+	a.GzipEnabled = false
+
+	req = httptest.NewRequest(http.MethodGet, "/error", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get("Signature"))
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	a = New()
+	a.GET(
+		"/",
+		func(req *Request, res *Response) error {
+			return res.WriteString("Foobar")
+		},
+		SignatureGas(SignatureGasConfig{
+			Algorithm:  "ed25519",
+			HeaderName: "X-Signature",
+			KeySelector: func(req *Request) (string, []byte) {
+				return "bar", priv
+			},
+		}),
+	)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	sig := ed25519.Sign(priv, []byte("Foobar"))
+	assert.True(t, ed25519.Verify(pub, []byte("Foobar"), sig))
+	assert.Equal(t, fmt.Sprintf(
+		"keyid=%q,algorithm=%q,signature=%q",
+		"bar",
+		"ed25519",
+		base64.StdEncoding.EncodeToString(sig),
+	), rec.Header().Get("X-Signature"))
+
+	assert.Panics(t, func() {
+		SignatureGas(SignatureGasConfig{
+			Algorithm: "foobar",
+		})
+	})
+}
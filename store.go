@@ -0,0 +1,260 @@
+package air
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store is a key-value store with the TTL support. It can be used as the
+// backend of the features that need to share states, such as sessions, rate
+// limits, idempotency keys and caches.
+//
+// All the methods of it must be safe for concurrent use.
+type Store interface {
+	// Get returns the value for the key. It returns nil if not found or
+	// expired.
+	Get(key string) ([]byte, error)
+
+	// Set sets the value for the key with the ttl. The value never expires
+	// if the ttl is less than or equal to zero.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// SetIfAbsent sets the value for the key with the ttl only if the key
+	// is not found or has expired. It reports whether the value has been
+	// set.
+	SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error)
+
+	// CompareAndSet sets the new for the key with the ttl only if the
+	// current value for the key equals the old. The key is deleted if the
+	// new is nil. It reports whether the swap has happened.
+	CompareAndSet(
+		key string,
+		old []byte,
+		new []byte,
+		ttl time.Duration,
+	) (bool, error)
+
+	// Delete deletes the value for the key.
+	Delete(key string) error
+}
+
+// boltStoreBucket is the name of the bbolt bucket used by the `BoltStore`.
+var boltStoreBucket = []byte("air")
+
+// BoltStore is a `Store` backed by an embedded bbolt database file. It is
+// suited for single-binary deployments that do not want to run a separate
+// key-value server. The file is locked exclusively while it is open, so it
+// cannot be shared by multiple processes.
+type BoltStore struct {
+	db        *bolt.DB
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBoltStore returns a new instance of the `BoltStore` with the filename. The
+// file will be created if it does not exist. The expired values are swept every
+// sweepInterval, or never if the sweepInterval is less than or equal to zero.
+func NewBoltStore(
+	filename string,
+	sweepInterval time.Duration,
+) (*BoltStore, error) {
+	db, err := bolt.Open(filename, 0600, &bolt.Options{
+		Timeout: time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltStoreBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	bs := &BoltStore{
+		db:        db,
+		closeChan: make(chan struct{}),
+	}
+
+	if sweepInterval > 0 {
+		go func() {
+			t := time.NewTicker(sweepInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					bs.Sweep()
+				case <-bs.closeChan:
+					return
+				}
+			}
+		}()
+	}
+
+	return bs, nil
+}
+
+// Get implements the `Store`.
+func (bs *BoltStore) Get(key string) ([]byte, error) {
+	var value []byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		v, ok := unpackBoltStoreEntry(
+			tx.Bucket(boltStoreBucket).Get([]byte(key)),
+			time.Now(),
+		)
+		if ok {
+			value = append([]byte{}, v...)
+		}
+
+		return nil
+	})
+
+	return value, err
+}
+
+// Set implements the `Store`.
+func (bs *BoltStore) Set(key string, value []byte, ttl time.Duration) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltStoreBucket).Put(
+			[]byte(key),
+			packBoltStoreEntry(value, ttl),
+		)
+	})
+}
+
+// SetIfAbsent implements the `Store`.
+func (bs *BoltStore) SetIfAbsent(
+	key string,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	set := false
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltStoreBucket)
+		if _, ok := unpackBoltStoreEntry(
+			b.Get([]byte(key)),
+			time.Now(),
+		); ok {
+			return nil
+		}
+
+		set = true
+
+		return b.Put([]byte(key), packBoltStoreEntry(value, ttl))
+	})
+
+	return set, err
+}
+
+// CompareAndSet implements the `Store`.
+func (bs *BoltStore) CompareAndSet(
+	key string,
+	old []byte,
+	new []byte,
+	ttl time.Duration,
+) (bool, error) {
+	swapped := false
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltStoreBucket)
+		if v, ok := unpackBoltStoreEntry(
+			b.Get([]byte(key)),
+			time.Now(),
+		); !ok || !bytes.Equal(v, old) {
+			return nil
+		}
+
+		swapped = true
+
+		if new == nil {
+			return b.Delete([]byte(key))
+		}
+
+		return b.Put([]byte(key), packBoltStoreEntry(new, ttl))
+	})
+
+	return swapped, err
+}
+
+// Delete implements the `Store`.
+func (bs *BoltStore) Delete(key string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltStoreBucket).Delete([]byte(key))
+	})
+}
+
+// Sweep deletes all the expired values in the bs.
+func (bs *BoltStore) Sweep() error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltStoreBucket)
+		now := time.Now()
+
+		var eks [][]byte // Expired keys
+		if err := b.ForEach(func(k, v []byte) error {
+			if _, ok := unpackBoltStoreEntry(v, now); !ok {
+				eks = append(eks, append([]byte{}, k...))
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range eks {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Close closes the bs. It stops sweeping and releases the database file.
+// After one call to it, subsequent calls have no effect.
+func (bs *BoltStore) Close() error {
+	var err error
+	bs.closeOnce.Do(func() {
+		close(bs.closeChan)
+		err = bs.db.Close()
+	})
+
+	return err
+}
+
+// packBoltStoreEntry packs the value with the ttl into a bbolt entry. The first
+// 8 bytes of the entry is the expiration time in Unix nanoseconds, or zero if
+// it never expires.
+func packBoltStoreEntry(value []byte, ttl time.Duration) []byte {
+	e := make([]byte, 8+len(value))
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(
+			e,
+			uint64(time.Now().Add(ttl).UnixNano()),
+		)
+	}
+
+	copy(e[8:], value)
+
+	return e
+}
+
+// unpackBoltStoreEntry unpacks the value from the bbolt entry e. It reports
+// false if the e is invalid or has expired at the now.
+func unpackBoltStoreEntry(e []byte, now time.Time) ([]byte, bool) {
+	if len(e) < 8 {
+		return nil, false
+	}
+
+	if et := binary.BigEndian.Uint64(e); et != 0 &&
+		int64(et) <= now.UnixNano() {
+		return nil, false
+	}
+
+	return e[8:], true
+}
@@ -0,0 +1,97 @@
+package air
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestBoltStore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), time.Minute)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	var s Store = bs
+
+	v, err := s.Get("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, s.Set("foo", []byte("bar"), 0))
+
+	v, err = s.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(v))
+
+	set, err := s.SetIfAbsent("foo", []byte("baz"), 0)
+	assert.NoError(t, err)
+	assert.False(t, set)
+
+	assert.NoError(t, s.Set("foo", []byte("bar"), time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+
+	v, err = s.Get("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	set, err = s.SetIfAbsent("foo", []byte("baz"), time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, set)
+
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, bs.Sweep())
+
+	assert.NoError(t, s.Set("bar", []byte("foo"), 0))
+	assert.NoError(t, s.Delete("bar"))
+
+	v, err = s.Get("bar")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, bs.Close())
+	assert.NoError(t, bs.Close())
+}
+
+func TestBoltStoreCompareAndSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestBoltStoreCompareAndSet")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bs, err := NewBoltStore(filepath.Join(dir, "air.db"), 0)
+	assert.NoError(t, err)
+	defer bs.Close()
+
+	swapped, err := bs.CompareAndSet("foo", []byte("bar"), []byte("baz"), 0)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	assert.NoError(t, bs.Set("foo", []byte("bar"), 0))
+
+	swapped, err = bs.CompareAndSet("foo", []byte("qux"), []byte("baz"), 0)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = bs.CompareAndSet("foo", []byte("bar"), []byte("baz"), 0)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	v, err := bs.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(v))
+
+	swapped, err = bs.CompareAndSet("foo", []byte("baz"), nil, 0)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	v, err = bs.Get("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+}
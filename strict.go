@@ -0,0 +1,458 @@
+package air
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// strictListener is a `net.Listener` whose connections are inspected by the
+// `strictConn`.
+type strictListener struct {
+	net.Listener
+
+	a *Air
+}
+
+// Accept implements the `net.Listener`.
+func (sl *strictListener) Accept() (net.Conn, error) {
+	c, err := sl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	mhb := sl.a.MaxHeaderBytes
+	if mhb <= 0 {
+		mhb = http.DefaultMaxHeaderBytes
+	}
+
+	return &strictConn{
+		Conn:           c,
+		a:              sl.a,
+		maxHeaderBytes: mhb,
+	}, nil
+}
+
+// strictConn states.
+const (
+	strictStateHead = iota
+	strictStateBody
+	strictStateChunkSize
+	strictStateChunkData
+	strictStateChunkDataEnd
+	strictStateTrailer
+)
+
+// strictMaxChunkLineBytes is the maximum number of bytes of a chunk-size line
+// (including the chunk extensions) accepted by the `strictConn`.
+const strictMaxChunkLineBytes = 4096
+
+// strictBadRequestResponse is the response sent by the `strictConn` before
+// closing a connection that violates the strict parsing.
+const strictBadRequestResponse = "HTTP/1.1 400 Bad Request\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Connection: close\r\n" +
+	"\r\n" +
+	"400 Bad Request"
+
+// strictConn is a `net.Conn` that inspects the HTTP/1.x messages read from it
+// before they reach the `http.Server`, and rejects the ones that are commonly
+// used to smuggle requests, such as the ones with conflicting
+// "Transfer-Encoding" and "Content-Length" headers, obs-folded headers, bare LF
+// line endings or malformed chunk extensions.
+type strictConn struct {
+	net.Conn
+	sync.Mutex
+
+	a              *Air
+	maxHeaderBytes int
+	state          int
+	line           []byte
+	headBytes      int
+	requestLined   bool
+	contentLength  string
+	chunked        bool
+	teCount        int
+	remaining      int64
+	passthrough    bool
+	err            error
+}
+
+// Read implements the `net.Conn`.
+func (sc *strictConn) Read(b []byte) (int, error) {
+	if err := sc.rejection(); err != nil {
+		return 0, err
+	}
+
+	n, err := sc.Conn.Read(b)
+	if sc.passedThrough() || n == 0 {
+		return n, err
+	}
+
+	if ierr := sc.inspect(b[:n]); ierr != nil {
+		sc.Lock()
+		sc.err = ierr
+		sc.Conn.Write([]byte(strictBadRequestResponse))
+		sc.Unlock()
+
+		sc.a.DEBUG(
+			"air: rejected request by strict parsing",
+			map[string]interface{}{
+				"remote_address": sc.RemoteAddr().String(),
+				"error":          ierr.Error(),
+			},
+		)
+
+		return 0, ierr
+	}
+
+	return n, err
+}
+
+// Write implements the `net.Conn`. Everything written after the sc rejects a
+// request is discarded, since the 400 response has been sent.
+//
+// The sc stops inspecting once a 101 response is written, since the messages
+// that follow are of the protocol switched to.
+func (sc *strictConn) Write(b []byte) (int, error) {
+	if sc.rejection() != nil {
+		return len(b), nil
+	}
+
+	if len(b) >= 13 && bytes.HasPrefix(b, []byte("HTTP/1.")) &&
+		string(b[8:13]) == " 101 " {
+		sc.passThrough()
+	}
+
+	return sc.Conn.Write(b)
+}
+
+// passThrough stops the sc from inspecting the messages read from it.
+func (sc *strictConn) passThrough() {
+	sc.Lock()
+	sc.passthrough = true
+	sc.Unlock()
+}
+
+// passedThrough reports whether the sc has stopped inspecting the messages read
+// from it.
+func (sc *strictConn) passedThrough() bool {
+	sc.Lock()
+	defer sc.Unlock()
+	return sc.passthrough
+}
+
+// rejection returns the reason why the sc rejected a request. It returns nil
+// if nothing has been rejected.
+func (sc *strictConn) rejection() error {
+	sc.Lock()
+	defer sc.Unlock()
+	return sc.err
+}
+
+// inspect inspects the b as the next bytes of the HTTP/1.x messages of the sc.
+func (sc *strictConn) inspect(b []byte) error {
+	for len(b) > 0 && !sc.passedThrough() {
+		switch sc.state {
+		case strictStateBody, strictStateChunkData:
+			n := int64(len(b))
+			if n > sc.remaining {
+				n = sc.remaining
+			}
+
+			b = b[n:]
+			if sc.remaining -= n; sc.remaining > 0 {
+				continue
+			}
+
+			if sc.state == strictStateBody {
+				sc.state = strictStateHead
+			} else {
+				sc.state = strictStateChunkDataEnd
+			}
+
+			continue
+		}
+
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			sc.line = append(sc.line, b...)
+			return sc.checkLineLength()
+		}
+
+		sc.line = append(sc.line, b[:i+1]...)
+		b = b[i+1:]
+		if err := sc.checkLineLength(); err != nil {
+			return err
+		}
+
+		line := sc.line
+		sc.line = sc.line[:0]
+		if len(line) < 2 || line[len(line)-2] != '\r' {
+			return errors.New("bare LF line ending")
+		}
+
+		if err := sc.inspectLine(line[:len(line)-2]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkLineLength checks the length of the line being read by the sc.
+func (sc *strictConn) checkLineLength() error {
+	switch sc.state {
+	case strictStateHead, strictStateTrailer:
+		// The over-long heads are left to the `http.Server`.
+		if sc.headBytes+len(sc.line) > sc.maxHeaderBytes+4096 {
+			sc.passThrough()
+		}
+	default:
+		if len(sc.line) > strictMaxChunkLineBytes {
+			return errors.New("chunk-size line too long")
+		}
+	}
+
+	return nil
+}
+
+// inspectLine inspects the line without its CRLF of the sc.
+func (sc *strictConn) inspectLine(line []byte) error {
+	switch sc.state {
+	case strictStateChunkSize:
+		size, err := parseStrictChunkSize(line)
+		if err != nil {
+			return err
+		}
+
+		if size == 0 {
+			sc.state = strictStateTrailer
+		} else {
+			sc.state = strictStateChunkData
+			sc.remaining = size
+		}
+
+		return nil
+	case strictStateChunkDataEnd:
+		if len(line) != 0 {
+			return errors.New("missing CRLF after chunk data")
+		}
+
+		sc.state = strictStateChunkSize
+
+		return nil
+	case strictStateTrailer:
+		if len(line) == 0 {
+			sc.state = strictStateHead
+			return nil
+		}
+
+		_, _, err := parseStrictHeaderLine(line)
+
+		return err
+	}
+
+	sc.headBytes += len(line) + 2
+
+	if !sc.requestLined {
+		if string(line) == "PRI * HTTP/2.0" {
+			sc.passThrough()
+			return nil
+		}
+
+		sc.requestLined = true
+
+		return nil
+	}
+
+	if len(line) > 0 {
+		name, value, err := parseStrictHeaderLine(line)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case bytes.EqualFold(name, []byte("Content-Length")):
+			if !isDigits(value) {
+				return errors.New("invalid content-length")
+			} else if sc.contentLength != "" &&
+				sc.contentLength != string(value) {
+				return errors.New("conflicting content-lengths")
+			}
+
+			sc.contentLength = string(value)
+		case bytes.EqualFold(name, []byte("Transfer-Encoding")):
+			sc.teCount++
+			if !bytes.EqualFold(value, []byte("chunked")) {
+				return errors.New(
+					"unsupported transfer-encoding",
+				)
+			}
+
+			sc.chunked = true
+		}
+
+		return nil
+	}
+
+	if sc.teCount > 1 {
+		return errors.New("multiple transfer-encodings")
+	} else if sc.chunked && sc.contentLength != "" {
+		return errors.New(
+			"both transfer-encoding and content-length present",
+		)
+	}
+
+	switch {
+	case sc.chunked:
+		sc.state = strictStateChunkSize
+	case sc.contentLength != "":
+		cl, err := strconv.ParseInt(sc.contentLength, 10, 64)
+		if err != nil {
+			return errors.New("invalid content-length")
+		} else if cl > 0 {
+			sc.state = strictStateBody
+			sc.remaining = cl
+		}
+	}
+
+	sc.headBytes = 0
+	sc.requestLined = false
+	sc.contentLength = ""
+	sc.chunked = false
+	sc.teCount = 0
+
+	return nil
+}
+
+// parseStrictHeaderLine parses the header line into its name and its value. It
+// rejects the obs-folded lines and the names that are not tokens.
+func parseStrictHeaderLine(line []byte) ([]byte, []byte, error) {
+	if line[0] == ' ' || line[0] == '\t' {
+		return nil, nil, errors.New("obs-folded header")
+	}
+
+	i := bytes.IndexByte(line, ':')
+	if i <= 0 {
+		return nil, nil, errors.New("malformed header line")
+	}
+
+	name := line[:i]
+	for _, c := range name {
+		if !isTokenChar(c) {
+			return nil, nil, errors.New("invalid header name")
+		}
+	}
+
+	for _, c := range line[i+1:] {
+		if c == '\r' || c == 0 {
+			return nil, nil, errors.New("invalid header value")
+		}
+	}
+
+	return name, bytes.Trim(line[i+1:], " \t"), nil
+}
+
+// parseStrictChunkSize parses the chunk-size line without its CRLF. It rejects
+// the chunk extensions that do not conform to the RFC 7230, section 4.1.1.
+func parseStrictChunkSize(line []byte) (int64, error) {
+	errMalformed := errors.New("malformed chunk extension")
+
+	i := 0
+	for i < len(line) && isHexDigit(line[i]) {
+		i++
+	}
+
+	if i == 0 || i > 16 {
+		return 0, errors.New("invalid chunk size")
+	}
+
+	size, err := strconv.ParseInt(string(line[:i]), 16, 64)
+	if err != nil {
+		return 0, errors.New("invalid chunk size")
+	}
+
+	for rest := line[i:]; len(rest) > 0; {
+		rest = bytes.TrimLeft(rest, " \t")
+		if len(rest) == 0 || rest[0] != ';' {
+			return 0, errMalformed
+		}
+
+		rest = bytes.TrimLeft(rest[1:], " \t")
+
+		var name []byte
+		if name, rest = splitToken(rest); len(name) == 0 {
+			return 0, errMalformed
+		}
+
+		rest = bytes.TrimLeft(rest, " \t")
+		if len(rest) == 0 || rest[0] != '=' {
+			continue
+		}
+
+		rest = bytes.TrimLeft(rest[1:], " \t")
+		if len(rest) > 0 && rest[0] == '"' {
+			j := 1
+			for ; j < len(rest) && rest[j] != '"'; j++ {
+				if rest[j] == '\\' {
+					j++
+				}
+			}
+
+			if j >= len(rest) {
+				return 0, errMalformed
+			}
+
+			rest = rest[j+1:]
+		} else {
+			var value []byte
+			if value, rest = splitToken(rest); len(value) == 0 {
+				return 0, errMalformed
+			}
+		}
+	}
+
+	return size, nil
+}
+
+// splitToken splits the leading token from the b.
+func splitToken(b []byte) ([]byte, []byte) {
+	i := 0
+	for i < len(b) && isTokenChar(b[i]) {
+		i++
+	}
+
+	return b[:i], b[i:]
+}
+
+// isTokenChar reports whether the c is a tchar of the RFC 7230, section 3.2.6.
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	}
+
+	return bytes.IndexByte([]byte("!#$%&'*+-.^_`|~"), c) >= 0
+}
+
+// isHexDigit reports whether the c is a hex digit.
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') ||
+		(c >= 'a' && c <= 'f') ||
+		(c >= 'A' && c <= 'F')
+}
+
+// isDigits reports whether the b is a non-empty sequence of decimal digits.
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return len(b) > 0
+}
@@ -0,0 +1,216 @@
+package air
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictListener(t *testing.T) {
+	a := New()
+	a.POST("/", func(req *Request, res *Response) error {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+
+		return res.WriteString(string(b))
+	})
+	a.GET("/", func(req *Request, res *Response) error {
+		return res.WriteString("Foobar")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	hs := &http.Server{
+		Handler: a.server,
+	}
+	defer hs.Close()
+
+	go hs.Serve(&strictListener{
+		Listener: l,
+		a:        a,
+	})
+
+	roundTrip := func(raw string) string {
+		c, err := net.Dial("tcp", l.Addr().String())
+		assert.NoError(t, err)
+		defer c.Close()
+
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		c.Write([]byte(raw))
+
+		b, _ := ioutil.ReadAll(c)
+
+		return string(b)
+	}
+
+	for _, raw := range []string{
+		"GET / HTTP/1.1\r\nHost: a\r\nConnection: close\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 6\r\n" +
+			"Connection: close\r\n\r\nFoobar",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n" +
+			"Connection: close\r\n\r\n" +
+			"3;foo=bar\r\nFoo\r\n3;foo=\"b\\\"ar\"\r\nbar\r\n" +
+			"0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 6\r\n" +
+			"Content-Length: 6\r\n\r\nFoobar" +
+			"GET / HTTP/1.1\r\nHost: a\r\n" +
+			"Connection: close\r\n\r\n",
+	} {
+		res := roundTrip(raw)
+		assert.True(t, strings.HasPrefix(res, "HTTP/1.1 200 OK"), raw)
+		assert.True(t, strings.HasSuffix(res, "Foobar"), raw)
+	}
+
+	for _, raw := range []string{
+		// CL.TE
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 13\r\n" +
+			"Transfer-Encoding: chunked\r\n\r\n0\r\n\r\nSMUGGLED",
+
+		// TE.CL
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n" +
+			"Content-Length: 3\r\n\r\n8\r\nSMUGGLED\r\n0\r\n\r\n",
+
+		// TE.TE
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n" +
+			"Transfer-Encoding: x\r\n\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: xchunked" +
+			"\r\n\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding : chunked" +
+			"\r\n\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: gzip, " +
+			"chunked\r\n\r\n0\r\n\r\n",
+
+		// Obs-fold
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding:\r\n chunked" +
+			"\r\n\r\n0\r\n\r\n",
+		"GET / HTTP/1.1\r\nHost: a\r\nFoo: bar\r\n\tbaz\r\n\r\n",
+
+		// Bare LF
+		"GET / HTTP/1.1\nHost: a\n\n",
+		"GET / HTTP/1.1\r\nHost: a\r\nFoo: bar\n\r\n",
+
+		// Conflicting or invalid Content-Length
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 6\r\n" +
+			"Content-Length: 7\r\n\r\nFoobar",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: +6\r\n\r\n" +
+			"Foobar",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 6, 6\r\n\r\n" +
+			"Foobar",
+
+		// Malformed chunks
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked" +
+			"\r\n\r\n3;\r\nFoo\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked" +
+			"\r\n\r\n3;foo=\"bar\r\nFoo\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked" +
+			"\r\n\r\n3;foo=bar baz\r\nFoo\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked" +
+			"\r\n\r\n0x3\r\nFoo\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked" +
+			"\r\n\r\n3\r\nFooX\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked" +
+			"\r\n\r\n3\nFoo\r\n0\r\n\r\n",
+	} {
+		assert.Equal(t, strictBadRequestResponse, roundTrip(raw), raw)
+	}
+
+	// An "Upgrade" header alone does not switch the protocols.
+	res := roundTrip("GET / HTTP/1.1\r\nHost: a\r\nUpgrade: x\r\n\r\n" +
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 13\r\n" +
+		"Transfer-Encoding: chunked\r\n\r\n0\r\n\r\nSMUGGLED")
+	assert.True(t, strings.HasSuffix(res, strictBadRequestResponse), res)
+	assert.NotContains(t, res, "200 OK\r\n\r\nFoobarHTTP/1.1 200")
+}
+
+func TestStrictListenerHijack(t *testing.T) {
+	a := New()
+	a.GET("/", func(req *Request, res *Response) error {
+		conn, brw, err := res.Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 200 OK\r\nConnection: close\r\n\r\n")
+		brw.Flush()
+
+		line, err := brw.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		brw.WriteString(line)
+
+		return brw.Flush()
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	hs := &http.Server{
+		Handler: a.server,
+	}
+	defer hs.Close()
+
+	go hs.Serve(&strictListener{
+		Listener: l,
+		a:        a,
+	})
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer c.Close()
+
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	c.Write([]byte("GET / HTTP/1.1\r\nHost: a\r\n\r\n"))
+
+	b := make([]byte, len("HTTP/1.1 200 OK\r\nConnection: close\r\n\r\n"))
+	_, err = io.ReadFull(c, b)
+	assert.NoError(t, err)
+
+	// The bare LF is not of an HTTP/1.x message any more.
+	c.Write([]byte("Foobar\n"))
+
+	b, _ = ioutil.ReadAll(c)
+	assert.Equal(t, "Foobar\n", string(b))
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	sc := &strictConn{
+		Conn: c1,
+		a:    a,
+	}
+	go ioutil.ReadAll(c2)
+
+	sc.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+	assert.False(t, sc.passedThrough())
+
+	sc.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+	assert.True(t, sc.passedThrough())
+}
+
+func TestServerPlaintext(t *testing.T) {
+	a := New()
+	assert.True(t, a.server.plaintext())
+
+	a.ACMEEnabled = true
+	assert.False(t, a.server.plaintext())
+
+	a.DebugMode = true
+	assert.True(t, a.server.plaintext())
+
+	a.TLSCertFile = "cert.pem"
+	a.TLSKeyFile = "key.pem"
+	assert.False(t, a.server.plaintext())
+}
@@ -0,0 +1,99 @@
+package air
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// maxSubRequestDepth is the maximum depth of the nested sub-requests.
+const maxSubRequestDepth = 8
+
+// subRequestDepthKey is the key of the depth of a sub-request in the
+// `Request#Context`.
+type subRequestDepthKey struct{}
+
+// subRequestExcludedHeaders is the names of the request headers that are not
+// passed to the sub-requests, since the responses of the sub-requests are
+// embedded rather than sent as is.
+var subRequestExcludedHeaders = []string{
+	"Accept-Encoding",
+	"Content-Length",
+	"Content-Type",
+	"If-Match",
+	"If-Modified-Since",
+	"If-None-Match",
+	"If-Range",
+	"If-Unmodified-Since",
+	"Range",
+}
+
+// SubResponse is the response of a sub-request served by the
+// `Air#SubRequest()`.
+type SubResponse struct {
+	// Status is the status code.
+	Status int
+
+	// Header is the header name-value pair map.
+	Header http.Header
+
+	// Body is the message body.
+	Body []byte
+}
+
+// SubRequest serves a sub-request of the req for the method and the path (with
+// the query) through the whole handler chain of the a in process, without a
+// network hop, and returns the collected response, which can be embedded into
+// the response of the req. It enables the edge-side-include-like compositions
+// and the internal batch endpoints.
+//
+// The sub-request inherits the `Request#Context` and the headers of the req,
+// except the ones that concern the message body, the content encoding or the
+// conditional requests. The sub-requests can be nested up to 8 levels.
+func (a *Air) SubRequest(
+	req *Request,
+	method string,
+	path string,
+) (*SubResponse, error) {
+	depth, _ := req.Context.Value(subRequestDepthKey{}).(int)
+	if depth >= maxSubRequestDepth {
+		return nil, errors.New("air: sub-request nested too deeply")
+	}
+
+	hr, err := http.NewRequest(
+		method,
+		req.Scheme+"://"+req.Authority+path,
+		http.NoBody,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hr = hr.WithContext(context.WithValue(
+		req.Context,
+		subRequestDepthKey{},
+		depth+1,
+	))
+	hr.RequestURI = path
+	hr.RemoteAddr = req.RemoteAddress()
+	hr.TLS = req.hr.TLS
+	hr.Header = req.Header.Clone()
+	for _, h := range subRequestExcludedHeaders {
+		hr.Header.Del(h)
+	}
+
+	lrw := a.serveLocalRequest(hr)
+
+	return &SubResponse{
+		Status: lrw.status,
+		Header: lrw.header,
+		Body:   lrw.body.Bytes(),
+	}, nil
+}
+
+// SubRequested reports whether the r is a sub-request served by the
+// `Air#SubRequest()`.
+func (r *Request) SubRequested() bool {
+	depth, _ := r.Context.Value(subRequestDepthKey{}).(int)
+	return depth > 0
+}
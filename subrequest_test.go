@@ -0,0 +1,73 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirSubRequest(t *testing.T) {
+	a := New()
+	a.GET("/fragments/:name", func(req *Request, res *Response) error {
+		assert.True(t, req.SubRequested())
+		assert.Empty(t, req.Header.Get("Accept-Encoding"))
+
+		res.Header.Set("X-Fragment", "true")
+
+		return res.WriteString(
+			"<p>" + req.Param("name").Value().String() + " " +
+				req.Header.Get("Cookie") + "</p>",
+		)
+	})
+
+	a.GET("/loop", func(req *Request, res *Response) error {
+		sr, err := a.SubRequest(req, http.MethodGet, "/loop")
+		if err != nil {
+			return res.WriteString(err.Error())
+		}
+
+		return res.WriteString(string(sr.Body))
+	})
+
+	a.GET("/", func(req *Request, res *Response) error {
+		assert.False(t, req.SubRequested())
+
+		sr, err := a.SubRequest(req, http.MethodGet, "/fragments/foo")
+		if err != nil {
+			return err
+		}
+
+		assert.Equal(t, http.StatusOK, sr.Status)
+		assert.Equal(t, "true", sr.Header.Get("X-Fragment"))
+
+		nf, err := a.SubRequest(req, http.MethodGet, "/missing")
+		if err != nil {
+			return err
+		}
+
+		assert.Equal(t, http.StatusNotFound, nf.Status)
+
+		return res.WriteHTML("<div>" + string(sr.Body) + "</div>")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", "a=b")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<div><p>foo a=b</p></div>", rec.Body.String())
+	assert.Empty(t, rec.Header().Get("X-Fragment"))
+
+	req = httptest.NewRequest(http.MethodGet, "/loop", nil)
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(
+		t,
+		"air: sub-request nested too deeply",
+		rec.Body.String(),
+	)
+}
@@ -0,0 +1,57 @@
+package air
+
+import "sync"
+
+// TenantOther is the tenant that the tenants beyond the limit are folded into
+// by the `MetricsGas()` and the `LoggerGas()`.
+const TenantOther = "other"
+
+// defaultTenantLimit is the default maximum number of the distinct tenants
+// tracked by a `tenantLimiter`.
+const defaultTenantLimit = 100
+
+// tenantLimiter bounds the cardinality of the tenants. It tracks the first
+// limit distinct non-empty tenants it sees and folds all the others into the
+// `TenantOther`, so that a flood of made-up tenants can neither explode the
+// label series of the metrics nor the number of the log partitions. A tracked
+// tenant is never evicted, which keeps the label series stable.
+type tenantLimiter struct {
+	sync.Mutex
+
+	limit   int
+	tenants map[string]struct{}
+}
+
+// newTenantLimiter returns a new instance of the `tenantLimiter` with the
+// limit. The `defaultTenantLimit` is used if the limit is not positive.
+func newTenantLimiter(limit int) *tenantLimiter {
+	if limit <= 0 {
+		limit = defaultTenantLimit
+	}
+
+	return &tenantLimiter{
+		limit:   limit,
+		tenants: map[string]struct{}{},
+	}
+}
+
+// limited returns the t if it is tracked by the tl, or the `TenantOther`
+// otherwise. The empty t is returned as is.
+func (tl *tenantLimiter) limited(t string) string {
+	if t == "" || t == TenantOther {
+		return t
+	}
+
+	tl.Lock()
+	defer tl.Unlock()
+
+	if _, ok := tl.tenants[t]; ok {
+		return t
+	} else if len(tl.tenants) >= tl.limit {
+		return TenantOther
+	}
+
+	tl.tenants[t] = struct{}{}
+
+	return t
+}
@@ -0,0 +1,331 @@
+package air
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ThrottleGasConfig is a set of configurations for the `ThrottleGas()`.
+type ThrottleGasConfig struct {
+	// BytesPerSecond is the maximum bandwidth of the message bodies of the
+	// responses in bytes per second.
+	//
+	// It must be greater than zero.
+	BytesPerSecond int
+
+	// Burst is the maximum number of bytes that can be sent at once after
+	// a pause.
+	//
+	// If it is less than or equal to zero, the `BytesPerSecond` will be
+	// used.
+	Burst int
+
+	// Principal returns the principal of a request, such as the ID of the
+	// authenticated user. The requests of the same principal share the
+	// bandwidth, even across connections.
+	//
+	// If it is nil, or it returns "", the requests will share the
+	// bandwidth per connection.
+	Principal func(req *Request) string
+}
+
+// ThrottleGas returns a `Gas` that limits the bandwidth of the responses of
+// the requests it processes with the tgc, so that the bulk downloads cannot
+// starve the interactive traffic on the same instance. It is usually used per
+// route, such as `a.GET("/exports/:id", h, ThrottleGas(tgc))`.
+//
+// The bandwidth is limited by a token bucket per connection or per principal,
+// which is released once no response of it is in flight. The bytes are
+// counted after the gzip (if any), as they are sent on the wire. When the
+// bandwidth is exhausted, the more urgent responses (see the
+// `Request#Priority()`) are sent first.
+//
+// It panics if the `tgc.BytesPerSecond` is not greater than zero.
+func ThrottleGas(tgc ThrottleGasConfig) Gas {
+	if tgc.BytesPerSecond <= 0 {
+		panic("air: throttle gas bytes per second must be positive")
+	}
+
+	if tgc.Burst <= 0 {
+		tgc.Burst = tgc.BytesPerSecond
+	}
+
+	tbs := &tokenBuckets{
+		rate:    float64(tgc.BytesPerSecond),
+		burst:   tgc.Burst,
+		buckets: map[string]*tokenBucket{},
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			rw, ok := res.hrw.(*responseWriter)
+			if !ok || res.Written {
+				return next(req, res)
+			}
+
+			key := "connection:" + req.RemoteAddress()
+			if tgc.Principal != nil {
+				if p := tgc.Principal(req); p != "" {
+					key = "principal:" + p
+				}
+			}
+
+			tb := tbs.acquire(key)
+			defer tbs.release(key)
+
+			rw.w = &throttledWriter{
+				w:       rw.w,
+				tb:      tb,
+				ctx:     req.Context,
+				urgency: req.Priority().Urgency,
+			}
+
+			return next(req, res)
+		}
+	}
+}
+
+// tokenBuckets is the shared `tokenBucket`s of a `ThrottleGas()`.
+type tokenBuckets struct {
+	sync.Mutex
+
+	rate    float64
+	burst   int
+	buckets map[string]*tokenBucket
+}
+
+// acquire returns the `tokenBucket` of the key and retains it.
+func (tbs *tokenBuckets) acquire(key string) *tokenBucket {
+	tbs.Lock()
+	defer tbs.Unlock()
+
+	tb, ok := tbs.buckets[key]
+	if !ok {
+		tb = &tokenBucket{
+			rate:   tbs.rate,
+			burst:  tbs.burst,
+			tokens: float64(tbs.burst),
+			last:   time.Now(),
+		}
+		tbs.buckets[key] = tb
+	}
+
+	tb.refs++
+
+	return tb
+}
+
+// release releases the `tokenBucket` of the key. It is removed once it is not
+// retained.
+func (tbs *tokenBuckets) release(key string) {
+	tbs.Lock()
+	defer tbs.Unlock()
+
+	if tb := tbs.buckets[key]; tb != nil {
+		if tb.refs--; tb.refs <= 0 {
+			delete(tbs.buckets, key)
+		}
+	}
+}
+
+// tokenBucket is a token bucket of bytes.
+type tokenBucket struct {
+	sync.Mutex
+
+	rate    float64
+	burst   int
+	tokens  float64
+	last    time.Time
+	refs    int
+	waiters []*tokenWaiter
+	changed chan struct{}
+}
+
+// tokenWaiter is a waiter of a `tokenBucket`.
+type tokenWaiter struct {
+	urgency int
+}
+
+// take waits until the n bytes can be taken from the tb, or until the ctx is
+// done. The n must not be greater than the `tb.burst`.
+//
+// The waiters are served in the order of their urgencies (see the
+// `Priority`), and then in the order of their arrivals.
+func (tb *tokenBucket) take(ctx context.Context, n, urgency int) error {
+	tb.Lock()
+	tb.refill()
+	if len(tb.waiters) == 0 && tb.tokens >= float64(n) {
+		tb.tokens -= float64(n)
+		tb.Unlock()
+		return nil
+	}
+
+	w := &tokenWaiter{
+		urgency: urgency,
+	}
+
+	i := len(tb.waiters)
+	for i > 0 && tb.waiters[i-1].urgency > urgency {
+		i--
+	}
+
+	tb.waiters = append(tb.waiters, nil)
+	copy(tb.waiters[i+1:], tb.waiters[i:])
+	tb.waiters[i] = w
+	if i == 0 {
+		tb.notify()
+	}
+
+	for {
+		var (
+			t       *time.Timer
+			timeout <-chan time.Time
+		)
+
+		if tb.waiters[0] == w {
+			if tb.tokens >= float64(n) {
+				tb.tokens -= float64(n)
+				tb.remove(w)
+				tb.Unlock()
+				return nil
+			}
+
+			t = time.NewTimer(time.Duration(
+				(float64(n) - tb.tokens) / tb.rate *
+					float64(time.Second),
+			))
+			timeout = t.C
+		}
+
+		if tb.changed == nil {
+			tb.changed = make(chan struct{})
+		}
+
+		changed := tb.changed
+		tb.Unlock()
+
+		select {
+		case <-timeout:
+		case <-changed:
+		case <-ctx.Done():
+		}
+
+		if t != nil {
+			t.Stop()
+		}
+
+		tb.Lock()
+		if err := ctx.Err(); err != nil {
+			tb.remove(w)
+			tb.Unlock()
+			return err
+		}
+
+		tb.refill()
+	}
+}
+
+// refill refills the tb with the tokens accumulated since the last refill. It
+// must be called with the tb locked.
+func (tb *tokenBucket) refill() {
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > float64(tb.burst) {
+		tb.tokens = float64(tb.burst)
+	}
+
+	tb.last = now
+}
+
+// remove removes the w from the waiters of the tb and wakes up the others if
+// the w was the first one. It must be called with the tb locked.
+func (tb *tokenBucket) remove(w *tokenWaiter) {
+	for i, tw := range tb.waiters {
+		if tw == w {
+			tb.waiters = append(tb.waiters[:i], tb.waiters[i+1:]...)
+			if i == 0 {
+				tb.notify()
+			}
+
+			return
+		}
+	}
+}
+
+// notify wakes up all the waiters of the tb. It must be called with the tb
+// locked.
+func (tb *tokenBucket) notify() {
+	if tb.changed != nil {
+		close(tb.changed)
+		tb.changed = nil
+	}
+}
+
+// throttledWriter is an `http.ResponseWriter` that limits the bandwidth of
+// the message body written to it by using a `tokenBucket`.
+type throttledWriter struct {
+	w       http.ResponseWriter
+	tb      *tokenBucket
+	ctx     context.Context
+	urgency int
+}
+
+// Header implements the `http.ResponseWriter`.
+func (tw *throttledWriter) Header() http.Header {
+	return tw.w.Header()
+}
+
+// Write implements the `http.ResponseWriter`.
+func (tw *throttledWriter) Write(b []byte) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		c := b
+		if len(c) > tw.tb.burst {
+			c = c[:tw.tb.burst]
+		}
+
+		if err := tw.tb.take(tw.ctx, len(c), tw.urgency); err != nil {
+			return n, err
+		}
+
+		cn, err := tw.w.Write(c)
+		n += cn
+		if err != nil {
+			return n, err
+		}
+
+		b = b[len(c):]
+
+		// Send what is allowed so far instead of letting it pile up in
+		// the buffers.
+		if f, ok := tw.w.(http.Flusher); ok && len(b) > 0 {
+			f.Flush()
+		}
+	}
+
+	return n, nil
+}
+
+// WriteHeader implements the `http.ResponseWriter`.
+func (tw *throttledWriter) WriteHeader(status int) {
+	tw.w.WriteHeader(status)
+}
+
+// Flush implements the `http.Flusher`.
+func (tw *throttledWriter) Flush() {
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Push implements the `http.Pusher`.
+func (tw *throttledWriter) Push(target string, pos *http.PushOptions) error {
+	p, ok := tw.w.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+
+	return p.Push(target, pos)
+}
@@ -0,0 +1,103 @@
+package air
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleGas(t *testing.T) {
+	assert.Panics(t, func() {
+		ThrottleGas(ThrottleGasConfig{})
+	})
+
+	a := New()
+	a.GET("/download", func(req *Request, res *Response) error {
+		return res.WriteString(strings.Repeat("a", 3000))
+	}, ThrottleGas(ThrottleGasConfig{
+		BytesPerSecond: 10000,
+		Burst:          1000,
+		Principal: func(req *Request) string {
+			return req.Header.Get("User")
+		},
+	}))
+
+	for _, user := range []string{"", "foo"} {
+		req := httptest.NewRequest(http.MethodGet, "/download", nil)
+		req.Header.Set("User", user)
+		rec := httptest.NewRecorder()
+
+		started := time.Now()
+		a.server.ServeHTTP(rec, req)
+		elapsed := time.Since(started)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 3000, rec.Body.Len())
+		assert.True(t, elapsed >= 150*time.Millisecond, elapsed)
+		assert.True(t, elapsed < time.Second, elapsed)
+	}
+}
+
+func TestTokenBucketTake(t *testing.T) {
+	tb := &tokenBucket{
+		rate:   1000,
+		burst:  100,
+		tokens: 100,
+		last:   time.Now(),
+	}
+
+	started := time.Now()
+	assert.NoError(t, tb.take(context.Background(), 100, 3))
+	assert.True(t, time.Since(started) < 10*time.Millisecond)
+
+	// The canceled requests stop waiting.
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		10*time.Millisecond,
+	)
+	defer cancel()
+
+	started = time.Now()
+	assert.Error(t, tb.take(ctx, 100, 3))
+	assert.True(t, time.Since(started) < 90*time.Millisecond)
+}
+
+func TestTokenBucketTakeUrgency(t *testing.T) {
+	tb := &tokenBucket{
+		rate:  1000,
+		burst: 100,
+		last:  time.Now(),
+	}
+
+	order := make(chan int, 2)
+	take := func(urgency int) {
+		go func() {
+			tb.take(context.Background(), 100, urgency)
+			order <- urgency
+		}()
+
+		for {
+			tb.Lock()
+			ok := len(tb.waiters) > 0 &&
+				tb.waiters[0].urgency == urgency
+			tb.Unlock()
+			if ok {
+				return
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	take(5)
+	take(1)
+
+	assert.Equal(t, 1, <-order)
+	assert.Equal(t, 5, <-order)
+	assert.Empty(t, tb.waiters)
+}
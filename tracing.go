@@ -0,0 +1,306 @@
+package air
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TraceContext is the W3C Trace Context and the W3C Baggage propagated with a
+// request. See https://www.w3.org/TR/trace-context and
+// https://www.w3.org/TR/baggage.
+type TraceContext struct {
+	// TraceID is the 32-hex-digit ID of the whole trace.
+	TraceID string
+
+	// ParentID is the 16-hex-digit ID of the caller's span. It is empty if
+	// the trace is started by the current service.
+	ParentID string
+
+	// SpanID is the 16-hex-digit ID of the current span. It is propagated
+	// as the parent ID to the downstream services.
+	SpanID string
+
+	// Flags is the trace flags, such as 0x01 for sampled.
+	Flags byte
+
+	// State is the vendor-specific "tracestate" which is propagated as is.
+	State string
+
+	// Baggage is the key-values propagated across services, such as the ID
+	// of the current tenant or experiment. Changes to it are propagated to
+	// the downstream services.
+	Baggage map[string]string
+}
+
+// traceContextKey is the key of the `TraceContext` in a `context.Context`.
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of the ctx which carries the tc.
+func ContextWithTraceContext(
+	ctx context.Context,
+	tc *TraceContext,
+) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the `TraceContext` carried by the ctx. It
+// returns nil if not found.
+func TraceContextFromContext(ctx context.Context) *TraceContext {
+	tc, _ := ctx.Value(traceContextKey{}).(*TraceContext)
+	return tc
+}
+
+// InjectTraceContext injects the `TraceContext` carried by the ctx (if any)
+// into the h as the "traceparent", the "tracestate" and the "baggage" headers.
+func InjectTraceContext(ctx context.Context, h http.Header) {
+	tc := TraceContextFromContext(ctx)
+	if tc == nil {
+		return
+	}
+
+	h.Set("traceparent", tc.traceparent())
+	if tc.State != "" {
+		h.Set("tracestate", tc.State)
+	} else {
+		h.Del("tracestate")
+	}
+
+	if b := formatBaggage(tc.Baggage); b != "" {
+		h.Set("baggage", b)
+	} else {
+		h.Del("baggage")
+	}
+}
+
+// traceparent returns the "traceparent" header value of the tc.
+func (tc *TraceContext) traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" +
+		hex.EncodeToString([]byte{tc.Flags})
+}
+
+// TracingTransport is an `http.RoundTripper` that injects the `TraceContext`
+// carried by the context of each outbound request into its headers. It can be
+// used as the `http.Client.Transport` so that the trace and the baggage flow
+// across services automatically.
+type TracingTransport struct {
+	// Base is the underlying `http.RoundTripper`.
+	//
+	// The default value is the `http.DefaultTransport`.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements the `http.RoundTripper`.
+func (tt *TracingTransport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+	base := tt.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if TraceContextFromContext(req.Context()) == nil {
+		return base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	InjectTraceContext(req.Context(), req.Header)
+
+	return base.RoundTrip(req)
+}
+
+// TracingGasConfig is a set of configurations for the `TracingGas()`.
+type TracingGasConfig struct {
+	// MaxBaggageMembers is the maximum number of the baggage members
+	// accepted from a request. The excess members will be dropped.
+	//
+	// If it is less than or equal to zero, 64 will be used.
+	MaxBaggageMembers int
+
+	// MaxBaggageBytes is the maximum number of bytes of the "baggage"
+	// header accepted from a request. The whole header will be dropped if
+	// it is exceeded.
+	//
+	// If it is less than or equal to zero, 8192 will be used.
+	MaxBaggageBytes int
+}
+
+// TracingGas returns a `Gas` that parses the "traceparent", the "tracestate"
+// and the "baggage" headers of every request it processes into a
+// `TraceContext` with the tgc, and attaches it to the `Request#Context`. A new
+// trace is started if the request does not carry a valid "traceparent".
+//
+// The `TraceContext` can be accessed by using the `Request#TraceContext()`, and
+// can be injected into outbound requests by using the `InjectTraceContext()`
+// or the `TracingTransport`.
+func TracingGas(tgc TracingGasConfig) Gas {
+	if tgc.MaxBaggageMembers <= 0 {
+		tgc.MaxBaggageMembers = 64
+	}
+
+	if tgc.MaxBaggageBytes <= 0 {
+		tgc.MaxBaggageBytes = 8192
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			tc := &TraceContext{}
+			if parseTraceparent(
+				req.Header.Get("traceparent"),
+				tc,
+			) {
+				tc.State = strings.Join(
+					req.Header["Tracestate"],
+					",",
+				)
+			} else {
+				tc.TraceID = randomHex(16)
+				tc.ParentID = ""
+				tc.Flags = 0
+			}
+
+			tc.SpanID = randomHex(8)
+
+			tc.Baggage = map[string]string{}
+			if b := strings.Join(
+				req.Header["Baggage"],
+				",",
+			); len(b) <= tgc.MaxBaggageBytes {
+				tc.Baggage = parseBaggage(
+					b,
+					tgc.MaxBaggageMembers,
+				)
+			}
+
+			req.Context = ContextWithTraceContext(req.Context, tc)
+
+			return next(req, res)
+		}
+	}
+}
+
+// newTracingGas returns a new `TracingGas()` built with the gs. It is
+// registered as "tracing" in the gas factory registry.
+func newTracingGas(gs GasSettings) (Gas, error) {
+	tgc := TracingGasConfig{}
+
+	var err error
+	if tgc.MaxBaggageMembers, err = gs.Int(
+		"max_baggage_members",
+	); err != nil {
+		return nil, err
+	}
+
+	if tgc.MaxBaggageBytes, err = gs.Int("max_baggage_bytes"); err != nil {
+		return nil, err
+	}
+
+	return TracingGas(tgc), nil
+}
+
+// parseTraceparent parses the "traceparent" header value tp into the tc. It
+// reports false if the tp is invalid.
+func parseTraceparent(tp string, tc *TraceContext) bool {
+	tp = strings.TrimSpace(tp)
+	if len(tp) < 55 || (len(tp) > 55 && tp[55] != '-') ||
+		tp[2] != '-' || tp[35] != '-' || tp[52] != '-' {
+		return false
+	}
+
+	version := tp[:2]
+	if !isLowerHex(version) || version == "ff" ||
+		(version == "00" && len(tp) != 55) {
+		return false
+	}
+
+	traceID, parentID, flags := tp[3:35], tp[36:52], tp[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(parentID) ||
+		!isLowerHex(flags) ||
+		strings.Trim(traceID, "0") == "" ||
+		strings.Trim(parentID, "0") == "" {
+		return false
+	}
+
+	fb, _ := hex.DecodeString(flags)
+
+	tc.TraceID = traceID
+	tc.ParentID = parentID
+	tc.Flags = fb[0]
+
+	return true
+}
+
+// parseBaggage parses the "baggage" header value b into key-values with at most
+// maxMembers members. The properties of the members are dropped, as are the
+// invalid members.
+func parseBaggage(b string, maxMembers int) map[string]string {
+	m := map[string]string{}
+	for _, member := range strings.Split(b, ",") {
+		if len(m) >= maxMembers {
+			break
+		}
+
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i]
+		}
+
+		i := strings.IndexByte(member, '=')
+		if i < 0 {
+			continue
+		}
+
+		k := strings.TrimSpace(member[:i])
+		if k == "" || strings.ContainsAny(k, " \t\"\\") {
+			continue
+		}
+
+		v, err := url.PathUnescape(strings.TrimSpace(member[i+1:]))
+		if err != nil {
+			continue
+		}
+
+		m[k] = v
+	}
+
+	return m
+}
+
+// formatBaggage formats the m into a "baggage" header value. The keys are
+// sorted so that the result is stable.
+func formatBaggage(m map[string]string) string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+
+	sort.Strings(ks)
+
+	members := make([]string, 0, len(ks))
+	for _, k := range ks {
+		members = append(members, k+"="+url.PathEscape(m[k]))
+	}
+
+	return strings.Join(members, ",")
+}
+
+// isLowerHex reports whether the s consists only of lowercase hex digits.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// randomHex returns a random hex string of the n bytes.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,92 @@
+package air
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracingGas(t *testing.T) {
+	a := New()
+	a.Pregases = []Gas{TracingGas(TracingGasConfig{})}
+
+	var tc *TraceContext
+	a.GET("/", func(req *Request, res *Response) error {
+		tc = req.TraceContext()
+		tc.Baggage["experiment"] = "b"
+		return res.WriteString("ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(
+		"traceparent",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	)
+	req.Header.Set("tracestate", "foo=bar")
+	req.Header.Set("baggage", "tenant=acme%20inc;prop=1, invalid")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotNil(t, tc)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", tc.ParentID)
+	assert.Len(t, tc.SpanID, 16)
+	assert.Equal(t, byte(1), tc.Flags)
+	assert.Equal(t, "foo=bar", tc.State)
+	assert.Equal(t, "acme inc", tc.Baggage["tenant"])
+
+	h := http.Header{}
+	InjectTraceContext(ContextWithTraceContext(req.Context(), tc), h)
+	assert.Equal(
+		t,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-"+tc.SpanID+"-01",
+		h.Get("traceparent"),
+	)
+	assert.Equal(t, "foo=bar", h.Get("tracestate"))
+	assert.Equal(t, "experiment=b,tenant=acme%20inc", h.Get("baggage"))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-invalid")
+	rec = httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Len(t, tc.TraceID, 32)
+	assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+	assert.Empty(t, tc.ParentID)
+}
+
+func TestResponseProxyPassTraceContext(t *testing.T) {
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(
+		rw http.ResponseWriter,
+		r *http.Request,
+	) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	a := New()
+	a.Pregases = []Gas{TracingGas(TracingGasConfig{})}
+
+	var tc *TraceContext
+	a.GET("/", func(req *Request, res *Response) error {
+		tc = req.TraceContext()
+		return res.ProxyPass(upstream.URL)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(
+		"traceparent",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	)
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotNil(t, tc)
+	assert.Equal(
+		t,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-"+tc.SpanID+"-01",
+		traceparent,
+	)
+}
@@ -0,0 +1,328 @@
+package air
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validationMessages is the default messages of the validation rules. The
+// field key is the first argument and the rule param is the second.
+var validationMessages = map[string]string{
+	"required": "%[1]s is required",
+	"min":      "%[1]s must be at least %[2]s",
+	"max":      "%[1]s must be at most %[2]s",
+	"len":      "%[1]s must be exactly %[2]s",
+	"email":    "%[1]s must be a valid e-mail address",
+	"oneof":    "%[1]s must be one of [%[2]s]",
+}
+
+// Validator is implemented by the values that validate themselves beyond their
+// "validate" struct tags when they are validated by the `Request#Validate()`.
+//
+// The returned `ValidationErrors` are merged into the ones of the struct tags,
+// and any other error is returned as is.
+type Validator interface {
+	Validate(req *Request) error
+}
+
+// ValidationError is a validation failure of a field.
+type ValidationError struct {
+	// Field is the key of the field, which is the name of its "json" tag
+	// (if any) or its name, prefixed with the keys of its parent fields
+	// separated by ".".
+	Field string
+
+	// Rule is the name of the validation rule that fails, such as the
+	// "required".
+	Rule string
+
+	// Param is the param of the `Rule`, such as the "3" of the "min=3".
+	Param string
+
+	// Message is the message that is safe to be shown to the client.
+	Message string
+}
+
+// Error implements the `error`.
+func (ve *ValidationError) Error() string {
+	return ve.Message
+}
+
+// ValidationErrors is the validation failures of the fields of a value.
+//
+// It is rendered by the `DefaultErrorHandler` as the "errors" member of the
+// problem details for the JSON clients, or as the field "Errors" of the data
+// of the error page template.
+type ValidationErrors []*ValidationError
+
+// Error implements the `error`.
+func (ves ValidationErrors) Error() string {
+	ms := make([]string, 0, len(ves))
+	for _, ve := range ves {
+		ms = append(ms, ve.Error())
+	}
+
+	return strings.Join(ms, "; ")
+}
+
+// Fields returns the messages of the ves keyed by their fields. It can be used
+// to render the inline form errors in the HTML templates, such as
+// `{{range index .Errors "name"}}<p>{{.}}</p>{{end}}`.
+func (ves ValidationErrors) Fields() map[string][]string {
+	fs := make(map[string][]string, len(ves))
+	for _, ve := range ves {
+		fs[ve.Field] = append(fs[ve.Field], ve.Message)
+	}
+
+	return fs
+}
+
+// MarshalJSON implements the `json.Marshaler`. The ves is marshaled as the
+// `ves.Fields()`.
+func (ves ValidationErrors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ves.Fields())
+}
+
+// BindAndValidate binds the r into the v and then validates the v by using the
+// `r#Validate()`.
+func (r *Request) BindAndValidate(v interface{}) error {
+	if err := r.Bind(v); err != nil {
+		return err
+	}
+
+	return r.Validate(v)
+}
+
+// Validate validates the struct pointed to by the v with the rules in the
+// "validate" tags of its fields (such as `validate:"required,max=64"`) and its
+// `Validator` implementation (if any). It returns the `ValidationErrors` and
+// sets the status code of the response to the 422 if the validation fails.
+//
+// The supported rules are the "required" (not the zero value), the "min=<n>",
+// the "max=<n>" and the "len=<n>" (the value of a number, or the length of a
+// string, a slice or a map), the "email" and the "oneof=<a> <b>...". The rules
+// other than the "required" are skipped for the zero values.
+//
+// The messages are localized by using the `r#LocalizedString()` with the keys
+// of the form "validation.<rule>", such as the "validation.required", where the
+// "%[1]s" is the field key and the "%[2]s" is the rule param.
+func (r *Request) Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return errors.New("validation element must be a struct")
+	}
+
+	ves, err := r.validateStruct(rv, "")
+	if err != nil {
+		return err
+	}
+
+	if vr, ok := v.(Validator); ok {
+		if err := vr.Validate(r); err != nil {
+			var vves ValidationErrors
+			if !errors.As(err, &vves) {
+				return err
+			}
+
+			ves = append(ves, vves...)
+		}
+	}
+
+	if len(ves) > 0 {
+		if r.res != nil {
+			r.res.Status = http.StatusUnprocessableEntity
+		}
+
+		return ves
+	}
+
+	return nil
+}
+
+// validateStruct validates the fields of the struct rv whose keys are prefixed
+// with the prefix.
+func (r *Request) validateStruct(
+	rv reflect.Value,
+	prefix string,
+) (ValidationErrors, error) {
+	var ves ValidationErrors
+	for i, t := 0, rv.Type(); i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" { // Unexported
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		key := sf.Name
+		if jt := strings.Split(sf.Tag.Get("json"), ",")[0]; jt == "-" {
+			continue
+		} else if jt != "" {
+			key = jt
+		}
+
+		key = prefix + key
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			fves, err := r.validateField(fv, key, tag)
+			if err != nil {
+				return nil, err
+			}
+
+			ves = append(ves, fves...)
+		}
+
+		if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+
+		if fv.Kind() == reflect.Struct {
+			fp := key + "."
+			if sf.Anonymous {
+				fp = prefix
+			}
+
+			fves, err := r.validateStruct(fv, fp)
+			if err != nil {
+				return nil, err
+			}
+
+			ves = append(ves, fves...)
+		}
+	}
+
+	return ves, nil
+}
+
+// validateField validates the field value fv keyed by the key with the rules in
+// the tag.
+func (r *Request) validateField(
+	fv reflect.Value,
+	key string,
+	tag string,
+) (ValidationErrors, error) {
+	zero := fv.IsZero()
+	for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+		fv = fv.Elem()
+	}
+
+	var ves ValidationErrors
+	for _, rp := range strings.Split(tag, ",") {
+		rule, param := rp, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			rule, param = rule[:i], rule[i+1:]
+		}
+
+		if _, ok := validationMessages[rule]; !ok {
+			return nil, fmt.Errorf(
+				"unknown validation rule %q",
+				rule,
+			)
+		} else if zero && rule != "required" {
+			continue
+		}
+
+		ok, err := validateRule(fv, zero, rule, param)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			continue
+		}
+
+		mk := "validation." + rule
+		mf := r.LocalizedString(mk)
+		if mf == mk {
+			mf = validationMessages[rule]
+		}
+
+		ves = append(ves, &ValidationError{
+			Field:   key,
+			Rule:    rule,
+			Param:   param,
+			Message: fmt.Sprintf(mf, key, param),
+		})
+	}
+
+	return ves, nil
+}
+
+// validateRule reports whether the fv satisfies the rule with the param.
+func validateRule(
+	fv reflect.Value,
+	zero bool,
+	rule string,
+	param string,
+) (bool, error) {
+	switch rule {
+	case "required":
+		return !zero, nil
+	case "email":
+		a, err := mail.ParseAddress(fv.String())
+		return err == nil && a.Address == fv.String(), nil
+	case "oneof":
+		s := fmt.Sprint(fv.Interface())
+		for _, o := range strings.Fields(param) {
+			if o == s {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false, fmt.Errorf(
+			"invalid validation rule param %q",
+			rule+"="+param,
+		)
+	}
+
+	var x float64
+	switch fv.Kind() {
+	case reflect.String:
+		x = float64(utf8.RuneCountInString(fv.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		x = float64(fv.Len())
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		x = float64(fv.Int())
+	case reflect.Uint,
+		reflect.Uint8,
+		reflect.Uint16,
+		reflect.Uint32,
+		reflect.Uint64:
+		x = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		x = fv.Float()
+	default:
+		return false, fmt.Errorf(
+			"validation rule %q unsupported for %s",
+			rule,
+			fv.Kind(),
+		)
+	}
+
+	switch rule {
+	case "min":
+		return x >= n, nil
+	case "max":
+		return x <= n, nil
+	}
+
+	return x == n, nil
+}
@@ -0,0 +1,124 @@
+package air
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testValidationUser struct {
+	Name    string   `json:"name" validate:"required,min=2,max=8"`
+	Email   string   `json:"email" validate:"email"`
+	Age     int      `json:"age" validate:"min=18"`
+	Role    string   `json:"role" validate:"oneof=admin user"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Address struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+}
+
+func (tvu *testValidationUser) Validate(req *Request) error {
+	if tvu.Name == "root" {
+		return ValidationErrors{{
+			Field:   "name",
+			Rule:    "reserved",
+			Message: "name is reserved",
+		}}
+	}
+
+	return nil
+}
+
+func TestRequestValidate(t *testing.T) {
+	a := New()
+	req, _, _ := fakeRRCycle(a, http.MethodGet, "/", nil)
+
+	u := &testValidationUser{}
+	u.Name = "Foobar"
+	u.Address.City = "Foo"
+	assert.NoError(t, req.Validate(u))
+
+	u = &testValidationUser{
+		Name:  "F",
+		Email: "foo",
+		Age:   17,
+		Role:  "root",
+		Tags:  []string{"a", "b", "c"},
+	}
+
+	err := req.Validate(u)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, req.res.Status)
+
+	ves, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, map[string][]string{
+		"name":         {"name must be at least 2"},
+		"email":        {"email must be a valid e-mail address"},
+		"age":          {"age must be at least 18"},
+		"role":         {"role must be one of [admin user]"},
+		"tags":         {"tags must be at most 2"},
+		"address.city": {"address.city is required"},
+	}, ves.Fields())
+
+	u = &testValidationUser{Name: "root"}
+	u.Address.City = "Foo"
+	assert.Equal(t, map[string][]string{
+		"name": {"name is reserved"},
+	}, req.Validate(u).(ValidationErrors).Fields())
+
+	assert.Error(t, req.Validate(&struct {
+		Foo string `validate:"foobar"`
+	}{}))
+}
+
+func TestRequestBindAndValidateI18n(t *testing.T) {
+	dir, err := ioutil.TempDir("", "air.TestRequestBindAndValidateI18n")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "en-US.toml"),
+		nil,
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "zh-CN.toml"),
+		[]byte(`"validation.required" = "%[1]s 不能为空"`),
+		0644,
+	))
+
+	a := New()
+	a.I18nEnabled = true
+	a.LocaleRoot = dir
+	a.POST("/", func(req *Request, res *Response) error {
+		var v struct {
+			Name string `json:"name" validate:"required"`
+		}
+
+		return req.BindAndValidate(&v)
+	})
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		strings.NewReader("{}"),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Language", "zh-CN")
+	rec := httptest.NewRecorder()
+	a.server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(
+		t,
+		rec.Body.String(),
+		`"errors":{"name":["name 不能为空"]}`,
+	)
+}
@@ -0,0 +1,60 @@
+package air
+
+// ViewDataProvider provides the data that is shared by the HTML template
+// renderings of the req, such as the current user, the navigation items, the
+// CSRF token and the flash messages.
+type ViewDataProvider func(req *Request) map[string]interface{}
+
+// ViewDataGas returns a `Gas` that adds the vdps to every request it processes
+// so that their data will be merged into the data of the HTML template
+// renderings of the request. It is usually used as a group-level gas, such as
+// `a.Group("/admin", ViewDataGas(adminNav))`.
+//
+// The data of the vdps takes precedence over the data of the
+// `Air#ViewDataProviders` and the vdps added by the former gases.
+func ViewDataGas(vdps ...ViewDataProvider) Gas {
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) error {
+			req.viewDataProviders = append(
+				req.viewDataProviders,
+				vdps...,
+			)
+
+			return next(req, res)
+		}
+	}
+}
+
+// viewData returns the m merged with the data of the
+// `r.Air.ViewDataProviders` and the `ViewDataProvider`s added by the
+// `ViewDataGas()`s. The existing keys of the m are never overridden. A new map
+// is made if the m is nil and there is any data to be merged.
+func (r *Request) viewData(m map[string]interface{}) map[string]interface{} {
+	vd := map[string]interface{}{}
+	for _, vdps := range [][]ViewDataProvider{
+		r.Air.ViewDataProviders,
+		r.viewDataProviders,
+	} {
+		for _, vdp := range vdps {
+			for k, v := range vdp(r) {
+				vd[k] = v
+			}
+		}
+	}
+
+	if len(vd) == 0 {
+		return m
+	}
+
+	if m == nil {
+		m = make(map[string]interface{}, len(vd))
+	}
+
+	for k, v := range vd {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+
+	return m
+}